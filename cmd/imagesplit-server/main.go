@@ -0,0 +1,66 @@
+// Command imagesplit-server serves the imagesplit HTTP API.
+//
+//	imagesplit-server -addr :8080
+//	curl -F image=@photo.png -F rows=2 -F cols=2 -o tiles.zip http://localhost:8080/split
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/zsq2010/utils/imagesplit/httpsplit"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	maxBody := flag.Int64("max-body", httpsplit.DefaultMaxBodyBytes, "maximum request body size in bytes")
+	maxPixels := flag.Int64("max-pixels", httpsplit.DefaultMaxPixels, "maximum number of pixels of an uploaded image")
+	maxTiles := flag.Int("max-tiles", httpsplit.DefaultMaxTiles, "maximum number of tiles per request")
+	concurrency := flag.Int("concurrency", 0, "maximum number of concurrent splits (default: number of CPUs)")
+	flag.Parse()
+
+	mux := http.NewServeMux()
+	mux.Handle("/split", httpsplit.NewHandler(httpsplit.Config{
+		MaxBodyBytes:  *maxBody,
+		MaxPixels:     *maxPixels,
+		MaxTiles:      *maxTiles,
+		MaxConcurrent: *concurrency,
+	}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       2 * time.Minute,
+		WriteTimeout:      5 * time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
+	log.Printf("imagesplit-server listening on %s", *addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("serve: %v", err)
+	}
+	<-done
+}
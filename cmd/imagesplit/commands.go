@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/zsq2010/utils/imagesplit"
+)
+
+// errHelp is returned when -h was requested; the usage is already printed.
+var errHelp = errors.New("help requested")
+
+// command is a subcommand with its own flag set.
+type command struct {
+	name     string
+	args     string
+	summary  string
+	examples string
+	// setup registers the command specific flags and returns the function
+	// that runs the command with the remaining arguments.
+	setup func(fs *flag.FlagSet, opts *imagesplit.SplitOptions) func(args []string) (any, error)
+
+	jsonOutput bool
+}
+
+var commands = []*command{
+	{
+		name:    "grid",
+		args:    "<image>",
+		summary: "Split an image into a grid of rows x columns tiles.",
+		examples: `  imagesplit grid -rows 2 -cols 3 photo.png
+  imagesplit grid -rows 3 -cols 3 -out tiles -format jpeg -quality 85 photo.png
+  imagesplit grid -rows 1 -cols 4 -archive tiles.zip -json photo.jpg`,
+		setup: func(fs *flag.FlagSet, opts *imagesplit.SplitOptions) func([]string) (any, error) {
+			rows := fs.Int("rows", 0, "number of rows (required)")
+			cols := fs.Int("cols", 0, "number of columns (required)")
+			return func(args []string) (any, error) {
+				input, err := singleArg(args, "image")
+				if err != nil {
+					return nil, err
+				}
+				if *rows <= 0 || *cols <= 0 {
+					return nil, usagef("-rows and -cols must be greater than zero")
+				}
+				files, err := imagesplit.GridSplit(input, *rows, *cols, *opts)
+				return filesResult{Files: files}, err
+			}
+		},
+	},
+	{
+		name:    "tile",
+		args:    "<image>",
+		summary: "Split an image into tiles of a fixed size; edge tiles may be smaller.",
+		examples: `  imagesplit tile -width 512 -height 512 map.png
+  imagesplit tile -width 1080 -height 1350 -out slides -prefix post photo.jpg`,
+		setup: func(fs *flag.FlagSet, opts *imagesplit.SplitOptions) func([]string) (any, error) {
+			width := fs.Int("width", 0, "tile width in pixels (required)")
+			height := fs.Int("height", 0, "tile height in pixels (required)")
+			return func(args []string) (any, error) {
+				input, err := singleArg(args, "image")
+				if err != nil {
+					return nil, err
+				}
+				if *width <= 0 || *height <= 0 {
+					return nil, usagef("-width and -height must be greater than zero")
+				}
+				files, err := imagesplit.TileSplit(input, *width, *height, *opts)
+				return filesResult{Files: files}, err
+			}
+		},
+	},
+	{
+		name:    "dir",
+		args:    "<input-dir> <output-dir>",
+		summary: "Split every PNG and JPEG image of a directory, one subdirectory per image.",
+		examples: `  imagesplit dir -rows 2 -cols 2 photos tiles
+  imagesplit dir -mode tile -width 256 -height 256 -incremental photos tiles
+  imagesplit dir -rows 3 -cols 3 -overwrite clean -json photos tiles`,
+		setup: func(fs *flag.FlagSet, opts *imagesplit.SplitOptions) func([]string) (any, error) {
+			var cfg imagesplit.DirectorySplitConfig
+			mode := fs.String("mode", "grid", "split mode: grid or tile")
+			fs.IntVar(&cfg.Rows, "rows", 0, "number of rows in grid mode")
+			fs.IntVar(&cfg.Cols, "cols", 0, "number of columns in grid mode")
+			fs.IntVar(&cfg.TileWidth, "width", 0, "tile width in tile mode")
+			fs.IntVar(&cfg.TileHeight, "height", 0, "tile height in tile mode")
+			overwrite := fs.String("overwrite", "overwrite", "existing output directories: overwrite, fail, skip or clean")
+			fs.BoolVar(&cfg.Incremental, "incremental", false, "skip images whose source and configuration are unchanged")
+			fs.BoolVar(&cfg.PruneStale, "prune", false, "with -incremental, remove outputs of deleted sources")
+			return func(args []string) (any, error) {
+				if len(args) != 2 {
+					return nil, usagef("expected <input-dir> and <output-dir>, got %d argument(s)", len(args))
+				}
+				switch *mode {
+				case "grid":
+					cfg.Mode = imagesplit.DirectorySplitModeGrid
+					if cfg.Rows <= 0 || cfg.Cols <= 0 {
+						return nil, usagef("-rows and -cols must be greater than zero in grid mode")
+					}
+				case "tile":
+					cfg.Mode = imagesplit.DirectorySplitModeTile
+					if cfg.TileWidth <= 0 || cfg.TileHeight <= 0 {
+						return nil, usagef("-width and -height must be greater than zero in tile mode")
+					}
+				default:
+					return nil, usagef("unsupported -mode %q", *mode)
+				}
+				switch policy := imagesplit.OverwritePolicy(*overwrite); policy {
+				case imagesplit.OverwriteFiles, imagesplit.OverwriteFail, imagesplit.OverwriteSkip, imagesplit.OverwriteClean:
+					cfg.Overwrite = policy
+				default:
+					return nil, usagef("unsupported -overwrite %q", *overwrite)
+				}
+				if cfg.PruneStale && !cfg.Incremental {
+					return nil, usagef("-prune requires -incremental")
+				}
+				cfg.Options = *opts
+				results, err := imagesplit.SplitDirectory(args[0], args[1], cfg)
+				return dirResult{Results: results}, err
+			}
+		},
+	},
+}
+
+type filesResult struct {
+	Files []string `json:"files"`
+}
+
+type dirResult struct {
+	Results map[string][]string `json:"results"`
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// flagSet registers the shared and the command specific flags.
+func (c *command) flagSet(output io.Writer, opts *imagesplit.SplitOptions, archive *string) (*flag.FlagSet, func([]string) (any, error)) {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	if c.name != "dir" {
+		// dir takes the output directory as argument.
+		fs.StringVar(&opts.OutputDir, "out", "", "output directory (default: directory of the image)")
+	}
+	fs.StringVar(&opts.FilePrefix, "prefix", "", "file name prefix of the tiles (default: image name)")
+	fs.StringVar(&opts.Format, "format", "", "output format: png or jpeg (default: input format)")
+	fs.IntVar(&opts.Quality, "quality", 90, "JPEG quality, 1-100")
+	fs.StringVar(archive, "archive", "", "write the tiles into a .zip, .tar, .tar.gz or .tgz archive")
+	fs.BoolVar(&c.jsonOutput, "json", false, "print the generated paths as JSON")
+	exec := c.setup(fs, opts)
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: imagesplit %s [flags] %s\n\n%s\n\nFlags:\n", c.name, c.args, c.summary)
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nExamples:\n%s\n", c.examples)
+	}
+	return fs, exec
+}
+
+// run parses args, runs the command and prints its result.
+func (c *command) run(args []string, stdout, stderr io.Writer) error {
+	var (
+		opts    imagesplit.SplitOptions
+		archive = new(string)
+	)
+	fs, exec := c.flagSet(stderr, &opts, archive)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return errHelp
+		}
+		return &usageError{msg: err.Error()}
+	}
+	if opts.Quality < 1 || opts.Quality > 100 {
+		return usagef("-quality must be between 1 and 100")
+	}
+
+	if want := len(strings.Fields(c.args)); fs.NArg() != want {
+		return usagef("expected %s, got %d argument(s)", c.args, fs.NArg())
+	}
+
+	if *archive != "" {
+		if opts.OutputDir == "" && c.name != "dir" {
+			opts.OutputDir = "."
+		}
+		result, err := writeArchive(*archive, &opts, func() (any, error) { return exec(fs.Args()) })
+		return c.print(stdout, result, err)
+	}
+
+	result, err := exec(fs.Args())
+	return c.print(stdout, result, err)
+}
+
+// writeArchive runs exec with opts.OutputFS writing into a temporary archive
+// next to path, which replaces path only once exec succeeded. A failed run
+// leaves any existing file at path untouched.
+func writeArchive(path string, opts *imagesplit.SplitOptions, exec func() (any, error)) (any, error) {
+	format, err := imagesplit.ArchiveFormatFromPath(path)
+	if err != nil {
+		return nil, &usageError{msg: err.Error()}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("create archive: %w: %w", imagesplit.ErrOutput, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	out, err := imagesplit.NewArchiveFS(tmp, format)
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	opts.OutputFS = out
+	result, err := exec()
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("write archive: %w: %w", imagesplit.ErrOutput, err)
+	}
+	return result, nil
+}
+
+func (c *command) print(w io.Writer, result any, err error) error {
+	if err != nil {
+		return err
+	}
+	if c.jsonOutput {
+		return writeJSON(w, result)
+	}
+	switch r := result.(type) {
+	case filesResult:
+		for _, file := range r.Files {
+			fmt.Fprintln(w, file)
+		}
+	case dirResult:
+		for _, input := range slices.Sorted(maps.Keys(r.Results)) {
+			fmt.Fprintf(w, "%s:\n", input)
+			for _, file := range r.Results[input] {
+				fmt.Fprintf(w, "  %s\n", file)
+			}
+		}
+	}
+	return nil
+}
+
+func singleArg(args []string, name string) (string, error) {
+	if len(args) != 1 {
+		return "", usagef("expected exactly one <%s>, got %d argument(s)", name, len(args))
+	}
+	if strings.TrimSpace(args[0]) == "" {
+		return "", usagef("<%s> must not be empty", name)
+	}
+	return args[0], nil
+}
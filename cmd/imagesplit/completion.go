@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zsq2010/utils/imagesplit"
+)
+
+// runCompletion prints the completion script for the requested shell.
+func runCompletion(args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return usagef("usage: imagesplit completion bash|zsh|fish")
+	}
+
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	subcommands := strings.Join(append(names, "completion"), " ")
+
+	switch args[0] {
+	case "bash":
+		fmt.Fprintf(w, bashCompletion, subcommands, flagCases())
+	case "zsh":
+		fmt.Fprintf(w, "#compdef imagesplit\nautoload -U +X bashcompinit && bashcompinit\n"+bashCompletion, subcommands, flagCases())
+	case "fish":
+		fmt.Fprintf(w, "complete -c imagesplit -f -n __fish_use_subcommand -a '%s'\n", subcommands)
+		fmt.Fprintln(w, "complete -c imagesplit -f -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'")
+		for _, cmd := range commands {
+			for _, flag := range commandFlags(cmd) {
+				fmt.Fprintf(w, "complete -c imagesplit -n '__fish_seen_subcommand_from %s' -o %s\n", cmd.name, flag)
+			}
+		}
+	default:
+		return usagef("unsupported shell %q: use bash, zsh or fish", args[0])
+	}
+	return nil
+}
+
+// flagCases renders one case line per command with its flags.
+func flagCases() string {
+	var b strings.Builder
+	for _, cmd := range commands {
+		var flags []string
+		for _, flag := range commandFlags(cmd) {
+			flags = append(flags, "-"+flag)
+		}
+		b.WriteString("\t\t")
+		fmt.Fprintf(&b, "%s) flags=%q ;;", cmd.name, strings.Join(flags, " "))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// commandFlags lists the flag names of cmd without the leading dash.
+func commandFlags(cmd *command) []string {
+	var (
+		opts    imagesplit.SplitOptions
+		archive string
+	)
+	fs, _ := cmd.flagSet(io.Discard, &opts, &archive)
+	var flags []string
+	fs.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f.Name)
+	})
+	return flags
+}
+
+const bashCompletion = `_imagesplit() {
+	local cur flags
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+		return
+	fi
+	case "${COMP_WORDS[1]}" in
+%s		completion) COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")); return ;;
+	esac
+	if [[ "$cur" == -* ]]; then
+		COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+	else
+		COMPREPLY=($(compgen -f -- "$cur"))
+	fi
+}
+complete -F _imagesplit imagesplit
+`
@@ -0,0 +1,134 @@
+// Command imagesplit splits images into tiles from the command line.
+//
+// Usage:
+//
+//	imagesplit grid [flags] <image>
+//	imagesplit tile [flags] <image>
+//	imagesplit dir [flags] <input-dir> <output-dir>
+//	imagesplit completion bash|zsh|fish
+//
+// Run "imagesplit <command> -h" for the flags and examples of a command.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/zsq2010/utils/imagesplit"
+)
+
+// Exit codes, one per error class, so that scripts can react without parsing
+// messages.
+const (
+	exitOK      = 0
+	exitFailure = 1 // splitting failed for another reason
+	exitUsage   = 2 // unknown command, bad flags or arguments
+	exitInput   = 3 // input missing or not a supported image
+	exitOutput  = 4 // output could not be written (permission, existing directory)
+)
+
+// usageError marks errors caused by the command line itself.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the process exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	var (
+		cmd *command
+		err error
+	)
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		printUsage(stdout)
+		return exitOK
+	case "completion":
+		err = runCompletion(args[1:], stdout)
+	default:
+		cmd = findCommand(args[0])
+		if cmd == nil {
+			fmt.Fprintf(stderr, "imagesplit: unknown command %q\n\n", args[0])
+			printUsage(stderr)
+			return exitUsage
+		}
+		err = cmd.run(args[1:], stdout, stderr)
+	}
+
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, errHelp) {
+		return exitOK
+	}
+	code := exitCode(err)
+	fmt.Fprintf(stderr, "imagesplit: %v\n", err)
+	if cmd != nil && cmd.jsonOutput {
+		writeJSON(stdout, map[string]any{"error": err.Error(), "code": code})
+	}
+	return code
+}
+
+// exitCode maps an error to its exit code.
+func exitCode(err error) int {
+	var usage *usageError
+	switch {
+	case errors.As(err, &usage), errors.Is(err, imagesplit.ErrInvalidArgument):
+		return exitUsage
+	case errors.Is(err, imagesplit.ErrOutput), errors.Is(err, fs.ErrExist):
+		return exitOutput
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, image.ErrFormat), errors.Is(err, imagesplit.ErrUnsupportedFormat):
+		return exitInput
+	case errors.Is(err, fs.ErrPermission):
+		return exitOutput
+	default:
+		return exitFailure
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, `Usage: imagesplit <command> [flags] <args>
+
+Commands:
+  grid        split an image into rows x columns tiles
+  tile        split an image into fixed-size tiles
+  dir         split every image of a directory
+  completion  print a shell completion script (bash, zsh, fish)
+
+Run "imagesplit <command> -h" for the flags of a command.
+
+Exit codes:
+  0  success
+  1  split failed
+  2  usage error
+  3  input missing or not a supported image
+  4  output could not be written
+`)
+}
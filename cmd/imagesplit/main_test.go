@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	testdata "github.com/zsq2010/utils/imagesplit/testdata"
+)
+
+func writeSample(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "sample.png")
+	if err := testdata.WriteGradientPNG(path); err != nil {
+		t.Fatalf("write sample: %v", err)
+	}
+	return path
+}
+
+func runCLI(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestGridJSON(t *testing.T) {
+	dir := t.TempDir()
+	input := writeSample(t, dir)
+	out := filepath.Join(dir, "tiles")
+
+	code, stdout, stderr := runCLI("grid", "-rows", "2", "-cols", "3", "-out", out, "-json", input)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr)
+	}
+	var result filesResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", stdout, err)
+	}
+	if len(result.Files) != 6 {
+		t.Fatalf("expected 6 files, got %v", result.Files)
+	}
+	for _, file := range result.Files {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("missing tile %s: %v", file, err)
+		}
+	}
+}
+
+func TestTileArchive(t *testing.T) {
+	dir := t.TempDir()
+	input := writeSample(t, dir)
+	archive := filepath.Join(dir, "tiles.zip")
+
+	code, stdout, stderr := runCLI("tile", "-width", "400", "-height", "400", "-archive", archive, input)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "sample_tile_0.png") {
+		t.Errorf("unexpected output %q", stdout)
+	}
+	if info, err := os.Stat(archive); err != nil || info.Size() == 0 {
+		t.Fatalf("expected archive to be written: %v", err)
+	}
+}
+
+func TestArchiveKeepsExistingFileOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "keep.zip")
+	if err := os.WriteFile(archive, []byte("precious"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"grid", "-rows", "2", "-cols", "2", "-archive", archive},
+		{"grid", "-rows", "2", "-cols", "2", "-archive", archive, filepath.Join(dir, "missing.png")},
+	} {
+		if code, _, _ := runCLI(args...); code == exitOK {
+			t.Fatalf("%v: expected failure", args)
+		}
+		if data, err := os.ReadFile(archive); err != nil || string(data) != "precious" {
+			t.Fatalf("%v: expected the existing archive to be kept, got %q, %v", args, data, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expected no temporary files to be left, got %v", entries)
+	}
+}
+
+func TestDirCommand(t *testing.T) {
+	inputDir := t.TempDir()
+	writeSample(t, inputDir)
+	outputDir := filepath.Join(t.TempDir(), "out")
+
+	code, stdout, stderr := runCLI("dir", "-mode", "tile", "-width", "500", "-height", "500", "-json", inputDir, outputDir)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr)
+	}
+	var result dirResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", stdout, err)
+	}
+	if len(result.Results) != 1 {
+		t.Fatalf("expected one image, got %v", result.Results)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	input := writeSample(t, dir)
+	notImage := filepath.Join(dir, "notes.png")
+	if err := os.WriteFile(notImage, []byte("not an image"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	blocked := filepath.Join(dir, "blocked")
+	if err := os.WriteFile(blocked, nil, 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	inputDir := filepath.Join(dir, "in")
+	existing := filepath.Join(dir, "existing")
+	if err := os.MkdirAll(inputDir, 0o755); err != nil {
+		t.Fatalf("create input directory: %v", err)
+	}
+	writeSample(t, inputDir)
+	if err := os.MkdirAll(filepath.Join(existing, "sample"), 0o755); err != nil {
+		t.Fatalf("create output directory: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"no args", nil, exitUsage},
+		{"unknown command", []string{"rotate"}, exitUsage},
+		{"missing rows", []string{"grid", input}, exitUsage},
+		{"bad flag", []string{"tile", "-size", "3", input}, exitUsage},
+		{"bad quality", []string{"grid", "-rows", "1", "-cols", "1", "-quality", "0", input}, exitUsage},
+		{"bad archive", []string{"grid", "-rows", "1", "-cols", "1", "-archive", "x.rar", input}, exitUsage},
+		{"missing input", []string{"grid", "-rows", "1", "-cols", "1", filepath.Join(dir, "missing.png")}, exitInput},
+		{"not an image", []string{"grid", "-rows", "1", "-cols", "1", notImage}, exitInput},
+		{"output blocked", []string{"grid", "-rows", "1", "-cols", "1", "-out", filepath.Join(blocked, "x"), input}, exitOutput},
+		{"output exists", []string{"dir", "-mode", "tile", "-width", "500", "-height", "500", "-overwrite", "fail", inputDir, existing}, exitOutput},
+		{"too many rows", []string{"grid", "-rows", "100000", "-cols", "1", input}, exitUsage},
+		{"help", []string{"tile", "-h"}, exitOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code, _, stderr := runCLI(tc.args...); code != tc.want {
+				t.Errorf("exit code %d, want %d (stderr: %s)", code, tc.want, stderr)
+			}
+		})
+	}
+}
+
+func TestJSONError(t *testing.T) {
+	code, stdout, _ := runCLI("grid", "-rows", "1", "-cols", "1", "-json", filepath.Join(t.TempDir(), "missing.png"))
+	if code != exitInput {
+		t.Fatalf("exit code %d, want %d", code, exitInput)
+	}
+	var result struct {
+		Error string `json:"error"`
+		Code  int    `json:"code"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil || result.Code != exitInput || result.Error == "" {
+		t.Fatalf("unexpected JSON error output %q: %v", stdout, err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		code, stdout, _ := runCLI("completion", shell)
+		if code != exitOK || !strings.Contains(stdout, "grid") {
+			t.Errorf("%s completion: exit %d, output %q", shell, code, stdout)
+		}
+	}
+	if code, _, _ := runCLI("completion", "tcsh"); code != exitUsage {
+		t.Errorf("expected usage error for unsupported shell, got %d", code)
+	}
+}
@@ -2,4 +2,9 @@ module github.com/zsq2010/utils
 
 go 1.24.4
 
-require gopkg.in/ini.v1 v1.67.0 // indirect
+require (
+	golang.org/x/image v0.25.0
+	gopkg.in/ini.v1 v1.67.0
+)
+
+require golang.org/x/text v0.23.0 // indirect
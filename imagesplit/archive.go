@@ -0,0 +1,381 @@
+package imagesplit
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "bytes"
+    "compress/gzip"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// ArchiveFormat selects the container written by an ArchiveFS.
+type ArchiveFormat string
+
+const (
+    // ArchiveZip writes a ZIP file with deflate-compressed entries.
+    ArchiveZip ArchiveFormat = "zip"
+    // ArchiveTar writes an uncompressed tar stream.
+    ArchiveTar ArchiveFormat = "tar"
+    // ArchiveTarGz writes a gzip-compressed tar stream.
+    ArchiveTarGz ArchiveFormat = "tar.gz"
+)
+
+// ArchiveFormatFromPath derives the archive format from the extension of
+// path: ".zip", ".tar", ".tar.gz" or ".tgz".
+func ArchiveFormatFromPath(path string) (ArchiveFormat, error) {
+    lower := strings.ToLower(path)
+    switch {
+    case strings.HasSuffix(lower, ".zip"):
+        return ArchiveZip, nil
+    case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+        return ArchiveTarGz, nil
+    case strings.HasSuffix(lower, ".tar"):
+        return ArchiveTar, nil
+    default:
+        return "", fmt.Errorf("unsupported archive extension: %s", path)
+    }
+}
+
+// ArchiveFS is a WritableFS that streams every committed file into a ZIP or
+// tar archive, so split results can be delivered as a single file without a
+// temporary directory. Names are stored slash-separated and relative; a
+// leading "/" is dropped and names escaping the root are refused.
+//
+// The archive is append-only: files can be written once and not removed, so
+// a failed split may leave the tiles written before the failure in the
+// archive, and incremental SplitDirectory runs are not supported. Close must
+// be called to finish the archive. ArchiveFS is safe for concurrent use.
+type ArchiveFS struct {
+    mu     sync.Mutex
+    format ArchiveFormat
+    zw     *zip.Writer
+    tw     *tar.Writer
+    gz     *gzip.Writer
+    file   *os.File
+    files  map[string]archiveEntry
+    dirs   map[string]time.Time
+    closed bool
+}
+
+// NewArchiveFS returns an ArchiveFS writing an archive of the given format to
+// w. Closing the ArchiveFS finishes the archive but does not close w.
+func NewArchiveFS(w io.Writer, format ArchiveFormat) (*ArchiveFS, error) {
+    a := &ArchiveFS{
+        format: format,
+        files:  make(map[string]archiveEntry),
+        dirs:   map[string]time.Time{".": time.Now()},
+    }
+    switch format {
+    case ArchiveZip:
+        a.zw = zip.NewWriter(w)
+    case ArchiveTar:
+        a.tw = tar.NewWriter(w)
+    case ArchiveTarGz:
+        a.gz = gzip.NewWriter(w)
+        a.tw = tar.NewWriter(a.gz)
+    default:
+        return nil, fmt.Errorf("unsupported archive format: %s", format)
+    }
+    return a, nil
+}
+
+// CreateArchive creates the archive file at path, choosing the format from
+// its extension (see ArchiveFormatFromPath). Close finishes and closes the
+// file.
+func CreateArchive(path string) (*ArchiveFS, error) {
+    format, err := ArchiveFormatFromPath(path)
+    if err != nil {
+        return nil, err
+    }
+    f, err := os.Create(path)
+    if err != nil {
+        return nil, outputErrorf("create archive: %w", err)
+    }
+    a, err := NewArchiveFS(f, format)
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+    a.file = f
+    return a, nil
+}
+
+// Format returns the archive format.
+func (a *ArchiveFS) Format() ArchiveFormat {
+    return a.format
+}
+
+// Close writes the archive trailer and, for archives created with
+// CreateArchive, closes the file.
+func (a *ArchiveFS) Close() error {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if a.closed {
+        return nil
+    }
+    a.closed = true
+
+    var err error
+    if a.zw != nil {
+        err = a.zw.Close()
+    }
+    if a.tw != nil {
+        err = a.tw.Close()
+    }
+    if a.gz != nil {
+        if gzErr := a.gz.Close(); err == nil {
+            err = gzErr
+        }
+    }
+    if a.file != nil {
+        if fileErr := a.file.Close(); err == nil {
+            err = fileErr
+        }
+    }
+    if err != nil {
+        return fmt.Errorf("close archive: %w", err)
+    }
+    return nil
+}
+
+func archiveName(op, name string) (string, error) {
+    clean, err := cleanFSName(strings.TrimPrefix(filepath.ToSlash(name), "/"))
+    if err != nil {
+        return "", &fs.PathError{Op: op, Path: name, Err: err}
+    }
+    return clean, nil
+}
+
+// MkdirAll implements WritableFS. Every new directory is added to the archive
+// as a directory entry.
+func (a *ArchiveFS) MkdirAll(dir string) error {
+    clean, err := archiveName("mkdir", dir)
+    if err != nil {
+        return err
+    }
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    return a.mkdirAll(clean)
+}
+
+func (a *ArchiveFS) mkdirAll(clean string) error {
+    if _, ok := a.dirs[clean]; ok {
+        return nil
+    }
+    if _, ok := a.files[clean]; ok {
+        return &fs.PathError{Op: "mkdir", Path: clean, Err: fmt.Errorf("not a directory")}
+    }
+    if err := a.mkdirAll(path.Dir(clean)); err != nil {
+        return err
+    }
+    if a.closed {
+        return &fs.PathError{Op: "mkdir", Path: clean, Err: fs.ErrClosed}
+    }
+
+    now := time.Now()
+    var err error
+    if a.zw != nil {
+        _, err = a.zw.CreateHeader(&zip.FileHeader{Name: clean + "/", Modified: now})
+    } else {
+        err = a.tw.WriteHeader(&tar.Header{
+            Typeflag: tar.TypeDir,
+            Name:     clean + "/",
+            Mode:     0o755,
+            ModTime:  now,
+        })
+    }
+    if err != nil {
+        return fmt.Errorf("write archive directory %s: %w", clean, err)
+    }
+    a.dirs[clean] = now
+    return nil
+}
+
+// Create implements WritableFS. The content is buffered and appended to the
+// archive on Commit; a name can be committed only once.
+func (a *ArchiveFS) Create(name string) (OutputFile, error) {
+    clean, err := archiveName("create", name)
+    if err != nil {
+        return nil, err
+    }
+    return &archiveOutputFile{fs: a, name: clean}, nil
+}
+
+func (a *ArchiveFS) store(clean string, data []byte) error {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if a.closed {
+        return &fs.PathError{Op: "write", Path: clean, Err: fs.ErrClosed}
+    }
+    if _, ok := a.files[clean]; ok {
+        return &fs.PathError{Op: "write", Path: clean, Err: fs.ErrExist}
+    }
+    if _, ok := a.dirs[clean]; ok {
+        return &fs.PathError{Op: "write", Path: clean, Err: fmt.Errorf("is a directory")}
+    }
+    if err := a.mkdirAll(path.Dir(clean)); err != nil {
+        return err
+    }
+
+    now := time.Now()
+    if a.zw != nil {
+        w, err := a.zw.CreateHeader(&zip.FileHeader{Name: clean, Method: zip.Deflate, Modified: now})
+        if err != nil {
+            return fmt.Errorf("write archive entry %s: %w", clean, err)
+        }
+        if _, err := w.Write(data); err != nil {
+            return fmt.Errorf("write archive entry %s: %w", clean, err)
+        }
+    } else {
+        header := &tar.Header{
+            Typeflag: tar.TypeReg,
+            Name:     clean,
+            Size:     int64(len(data)),
+            Mode:     0o644,
+            ModTime:  now,
+        }
+        if err := a.tw.WriteHeader(header); err != nil {
+            return fmt.Errorf("write archive entry %s: %w", clean, err)
+        }
+        if _, err := a.tw.Write(data); err != nil {
+            return fmt.Errorf("write archive entry %s: %w", clean, err)
+        }
+    }
+    a.files[clean] = archiveEntry{size: int64(len(data)), modTime: now}
+    return nil
+}
+
+// ReadFile implements WritableFS. Entries cannot be read back once they have
+// been streamed into the archive.
+func (a *ArchiveFS) ReadFile(name string) ([]byte, error) {
+    info, err := a.Stat(name)
+    if err != nil {
+        return nil, err
+    }
+    return nil, &fs.PathError{Op: "read", Path: info.Name(), Err: errors.ErrUnsupported}
+}
+
+// Stat implements WritableFS for the files and directories written so far.
+func (a *ArchiveFS) Stat(name string) (fs.FileInfo, error) {
+    clean, err := archiveName("stat", name)
+    if err != nil {
+        return nil, err
+    }
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if e, ok := a.files[clean]; ok {
+        return archiveInfo{name: path.Base(clean), archiveEntry: e}, nil
+    }
+    if modTime, ok := a.dirs[clean]; ok {
+        return archiveInfo{name: path.Base(clean), archiveEntry: archiveEntry{dir: true, modTime: modTime}}, nil
+    }
+    return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// Remove implements WritableFS. Files that were already written cannot be
+// removed; a name that was never written reports fs.ErrNotExist.
+func (a *ArchiveFS) Remove(name string) error {
+    clean, err := archiveName("remove", name)
+    if err != nil {
+        return err
+    }
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if _, ok := a.files[clean]; ok {
+        return &fs.PathError{Op: "remove", Path: name, Err: errors.ErrUnsupported}
+    }
+    if _, ok := a.dirs[clean]; ok {
+        return &fs.PathError{Op: "remove", Path: name, Err: errors.ErrUnsupported}
+    }
+    return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+}
+
+// RemoveAll implements WritableFS. It succeeds only when no file has been
+// written below name yet.
+func (a *ArchiveFS) RemoveAll(name string) error {
+    clean, err := archiveName("removeall", name)
+    if err != nil {
+        return err
+    }
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    for p := range a.files {
+        if clean == "." || p == clean || strings.HasPrefix(p, clean+"/") {
+            return &fs.PathError{Op: "removeall", Path: name, Err: errors.ErrUnsupported}
+        }
+    }
+    return nil
+}
+
+// Names returns the files written so far in sorted order.
+func (a *ArchiveFS) Names() []string {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    names := make([]string, 0, len(a.files))
+    for name := range a.files {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+type archiveEntry struct {
+    size    int64
+    dir     bool
+    modTime time.Time
+}
+
+type archiveInfo struct {
+    archiveEntry
+    name string
+}
+
+func (i archiveInfo) Name() string       { return i.name }
+func (i archiveInfo) Size() int64        { return i.size }
+func (i archiveInfo) ModTime() time.Time { return i.modTime }
+func (i archiveInfo) IsDir() bool        { return i.dir }
+func (i archiveInfo) Sys() any           { return nil }
+
+func (i archiveInfo) Mode() fs.FileMode {
+    if i.dir {
+        return fs.ModeDir | 0o755
+    }
+    return 0o644
+}
+
+type archiveOutputFile struct {
+    fs   *ArchiveFS
+    name string
+    buf  bytes.Buffer
+    done bool
+}
+
+func (f *archiveOutputFile) Write(p []byte) (int, error) {
+    if f.done {
+        return 0, fmt.Errorf("output file %s already closed", f.name)
+    }
+    return f.buf.Write(p)
+}
+
+func (f *archiveOutputFile) Commit() error {
+    if f.done {
+        return fmt.Errorf("output file %s already closed", f.name)
+    }
+    f.done = true
+    return f.fs.store(f.name, f.buf.Bytes())
+}
+
+func (f *archiveOutputFile) Abort() error {
+    f.done = true
+    return nil
+}
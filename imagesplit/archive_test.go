@@ -0,0 +1,201 @@
+package imagesplit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestGridSplitToZip(t *testing.T) {
+	var buf bytes.Buffer
+	archive, err := NewArchiveFS(&buf, ArchiveZip)
+	if err != nil {
+		t.Fatalf("NewArchiveFS returned error: %v", err)
+	}
+	pngPath, _ := createSampleImages(t)
+
+	files, err := GridSplit(pngPath, 2, 2, SplitOptions{OutputFS: archive, OutputDir: "tiles"})
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	entries := map[string]*zip.File{}
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+	if _, ok := entries["tiles/"]; !ok {
+		t.Errorf("expected directory entry for tiles/")
+	}
+	for _, name := range files {
+		f, ok := entries[name]
+		if !ok {
+			t.Fatalf("archive is missing %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", name, err)
+		}
+		img, err := png.Decode(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("decode %s: %v", name, err)
+		}
+		if img.Bounds().Dx() != 5 || img.Bounds().Dy() != 5 {
+			t.Errorf("unexpected tile size %v", img.Bounds())
+		}
+	}
+}
+
+func TestSplitDirectoryToTarGz(t *testing.T) {
+	inputDir := t.TempDir()
+	pngPath, jpgPath := createSampleImages(t)
+	for _, src := range []string{pngPath, jpgPath} {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatalf("read sample: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(inputDir, filepath.Base(src)), data, 0o644); err != nil {
+			t.Fatalf("write sample: %v", err)
+		}
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "tiles.tgz")
+	archive, err := CreateArchive(archivePath)
+	if err != nil {
+		t.Fatalf("CreateArchive returned error: %v", err)
+	}
+	if archive.Format() != ArchiveTarGz {
+		t.Fatalf("expected tar.gz format, got %s", archive.Format())
+	}
+	results, err := SplitDirectory(inputDir, "out", DirectorySplitConfig{
+		Mode:    DirectorySplitModeGrid,
+		Rows:    1,
+		Cols:    2,
+		Options: SplitOptions{OutputFS: archive},
+	})
+	if err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	var want []string
+	for _, outputs := range results {
+		want = append(want, outputs...)
+	}
+	sort.Strings(want)
+	if got := archive.Names(); !slices.Equal(got, want) {
+		t.Fatalf("archive names %v, want %v", got, want)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var regular []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar: %v", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			regular = append(regular, header.Name)
+		}
+	}
+	sort.Strings(regular)
+	if !slices.Equal(regular, want) {
+		t.Fatalf("tar entries %v, want %v", regular, want)
+	}
+	if regular[0] != "out/blocks/blocks_row0_col0.jpg" {
+		t.Errorf("expected per-image subfolders, got %v", regular)
+	}
+}
+
+func TestArchiveFSRestrictions(t *testing.T) {
+	archive, err := NewArchiveFS(io.Discard, ArchiveTar)
+	if err != nil {
+		t.Fatalf("NewArchiveFS returned error: %v", err)
+	}
+	defer archive.Close()
+
+	write := func(name string) error {
+		f, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		f.Write([]byte("x"))
+		return f.Commit()
+	}
+	if err := write("a/b.txt"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := write("a/b.txt"); err == nil {
+		t.Fatalf("expected error writing the same name twice")
+	}
+	if _, err := archive.Create("../escape"); err == nil {
+		t.Fatalf("expected error for name escaping the root")
+	}
+	if err := archive.Remove("a/b.txt"); err == nil {
+		t.Fatalf("expected error removing a written entry")
+	}
+	if err := archive.RemoveAll("empty"); err != nil {
+		t.Fatalf("RemoveAll of an unused name returned error: %v", err)
+	}
+	if info, err := archive.Stat("a/b.txt"); err != nil || info.Size() != 1 {
+		t.Fatalf("unexpected Stat result %v, %v", info, err)
+	}
+
+	_, err = SplitDirectory(t.TempDir(), "out", DirectorySplitConfig{
+		Mode:        DirectorySplitModeGrid,
+		Rows:        1,
+		Cols:        1,
+		Incremental: true,
+		Options:     SplitOptions{OutputFS: archive},
+	})
+	if err == nil {
+		t.Fatalf("expected incremental mode to be rejected for archive output")
+	}
+}
+
+func TestArchiveFormatFromPath(t *testing.T) {
+	cases := map[string]ArchiveFormat{
+		"a.zip":    ArchiveZip,
+		"a.TAR":    ArchiveTar,
+		"a.tar.gz": ArchiveTarGz,
+		"a.tgz":    ArchiveTarGz,
+	}
+	for name, want := range cases {
+		got, err := ArchiveFormatFromPath(name)
+		if err != nil || got != want {
+			t.Errorf("ArchiveFormatFromPath(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ArchiveFormatFromPath("a.rar"); err == nil {
+		t.Errorf("expected error for unsupported extension")
+	}
+}
@@ -0,0 +1,60 @@
+package imagesplit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGridSplitAllOrNothing(t *testing.T) {
+	pngPath, _ := createSampleImages(t)
+	outDir := t.TempDir()
+
+	// A non-empty directory in place of the third tile makes its rename fail.
+	blocker := filepath.Join(outDir, "gradient_row0_col2.png")
+	if err := os.MkdirAll(filepath.Join(blocker, "keep"), 0o755); err != nil {
+		t.Fatalf("create blocker: %v", err)
+	}
+
+	if _, err := GridSplit(pngPath, 1, 4, SplitOptions{OutputDir: outDir}); err == nil {
+		t.Fatalf("expected error when a tile cannot be written")
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("read output dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(blocker) {
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Fatalf("expected only the blocker to remain, got %v", names)
+	}
+}
+
+func TestSaveTileLeavesNoTemporaryFiles(t *testing.T) {
+	pngPath, _ := createSampleImages(t)
+	outDir := t.TempDir()
+
+	files, err := TileSplit(pngPath, 5, 5, SplitOptions{OutputDir: outDir})
+	if err != nil {
+		t.Fatalf("TileSplit returned error: %v", err)
+	}
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("read output dir: %v", err)
+	}
+	if len(entries) != len(files) {
+		t.Fatalf("expected %d files, found %d entries", len(files), len(entries))
+	}
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat tile: %v", err)
+		}
+		if info.Mode().Perm() != 0o644 {
+			t.Errorf("unexpected mode %v for %s", info.Mode().Perm(), path)
+		}
+	}
+}
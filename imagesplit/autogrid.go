@@ -0,0 +1,145 @@
+package imagesplit
+
+import (
+    "image"
+    "math"
+)
+
+// GridTarget describes the desired outcome of an automatic grid selection.
+// At least one of TargetTiles, MaxTileWidth or MaxTileHeight must be set.
+type GridTarget struct {
+    // TargetTiles is the approximate number of tiles wanted. The chosen grid
+    // balances closeness to this count against how square the tiles are.
+    TargetTiles int
+    // MaxTileWidth and MaxTileHeight bound the size of every tile. When only
+    // one of them is set, it is used for both dimensions.
+    MaxTileWidth  int
+    MaxTileHeight int
+}
+
+// GridChoice is the grid picked by ChooseGrid together with the size of the
+// largest resulting tile.
+type GridChoice struct {
+    Rows       int
+    Cols       int
+    TileWidth  int
+    TileHeight int
+}
+
+func (t GridTarget) validate() error {
+    if t.TargetTiles < 0 || t.MaxTileWidth < 0 || t.MaxTileHeight < 0 {
+        return invalidArgf("grid target values must not be negative")
+    }
+    if t.TargetTiles == 0 && t.MaxTileWidth == 0 && t.MaxTileHeight == 0 {
+        return invalidArgf("grid target requires a tile count or a maximum tile size")
+    }
+    return nil
+}
+
+// ChooseGrid picks rows and columns for an image of the given size. With only
+// a maximum tile size, it returns the fewest tiles that respect the bound.
+// With a target count, it returns the grid whose tile count is close to the
+// target and whose tiles are as square as possible, still respecting any
+// maximum tile size.
+func ChooseGrid(width, height int, target GridTarget) (GridChoice, error) {
+    if width <= 0 || height <= 0 {
+        return GridChoice{}, invalidArgf("image dimensions must be greater than zero")
+    }
+    if err := target.validate(); err != nil {
+        return GridChoice{}, err
+    }
+
+    maxW, maxH := target.MaxTileWidth, target.MaxTileHeight
+    if maxW == 0 {
+        maxW = maxH
+    }
+    if maxH == 0 {
+        maxH = maxW
+    }
+
+    // Smallest grid that satisfies the size bound.
+    minRows, minCols := 1, 1
+    if maxH > 0 {
+        minRows = ceilDiv(height, maxH)
+    }
+    if maxW > 0 {
+        minCols = ceilDiv(width, maxW)
+    }
+
+    if target.TargetTiles == 0 {
+        return newGridChoice(width, height, minRows, minCols), nil
+    }
+
+    // A grid has at most one tile per pixel, so clamping the target there
+    // keeps the search limit from overflowing.
+    pixels := math.MaxInt / 4
+    if width <= pixels/height {
+        pixels = width * height
+    }
+    tiles := min(target.TargetTiles, pixels)
+    limit := 4 * max(tiles, min(minRows*minCols, pixels))
+
+    best := GridChoice{}
+    bestCost := math.Inf(1)
+    for rows := minRows; rows <= height && rows*minCols <= limit; rows++ {
+        for cols := minCols; cols <= width && rows*cols <= limit; cols++ {
+            cost := gridCost(width, height, rows, cols, tiles)
+            if cost < bestCost-1e-9 {
+                best = newGridChoice(width, height, rows, cols)
+                bestCost = cost
+            }
+        }
+    }
+    if bestCost == math.Inf(1) {
+        return GridChoice{}, invalidArgf("no grid of %dx%d satisfies the target", width, height)
+    }
+    return best, nil
+}
+
+// gridCost weighs the relative distance from the target count twice as much
+// as the log aspect ratio of a tile, so that a square-ish grid wins over an
+// exact count only when the count stays close.
+func gridCost(width, height, rows, cols, target int) float64 {
+    countErr := math.Abs(math.Log(float64(rows*cols) / float64(target)))
+    aspect := (float64(width) / float64(cols)) / (float64(height) / float64(rows))
+    return 2*countErr + math.Abs(math.Log(aspect))
+}
+
+func newGridChoice(width, height, rows, cols int) GridChoice {
+    return GridChoice{
+        Rows:       rows,
+        Cols:       cols,
+        TileWidth:  ceilDiv(width, cols),
+        TileHeight: ceilDiv(height, rows),
+    }
+}
+
+func ceilDiv(a, b int) int {
+    return (a + b - 1) / b
+}
+
+func autoGridSplit(inputPath string, target GridTarget, opts SplitOptions) (GridChoice, []string, error) {
+    if err := target.validate(); err != nil {
+        return GridChoice{}, nil, err
+    }
+
+    var choice GridChoice
+    files, err := runSplit(inputPath, opts, autoGridLayout(target, &choice))
+    if err != nil {
+        return GridChoice{}, nil, err
+    }
+    return choice, files, nil
+}
+
+// autoGridLayout chooses the grid once the image bounds are known and stores
+// the choice in *choice.
+func autoGridLayout(target GridTarget, choice *GridChoice) tileLayout {
+    return func(bounds image.Rectangle, opts normalizedOptions) ([]TilePlan, error) {
+        c, err := ChooseGrid(bounds.Dx(), bounds.Dy(), target)
+        if err != nil {
+            return nil, err
+        }
+        *choice = c
+        return gridLayout(c.Rows, c.Cols)(bounds, opts)
+    }
+}
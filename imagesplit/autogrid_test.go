@@ -0,0 +1,70 @@
+package imagesplit
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestChooseGrid(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		target        GridTarget
+		rows, cols    int
+	}{
+		{"square target 12", 1200, 1200, GridTarget{TargetTiles: 12}, 3, 4},
+		{"square target 9", 900, 900, GridTarget{TargetTiles: 9}, 3, 3},
+		{"wide target 12", 4000, 1000, GridTarget{TargetTiles: 12}, 2, 6},
+		{"prime target avoids strips", 1000, 1000, GridTarget{TargetTiles: 13}, 4, 4},
+		{"max tile size", 3000, 1000, GridTarget{MaxTileWidth: 1024, MaxTileHeight: 1024}, 1, 3},
+		{"max tile size single value", 2049, 1024, GridTarget{MaxTileWidth: 1024}, 1, 3},
+		{"target bounded by max size", 4000, 4000, GridTarget{TargetTiles: 4, MaxTileWidth: 1024}, 4, 4},
+		{"huge target clamped to pixels", 3, 2, GridTarget{TargetTiles: math.MaxInt}, 2, 3},
+	}
+	for _, tt := range tests {
+		got, err := ChooseGrid(tt.width, tt.height, tt.target)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if got.Rows != tt.rows || got.Cols != tt.cols {
+			t.Errorf("%s: got %dx%d, want %dx%d", tt.name, got.Rows, got.Cols, tt.rows, tt.cols)
+		}
+		if tt.target.MaxTileWidth > 0 && got.TileWidth > tt.target.MaxTileWidth {
+			t.Errorf("%s: tile width %d exceeds maximum", tt.name, got.TileWidth)
+		}
+	}
+}
+
+func TestChooseGridInvalid(t *testing.T) {
+	if _, err := ChooseGrid(100, 100, GridTarget{}); err == nil {
+		t.Fatalf("expected error for empty target")
+	}
+	if _, err := ChooseGrid(100, 100, GridTarget{TargetTiles: -1}); err == nil {
+		t.Fatalf("expected error for negative target")
+	}
+	if _, err := ChooseGrid(0, 100, GridTarget{TargetTiles: 4}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument for zero width, got %v", err)
+	}
+}
+
+func TestAutoGridSplit(t *testing.T) {
+	pngPath, _ := createSampleImages(t)
+
+	choice, files, err := AutoGridSplit(pngPath, GridTarget{MaxTileWidth: 4, MaxTileHeight: 5}, SplitOptions{OutputDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("AutoGridSplit returned error: %v", err)
+	}
+	if choice.Rows != 2 || choice.Cols != 3 {
+		t.Fatalf("expected 2x3 grid, got %dx%d", choice.Rows, choice.Cols)
+	}
+	if len(files) != 6 {
+		t.Fatalf("expected 6 tiles, got %d", len(files))
+	}
+	for _, path := range files {
+		b := decodePNGFile(t, path).Bounds()
+		if b.Dx() > 4 || b.Dy() > 5 {
+			t.Errorf("tile %s exceeds maximum size: %dx%d", path, b.Dx(), b.Dy())
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package imagesplit
+
+import (
+    "fmt"
+    "image"
+    "image/color"
+    "image/draw"
+    "strings"
+)
+
+// AlphaPolicy selects what happens to transparent pixels of tiles whose
+// output cannot store alpha: JPEG tiles and the gray and binary color modes.
+type AlphaPolicy string
+
+const (
+    // AlphaFlatten composites transparent pixels over the background, the
+    // default.
+    AlphaFlatten AlphaPolicy = "flatten"
+    // AlphaWarn flattens like AlphaFlatten and reports an EventWarning for
+    // every tile that had transparent pixels.
+    AlphaWarn AlphaPolicy = "warn"
+    // AlphaError fails the split when a tile has transparent pixels. The
+    // error matches ErrAlphaLost.
+    AlphaError AlphaPolicy = "error"
+)
+
+// Background fills the transparent areas of tiles when alpha is flattened
+// and of split previews.
+type Background struct {
+    // Color is the background color. Defaults to white.
+    Color color.Color
+    // Checkerboard alternates squares of Color and CheckerColor, the usual
+    // way of showing transparency in previews. The pattern is aligned to the
+    // source image, so it continues across tile edges.
+    Checkerboard bool
+    // CheckerColor is the second checkerboard color. Defaults to light gray.
+    CheckerColor color.Color
+    // CheckerSize is the size of a checkerboard square in pixels. Defaults
+    // to 8.
+    CheckerSize int
+}
+
+// preparedBackground is a validated Background and AlphaPolicy.
+type preparedBackground struct {
+    fill   image.Image
+    policy AlphaPolicy
+}
+
+func prepareBackground(b *Background, policy AlphaPolicy) (*preparedBackground, error) {
+    p := &preparedBackground{fill: image.White}
+    switch AlphaPolicy(strings.ToLower(strings.TrimSpace(string(policy)))) {
+    case "", AlphaFlatten:
+        p.policy = AlphaFlatten
+    case AlphaWarn:
+        p.policy = AlphaWarn
+    case AlphaError:
+        p.policy = AlphaError
+    default:
+        return nil, invalidArgf("unsupported alpha policy: %s", policy)
+    }
+    if b == nil {
+        return p, nil
+    }
+
+    c := b.Color
+    if c == nil {
+        c = color.White
+    }
+    p.fill = image.NewUniform(c)
+    if b.Checkerboard {
+        if b.CheckerSize < 0 {
+            return nil, invalidArgf("checker size must not be negative")
+        }
+        checker := checkerboard{a: c, b: b.CheckerColor, size: b.CheckerSize}
+        if checker.b == nil {
+            checker.b = color.Gray{Y: 204}
+        }
+        if checker.size == 0 {
+            checker.size = 8
+        }
+        p.fill = checker
+    }
+    return p, nil
+}
+
+// flatten composites tile, which covers the source region starting at
+// origin, over the background in place. It reports whether tile had any
+// transparent pixel.
+func (p *preparedBackground) flatten(tile *image.RGBA, origin image.Point) bool {
+    if !hasTransparency(tile) {
+        return false
+    }
+    b := tile.Bounds()
+    canvas := image.NewRGBA(b)
+    draw.Draw(canvas, b, p.fill, origin, draw.Src)
+    draw.Draw(canvas, b, tile, b.Min, draw.Over)
+    copy(tile.Pix, canvas.Pix)
+    return true
+}
+
+func hasTransparency(img *image.RGBA) bool {
+    b := img.Bounds()
+    for y := 0; y < b.Dy(); y++ {
+        row := img.Pix[img.PixOffset(b.Min.X, b.Min.Y+y):][:4*b.Dx()]
+        for i := 3; i < len(row); i += 4 {
+            if row[i] != 0xff {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// checkerboard is an infinite image of alternating squares.
+type checkerboard struct {
+    a, b color.Color
+    size int
+}
+
+func (c checkerboard) ColorModel() color.Model { return color.RGBAModel }
+
+func (c checkerboard) Bounds() image.Rectangle {
+    return image.Rect(-1e9, -1e9, 1e9, 1e9)
+}
+
+func (c checkerboard) At(x, y int) color.Color {
+    if (floorDiv(x, c.size)+floorDiv(y, c.size))%2 == 0 {
+        return c.a
+    }
+    return c.b
+}
+
+func floorDiv(a, b int) int {
+    q := a / b
+    if a%b != 0 && a < 0 {
+        q--
+    }
+    return q
+}
+
+// backgroundFingerprint identifies a background for incremental runs.
+func backgroundFingerprint(b *Background) string {
+    if b == nil {
+        return ""
+    }
+    rgba := func(c color.Color) string {
+        if c == nil {
+            return "-"
+        }
+        r, g, bl, a := c.RGBA()
+        return fmt.Sprintf("%d,%d,%d,%d", r, g, bl, a)
+    }
+    return fmt.Sprintf("%s %t %s %d", rgba(b.Color), b.Checkerboard, rgba(b.CheckerColor), b.CheckerSize)
+}
@@ -0,0 +1,156 @@
+package imagesplit
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// writeHalfTransparentPNG writes an image whose left half is opaque blue and
+// whose right half is fully transparent.
+func writeHalfTransparentPNG(t *testing.T, mem *MemFS, name string, w, h int) {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w/2; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{B: 255, A: 255})
+		}
+	}
+	writePNG(t, mem, name, img)
+}
+
+func TestJPEGTilesFlattenOntoBackground(t *testing.T) {
+	cases := []struct {
+		name       string
+		background *Background
+		want       color.RGBA
+	}{
+		{"default white", nil, color.RGBA{255, 255, 255, 255}},
+		{"custom color", &Background{Color: color.RGBA{R: 255, A: 255}}, color.RGBA{255, 0, 0, 255}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mem := NewMemFS()
+			writeHalfTransparentPNG(t, mem, "logo.png", 32, 16)
+			paths, err := GridSplit("logo.png", 1, 2, SplitOptions{
+				InputFS:    mem,
+				OutputFS:   mem,
+				Format:     "jpeg",
+				Quality:    100,
+				Background: tc.background,
+			})
+			if err != nil {
+				t.Fatalf("GridSplit returned error: %v", err)
+			}
+			got := color.RGBAModel.Convert(readImage(t, mem, paths[1]).At(8, 8)).(color.RGBA)
+			if !closeColor(got, tc.want, 8) {
+				t.Fatalf("expected %v in the transparent half, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func closeColor(a, b color.RGBA, tolerance int) bool {
+	d := func(x, y uint8) bool { return int(x)-int(y) <= tolerance && int(y)-int(x) <= tolerance }
+	return d(a.R, b.R) && d(a.G, b.G) && d(a.B, b.B)
+}
+
+func TestCheckerboardIsAlignedAcrossTiles(t *testing.T) {
+	mem := NewMemFS()
+	writePNG(t, mem, "clear.png", image.NewNRGBA(image.Rect(0, 0, 12, 4)))
+
+	paths, err := GridSplit("clear.png", 1, 2, SplitOptions{
+		InputFS:  mem,
+		OutputFS: mem,
+		Color:    &ColorOptions{Mode: ColorModeGray},
+		Background: &Background{
+			Color:        color.White,
+			Checkerboard: true,
+			CheckerColor: color.Black,
+			CheckerSize:  4,
+		},
+	})
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	left := readImage(t, mem, paths[0]).(*image.Gray)
+	right := readImage(t, mem, paths[1]).(*image.Gray)
+	// Source columns 0-3 are white, 4-7 black and 8-11 white again; the
+	// right tile starts at column 6.
+	for _, c := range []struct {
+		img  *image.Gray
+		x    int
+		want uint8
+	}{{left, 0, 255}, {left, 4, 0}, {right, 0, 0}, {right, 2, 255}} {
+		if got := c.img.GrayAt(c.x, 0).Y; got != c.want {
+			t.Errorf("expected %d at x=%d, got %d", c.want, c.x, got)
+		}
+	}
+}
+
+func TestAlphaPolicies(t *testing.T) {
+	mem := NewMemFS()
+	writeHalfTransparentPNG(t, mem, "logo.png", 16, 8)
+
+	var warnings []Event
+	_, err := GridSplit("logo.png", 1, 2, SplitOptions{
+		InputFS:     mem,
+		OutputFS:    mem,
+		OutputDir:   "warn",
+		Format:      "jpeg",
+		AlphaPolicy: AlphaWarn,
+		Observer: func(e Event) {
+			if e.Type == EventWarning {
+				warnings = append(warnings, e)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].InputPath != "logo.png" || warnings[0].Warning == "" {
+		t.Fatalf("expected one warning for the transparent tile, got %+v", warnings)
+	}
+
+	_, err = GridSplit("logo.png", 1, 2, SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "error", Format: "jpeg", AlphaPolicy: AlphaError})
+	if !errors.Is(err, ErrAlphaLost) {
+		t.Fatalf("expected ErrAlphaLost, got %v", err)
+	}
+
+	// PNG keeps the alpha channel, so nothing is lost.
+	paths, err := GridSplit("logo.png", 1, 2, SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "png", AlphaPolicy: AlphaError})
+	if err != nil {
+		t.Fatalf("GridSplit returned error for PNG output: %v", err)
+	}
+	if _, _, _, a := readImage(t, mem, paths[1]).At(0, 0).RGBA(); a != 0 {
+		t.Fatalf("expected the PNG tile to stay transparent, got alpha %d", a)
+	}
+
+	_, err = GridSplit("logo.png", 1, 1, SplitOptions{InputFS: mem, OutputFS: mem, AlphaPolicy: "ignore"})
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestPreviewUsesBackground(t *testing.T) {
+	mem := NewMemFS()
+	writeHalfTransparentPNG(t, mem, "logo.png", 32, 16)
+	plan, err := PlanGrid("logo.png", 1, 1, SplitOptions{InputFS: mem})
+	if err != nil {
+		t.Fatalf("PlanGrid returned error: %v", err)
+	}
+	preview, err := RenderPreview(plan, SplitOptions{
+		InputFS:    mem,
+		Background: &Background{Checkerboard: true, CheckerSize: 4},
+	}, PreviewOptions{Label: PreviewLabelNone})
+	if err != nil {
+		t.Fatalf("RenderPreview returned error: %v", err)
+	}
+	if a := preview.RGBAAt(28, 8).A; a != 255 {
+		t.Fatalf("expected an opaque checkerboard, got alpha %d", a)
+	}
+	if preview.RGBAAt(24, 8) == preview.RGBAAt(28, 8) {
+		t.Fatal("expected alternating checkerboard squares")
+	}
+}
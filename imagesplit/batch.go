@@ -2,9 +2,9 @@ package imagesplit
 
 import (
     "fmt"
-    "os"
     "path/filepath"
     "strings"
+    "time"
 )
 
 // DirectorySplitMode indicates how images should be split when processing a directory.
@@ -25,42 +25,235 @@ type DirectorySplitConfig struct {
     TileWidth  int
     TileHeight int
     Options    SplitOptions
+    // Layout, when it has row or column tracks, replaces Rows and Cols in grid
+    // mode with a non-uniform grid (see GridSplitLayout).
+    Layout GridLayout
+    // Overwrite controls how existing per-image output directories are
+    // treated. Defaults to OverwriteFiles.
+    Overwrite OverwritePolicy
+    // Incremental records the content hash of every source and the split
+    // configuration in a state file (StateFileName) inside outputDir. Images
+    // whose hash and configuration are unchanged and whose outputs still exist
+    // are skipped, which also lets an interrupted run resume where it stopped.
+    Incremental bool
+    // PruneStale, together with Incremental, removes the recorded outputs of
+    // sources that no longer exist in inputDir.
+    PruneStale bool
+    // Sidecars reads per-image overrides from a JSON file next to each
+    // source, named after it with SidecarSuffix appended. The file holds
+    // JobSettings; the fields it sets replace those of this configuration
+    // for that image. With Incremental, editing a sidecar re-splits its
+    // image.
+    Sidecars bool
 }
 
 // SplitDirectory walks through the input directory, splitting every supported image
 // using the provided configuration. Each image gets its own subdirectory inside
 // outputDir, named after the image file (duplicate names receive numeric/format suffixes).
+// Existing subdirectories are handled according to cfg.Overwrite, and a subdirectory
+// that would resolve to outputDir, inputDir or a parent of either is refused.
 // The function returns a map keyed by the input image path containing the generated file paths.
+// When cfg.Options.Observer is set, it receives per-image and per-tile events followed by a
+// final EventBatchFinished.
 func SplitDirectory(inputDir, outputDir string, cfg DirectorySplitConfig) (map[string][]string, error) {
+    start := time.Now()
+    progress := newProgressTracker(cfg.Options.Observer, 0)
+    results, err := splitDirectory(inputDir, outputDir, cfg, progress)
+    progress.batchFinished(start, err)
+    return results, err
+}
+
+func splitDirectory(inputDir, outputDir string, cfg DirectorySplitConfig, progress *progressTracker) (map[string][]string, error) {
+    if err := validateDirectoryArgs(inputDir, outputDir, cfg); err != nil {
+        return nil, err
+    }
+
+    out := outputFS(cfg.Options.OutputFS)
+    if err := out.MkdirAll(outputDir); err != nil {
+        return nil, outputErrorf("create output directory: %w", err)
+    }
+
+    policy, err := normalizeOverwritePolicy(cfg.Overwrite)
+    if err != nil {
+        return nil, err
+    }
+
+    jobs, err := directoryJobs(newInputSource(cfg.Options.InputFS), inputDir, outputDir)
+    if err != nil {
+        return nil, err
+    }
+    if cfg.Sidecars {
+        if err := loadSidecars(cfg, jobs); err != nil {
+            return nil, err
+        }
+    }
+
+    progress.setTotal(len(jobs))
+    return runDirectoryJobs(inputDir, outputDir, cfg, policy, jobs, cfg.PruneStale, progress)
+}
+
+// runDirectoryJobs splits the images of jobs, which must come from
+// directoryJobs for inputDir and outputDir. With prune, jobs must list every
+// image of inputDir, as the outputs of all other recorded sources are
+// removed.
+func runDirectoryJobs(inputDir, outputDir string, cfg DirectorySplitConfig, policy OverwritePolicy, jobs []directoryJob, prune bool, progress *progressTracker) (map[string][]string, error) {
+    results := make(map[string][]string)
+
+    var inc *incrementalRun
+    hashes := make(map[string]string)
+    pending := jobs
+    if cfg.Incremental {
+        var err error
+        inc, err = startIncremental(outputDir, cfg)
+        if err != nil {
+            return nil, err
+        }
+        pending = make([]directoryJob, 0, len(jobs))
+        for _, job := range jobs {
+            hash, outputs, unchanged, err := inc.lookup(job)
+            if err != nil {
+                progress.imageFailed(job.inputPath, 0, err)
+                return nil, fmt.Errorf("split image %s: %w", job.inputPath, err)
+            }
+            if unchanged {
+                results[job.inputPath] = outputs
+                progress.imageSkipped(job.inputPath)
+                continue
+            }
+            hashes[job.inputPath] = hash
+            pending = append(pending, job)
+        }
+    }
+
+    if err := checkDirectoryJobs(cfg.Options, inputDir, outputDir, pending, policy); err != nil {
+        return nil, err
+    }
+
+    for _, job := range pending {
+        var record func([]string) error
+        if inc != nil {
+            record = func(generated []string) error {
+                return inc.record(job, hashes[job.inputPath], generated)
+            }
+        }
+        generated, err := splitDirectoryImage(job, cfg, policy, progress, record)
+        if err != nil {
+            if inc != nil {
+                // Keep the progress made so far so that a rerun resumes here.
+                inc.save()
+            }
+            return nil, fmt.Errorf("split image %s: %w", job.inputPath, err)
+        }
+        results[job.inputPath] = generated
+    }
+
+    if inc != nil {
+        if prune {
+            if err := inc.prune(jobs); err != nil {
+                inc.save()
+                return nil, err
+            }
+        }
+        if err := inc.save(); err != nil {
+            return nil, err
+        }
+    }
+
+    return results, nil
+}
+
+// splitDirectoryImage splits a single image of a directory batch into its own
+// output directory, honouring the overwrite policy. record, if set, is called
+// with the outputs before the image is reported as done; its error fails the
+// image.
+func splitDirectoryImage(job directoryJob, cfg DirectorySplitConfig, policy OverwritePolicy, progress *progressTracker, record func([]string) error) ([]string, error) {
+    cfg = job.configFor(cfg)
+    opts := cfg.Options
+    opts.OutputDir = job.outputDir
+
+    switch policy {
+    case OverwriteSkip:
+        plan, err := planSplit(job.inputPath, opts, cfg.layout())
+        if err != nil {
+            progress.imageFailed(job.inputPath, 0, err)
+            return nil, err
+        }
+        if outputComplete(outputFS(opts.OutputFS), plan) {
+            if record != nil {
+                if err := record(plan.Paths()); err != nil {
+                    progress.imageFailed(job.inputPath, 0, err)
+                    return nil, err
+                }
+            }
+            progress.imageSkipped(job.inputPath)
+            return plan.Paths(), nil
+        }
+    case OverwriteClean:
+        if err := outputFS(opts.OutputFS).RemoveAll(job.outputDir); err != nil {
+            err = fmt.Errorf("remove existing output directory: %w", err)
+            progress.imageFailed(job.inputPath, 0, err)
+            return nil, err
+        }
+    }
+
+    return progress.track(job.inputPath, func() ([]string, error) {
+        generated, err := splitImage(job.inputPath, opts, cfg.layout(), progress)
+        if err == nil && record != nil {
+            err = record(generated)
+        }
+        return generated, err
+    })
+}
+
+func validateDirectoryArgs(inputDir, outputDir string, cfg DirectorySplitConfig) error {
     if strings.TrimSpace(inputDir) == "" {
-        return nil, fmt.Errorf("input directory is required")
+        return invalidArgf("input directory is required")
     }
     if strings.TrimSpace(outputDir) == "" {
-        return nil, fmt.Errorf("output directory is required")
+        return invalidArgf("output directory is required")
     }
 
     if err := validateDirectoryConfig(cfg); err != nil {
-        return nil, err
+        return err
     }
 
-    info, err := os.Stat(inputDir)
+    info, err := newInputSource(cfg.Options.InputFS).stat(inputDir)
     if err != nil {
-        return nil, fmt.Errorf("stat input directory: %w", err)
+        return fmt.Errorf("stat input directory: %w", err)
     }
     if !info.IsDir() {
-        return nil, fmt.Errorf("input path is not a directory: %s", inputDir)
+        return invalidArgf("input path is not a directory: %s", inputDir)
     }
+    return nil
+}
 
-    if err := os.MkdirAll(outputDir, 0o755); err != nil {
-        return nil, fmt.Errorf("create output directory: %w", err)
+// directoryJob pairs a source image with its per-image output directory.
+type directoryJob struct {
+    inputPath string
+    outputDir string
+    // config replaces the batch configuration when a sidecar file overrides
+    // it; sidecarHash identifies the sidecar contents for incremental runs.
+    config      *DirectorySplitConfig
+    sidecarHash string
+}
+
+// configFor returns the configuration the image of job is split with.
+func (job directoryJob) configFor(cfg DirectorySplitConfig) DirectorySplitConfig {
+    if job.config != nil {
+        return *job.config
     }
+    return cfg
+}
 
-    entries, err := os.ReadDir(inputDir)
+// directoryJobs lists the supported images in inputDir and assigns each a
+// unique subdirectory of outputDir named after the image file.
+func directoryJobs(src inputSource, inputDir, outputDir string) ([]directoryJob, error) {
+    entries, err := src.readDir(inputDir)
     if err != nil {
         return nil, fmt.Errorf("read input directory: %w", err)
     }
 
-    results := make(map[string][]string)
+    var jobs []directoryJob
     usedDirs := make(map[string]struct{})
 
     for _, entry := range entries {
@@ -79,7 +272,6 @@ func SplitDirectory(inputDir, outputDir string, cfg DirectorySplitConfig) (map[s
             continue
         }
 
-        inputPath := filepath.Join(inputDir, name)
         base := strings.TrimSuffix(name, ext)
         if base == "" {
             base = strings.TrimPrefix(name, ".")
@@ -105,51 +297,64 @@ func SplitDirectory(inputDir, outputDir string, cfg DirectorySplitConfig) (map[s
             }
         }
         usedDirs[dirName] = struct{}{}
-        subOutput := filepath.Join(outputDir, dirName)
 
-        if err := os.RemoveAll(subOutput); err != nil {
-            return nil, fmt.Errorf("remove existing output directory: %w", err)
-        }
+        jobs = append(jobs, directoryJob{
+            inputPath: src.join(inputDir, name),
+            outputDir: filepath.Join(outputDir, dirName),
+        })
+    }
 
-        opts := cfg.Options
-        opts.OutputDir = subOutput
+    return jobs, nil
+}
 
-        var generated []string
-        switch cfg.Mode {
-        case DirectorySplitModeGrid:
-            generated, err = GridSplit(inputPath, cfg.Rows, cfg.Cols, opts)
-        case DirectorySplitModeTile:
-            generated, err = TileSplit(inputPath, cfg.TileWidth, cfg.TileHeight, opts)
-        default:
-            err = fmt.Errorf("unsupported directory split mode: %s", cfg.Mode)
-        }
-        if err != nil {
-            return nil, fmt.Errorf("split image %s: %w", inputPath, err)
-        }
-        results[inputPath] = generated
+// layout returns the tile layout for a validated configuration.
+func (cfg DirectorySplitConfig) layout() tileLayout {
+    if cfg.Mode == DirectorySplitModeTile {
+        return fixedTileLayout(cfg.TileWidth, cfg.TileHeight)
     }
-
-    return results, nil
+    if cfg.Layout.hasTracks() {
+        return trackLayout(cfg.Layout)
+    }
+    return gridLayout(cfg.Rows, cfg.Cols)
 }
 
 func validateDirectoryConfig(cfg DirectorySplitConfig) error {
+    if _, err := normalizeOverwritePolicy(cfg.Overwrite); err != nil {
+        return err
+    }
+    if cfg.PruneStale && !cfg.Incremental {
+        return invalidArgf("pruning stale outputs requires incremental mode")
+    }
+    if _, ok := cfg.Options.OutputFS.(*ArchiveFS); ok && cfg.Incremental {
+        return invalidArgf("incremental mode is not supported with archive output")
+    }
+
     switch cfg.Mode {
     case DirectorySplitModeGrid:
+        if cfg.Layout.hasTracks() {
+            if len(cfg.Layout.Rows) == 0 {
+                return invalidArgf("layout requires at least one row track for grid mode")
+            }
+            if len(cfg.Layout.Cols) == 0 {
+                return invalidArgf("layout requires at least one column track for grid mode")
+            }
+            break
+        }
         if cfg.Rows <= 0 {
-            return fmt.Errorf("rows must be greater than zero for grid mode")
+            return invalidArgf("rows must be greater than zero for grid mode")
         }
         if cfg.Cols <= 0 {
-            return fmt.Errorf("cols must be greater than zero for grid mode")
+            return invalidArgf("cols must be greater than zero for grid mode")
         }
     case DirectorySplitModeTile:
         if cfg.TileWidth <= 0 {
-            return fmt.Errorf("tileWidth must be greater than zero for tile mode")
+            return invalidArgf("tileWidth must be greater than zero for tile mode")
         }
         if cfg.TileHeight <= 0 {
-            return fmt.Errorf("tileHeight must be greater than zero for tile mode")
+            return invalidArgf("tileHeight must be greater than zero for tile mode")
         }
     default:
-        return fmt.Errorf("unsupported directory split mode: %s", cfg.Mode)
+        return invalidArgf("unsupported directory split mode: %s", cfg.Mode)
     }
     return nil
 }
@@ -0,0 +1,242 @@
+package imagesplit
+
+import (
+    "image"
+    "image/color"
+    "image/draw"
+    "math"
+    "strings"
+)
+
+// ColorMode selects the color representation of the encoded tiles.
+type ColorMode string
+
+const (
+    // ColorModeRGBA keeps full color, the default.
+    ColorModeRGBA ColorMode = "rgba"
+    // ColorModeGray writes 8-bit grayscale tiles.
+    ColorModeGray ColorMode = "gray"
+    // ColorModeBinary writes black and white tiles, stored as 1-bit PNGs.
+    ColorModeBinary ColorMode = "binary"
+    // ColorModePalette writes paletted tiles with at most Colors colors.
+    ColorModePalette ColorMode = "palette"
+)
+
+// LuminanceFormula selects how grayscale values are computed from RGB.
+type LuminanceFormula string
+
+const (
+    // LuminanceBT601 weights RGB as 0.299, 0.587 and 0.114, the default.
+    LuminanceBT601 LuminanceFormula = "bt601"
+    // LuminanceBT709 weights RGB as 0.2126, 0.7152 and 0.0722.
+    LuminanceBT709 LuminanceFormula = "bt709"
+    // LuminanceAverage is the plain mean of the three channels.
+    LuminanceAverage LuminanceFormula = "average"
+)
+
+// Quantizer selects the palette construction algorithm.
+type Quantizer string
+
+const (
+    // QuantizerMedianCut repeatedly splits the color box with the widest
+    // channel range at its median, the default.
+    QuantizerMedianCut Quantizer = "median-cut"
+    // QuantizerOctree merges the least used branches of a color octree.
+    QuantizerOctree Quantizer = "octree"
+)
+
+// ColorOptions converts tiles to grayscale, black and white or a palette
+// before they are encoded.
+type ColorOptions struct {
+    // Mode selects the conversion. Defaults to ColorModeRGBA.
+    Mode ColorMode
+    // Luminance is the grayscale formula of the gray and binary modes.
+    // Defaults to LuminanceBT601.
+    Luminance LuminanceFormula
+    // Threshold is the luminance at and above which binary pixels become
+    // white. Zero picks the threshold with Otsu's method.
+    Threshold uint8
+    // Colors is the palette size of the palette mode, from 2 to 256. Zero
+    // uses 256.
+    Colors int
+    // Quantizer builds the palette. Defaults to QuantizerMedianCut.
+    Quantizer Quantizer
+    // Dither applies Floyd-Steinberg error diffusion in the palette and
+    // binary modes. Dithered binary tiles ignore Threshold.
+    Dither bool
+    // SharedPalette computes the palette, or the Otsu threshold, once from
+    // the whole image instead of per tile, so that colors match across tile
+    // edges.
+    SharedPalette bool
+}
+
+// preparedColor is a validated ColorOptions. The shared palette or
+// threshold is filled in by share once the image is decoded.
+type preparedColor struct {
+    mode      ColorMode
+    weights   [3]float64
+    threshold int
+    colors    int
+    quantizer draw.Quantizer
+    dither    bool
+    shared    bool
+
+    palette color.Palette
+}
+
+var binaryPalette = color.Palette{color.Black, color.White}
+
+func prepareColor(c *ColorOptions) (*preparedColor, error) {
+    if c == nil {
+        return nil, nil
+    }
+    p := &preparedColor{threshold: int(c.Threshold), dither: c.Dither, shared: c.SharedPalette}
+
+    switch mode := ColorMode(strings.ToLower(strings.TrimSpace(string(c.Mode)))); mode {
+    case "", ColorModeRGBA:
+        return nil, nil
+    case ColorModeGray, ColorModeBinary, ColorModePalette:
+        p.mode = mode
+    default:
+        return nil, invalidArgf("unsupported color mode: %s", c.Mode)
+    }
+
+    switch LuminanceFormula(strings.ToLower(strings.TrimSpace(string(c.Luminance)))) {
+    case "", LuminanceBT601:
+        p.weights = [3]float64{0.299, 0.587, 0.114}
+    case LuminanceBT709:
+        p.weights = [3]float64{0.2126, 0.7152, 0.0722}
+    case LuminanceAverage:
+        p.weights = [3]float64{1.0 / 3, 1.0 / 3, 1.0 / 3}
+    default:
+        return nil, invalidArgf("unsupported luminance formula: %s", c.Luminance)
+    }
+
+    p.colors = c.Colors
+    if p.colors == 0 {
+        p.colors = 256
+    }
+    if p.colors < 2 || p.colors > 256 {
+        return nil, invalidArgf("palette colors must be between 2 and 256")
+    }
+    switch Quantizer(strings.ToLower(strings.TrimSpace(string(c.Quantizer)))) {
+    case "", QuantizerMedianCut:
+        p.quantizer = medianCut{}
+    case QuantizerOctree:
+        p.quantizer = octreeQuantizer{}
+    default:
+        return nil, invalidArgf("unsupported quantizer: %s", c.Quantizer)
+    }
+    return p, nil
+}
+
+// share computes the palette or the Otsu threshold shared by every tile of
+// img when SharedPalette is set.
+func (p *preparedColor) share(img image.Image) {
+    if !p.shared {
+        return
+    }
+    switch p.mode {
+    case ColorModePalette:
+        p.palette = p.quantizer.Quantize(make(color.Palette, 0, p.colors), img)
+    case ColorModeBinary:
+        if p.threshold == 0 && !p.dither {
+            p.threshold = otsuThreshold(p.gray(img))
+        }
+    }
+}
+
+// convert returns tile in the configured color mode.
+func (p *preparedColor) convert(tile *image.RGBA) image.Image {
+    switch p.mode {
+    case ColorModeGray:
+        return p.gray(tile)
+    case ColorModeBinary:
+        gray := p.gray(tile)
+        dst := image.NewPaletted(gray.Bounds(), binaryPalette)
+        if p.dither {
+            draw.FloydSteinberg.Draw(dst, dst.Bounds(), gray, gray.Bounds().Min)
+            return dst
+        }
+        threshold := p.threshold
+        if threshold == 0 {
+            threshold = otsuThreshold(gray)
+        }
+        for i, v := range gray.Pix {
+            if int(v) >= threshold {
+                dst.Pix[i] = 1
+            }
+        }
+        return dst
+    default:
+        palette := p.palette
+        if palette == nil {
+            palette = p.quantizer.Quantize(make(color.Palette, 0, p.colors), tile)
+        }
+        dst := image.NewPaletted(tile.Bounds(), palette)
+        if p.dither {
+            draw.FloydSteinberg.Draw(dst, dst.Bounds(), tile, tile.Bounds().Min)
+        } else {
+            draw.Draw(dst, dst.Bounds(), tile, tile.Bounds().Min, draw.Src)
+        }
+        return dst
+    }
+}
+
+// gray converts img with the configured luminance weights. Tiles are
+// flattened onto SplitOptions.Background before; any remaining transparent
+// pixels are treated as composited over black.
+func (p *preparedColor) gray(img image.Image) *image.Gray {
+    b := img.Bounds()
+    dst := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+    if rgba, ok := img.(*image.RGBA); ok {
+        for y := 0; y < b.Dy(); y++ {
+            row := rgba.Pix[rgba.PixOffset(b.Min.X, b.Min.Y+y):][:4*b.Dx()]
+            for x := 0; x < b.Dx(); x++ {
+                v := p.weights[0]*float64(row[4*x]) + p.weights[1]*float64(row[4*x+1]) + p.weights[2]*float64(row[4*x+2])
+                dst.Pix[y*dst.Stride+x] = uint8(clampInt(int(math.Round(v)), 0, 255))
+            }
+        }
+        return dst
+    }
+    for y := b.Min.Y; y < b.Max.Y; y++ {
+        for x := b.Min.X; x < b.Max.X; x++ {
+            r, g, bl, _ := img.At(x, y).RGBA()
+            v := (p.weights[0]*float64(r) + p.weights[1]*float64(g) + p.weights[2]*float64(bl)) / 257
+            dst.Pix[dst.PixOffset(x-b.Min.X, y-b.Min.Y)] = uint8(clampInt(int(math.Round(v)), 0, 255))
+        }
+    }
+    return dst
+}
+
+// otsuThreshold returns the binarization threshold that maximizes the
+// between-class variance of the histogram of img, as the lowest white value.
+func otsuThreshold(img *image.Gray) int {
+    var hist [256]int
+    for _, v := range img.Pix {
+        hist[v]++
+    }
+    total, sum := 0, 0.0
+    for v, n := range hist {
+        total += n
+        sum += float64(v * n)
+    }
+
+    best, bestVariance := 128, -1.0
+    below, belowSum := 0, 0.0
+    for t := 0; t < 255; t++ {
+        below += hist[t]
+        belowSum += float64(t * hist[t])
+        above := total - below
+        if below == 0 || above == 0 {
+            continue
+        }
+        meanBelow := belowSum / float64(below)
+        meanAbove := (sum - belowSum) / float64(above)
+        variance := float64(below) * float64(above) * (meanBelow - meanAbove) * (meanBelow - meanAbove)
+        if variance > bestVariance {
+            best, bestVariance = t+1, variance
+        }
+    }
+    return best
+}
@@ -0,0 +1,168 @@
+package imagesplit
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestGrayscaleTiles(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "in.png", 8, 8, color.RGBA{R: 255, A: 255})
+
+	for formula, want := range map[LuminanceFormula]uint8{
+		LuminanceBT601:   76,
+		LuminanceBT709:   54,
+		LuminanceAverage: 85,
+	} {
+		paths, err := GridSplit("in.png", 1, 2, SplitOptions{
+			InputFS:   mem,
+			OutputFS:  mem,
+			OutputDir: string(formula),
+			Color:     &ColorOptions{Mode: ColorModeGray, Luminance: formula},
+		})
+		if err != nil {
+			t.Fatalf("%s: GridSplit returned error: %v", formula, err)
+		}
+		gray, ok := readImage(t, mem, paths[0]).(*image.Gray)
+		if !ok {
+			t.Fatalf("%s: expected a grayscale PNG", formula)
+		}
+		if got := gray.GrayAt(0, 0).Y; got != want {
+			t.Errorf("%s: expected luminance %d, got %d", formula, want, got)
+		}
+	}
+}
+
+func TestBinaryTilesWithOtsuAndThreshold(t *testing.T) {
+	img := solidImage(10, 4, color.Gray{Y: 40})
+	for y := 0; y < 4; y++ {
+		for x := 5; x < 10; x++ {
+			img.Set(x, y, color.Gray{Y: 180})
+		}
+	}
+	opts, err := prepareColor(&ColorOptions{Mode: ColorModeBinary})
+	if err != nil {
+		t.Fatalf("prepareColor returned error: %v", err)
+	}
+	out := opts.convert(img).(*image.Paletted)
+	if out.ColorIndexAt(0, 0) != 0 || out.ColorIndexAt(9, 0) != 1 {
+		t.Fatalf("Otsu did not separate the halves: %d %d", out.ColorIndexAt(0, 0), out.ColorIndexAt(9, 0))
+	}
+	if threshold := otsuThreshold(opts.gray(img)); threshold <= 40 || threshold > 180 {
+		t.Fatalf("unexpected Otsu threshold %d", threshold)
+	}
+
+	opts, err = prepareColor(&ColorOptions{Mode: ColorModeBinary, Threshold: 200})
+	if err != nil {
+		t.Fatalf("prepareColor returned error: %v", err)
+	}
+	out = opts.convert(img).(*image.Paletted)
+	if out.ColorIndexAt(9, 0) != 0 {
+		t.Fatal("expected every pixel below the threshold to be black")
+	}
+}
+
+func TestPaletteTiles(t *testing.T) {
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 255, 255}}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetRGBA(x, y, colors[(y/4)*2+x/4])
+		}
+	}
+	mem := NewMemFS()
+	writePNG(t, mem, "in.png", img)
+
+	for _, q := range []Quantizer{QuantizerMedianCut, QuantizerOctree} {
+		paths, err := GridSplit("in.png", 1, 1, SplitOptions{
+			InputFS:   mem,
+			OutputFS:  mem,
+			OutputDir: string(q),
+			Color:     &ColorOptions{Mode: ColorModePalette, Colors: 4, Quantizer: q},
+		})
+		if err != nil {
+			t.Fatalf("%s: GridSplit returned error: %v", q, err)
+		}
+		tile, ok := readImage(t, mem, paths[0]).(*image.Paletted)
+		if !ok {
+			t.Fatalf("%s: expected a paletted PNG", q)
+		}
+		if len(tile.Palette) != 4 {
+			t.Fatalf("%s: expected 4 palette entries, got %d", q, len(tile.Palette))
+		}
+		for i, c := range colors {
+			x, y := (i%2)*4, (i/2)*4
+			if got := color.RGBAModel.Convert(tile.At(x, y)); got != c {
+				t.Errorf("%s: expected %v at (%d, %d), got %v", q, c, x, y, got)
+			}
+		}
+	}
+}
+
+func TestSharedPaletteAcrossTiles(t *testing.T) {
+	mem := NewMemFS()
+	img := image.NewRGBA(image.Rect(0, 0, 32, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 32; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x * 8), uint8(y * 30), 128, 255})
+		}
+	}
+	writePNG(t, mem, "in.png", img)
+
+	paths, err := GridSplit("in.png", 1, 2, SplitOptions{
+		InputFS:  mem,
+		OutputFS: mem,
+		Color:    &ColorOptions{Mode: ColorModePalette, Colors: 8, Dither: true, SharedPalette: true},
+	})
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	left := readImage(t, mem, paths[0]).(*image.Paletted)
+	right := readImage(t, mem, paths[1]).(*image.Paletted)
+	if len(left.Palette) != 8 || len(right.Palette) != 8 {
+		t.Fatalf("expected 8 colors per tile, got %d and %d", len(left.Palette), len(right.Palette))
+	}
+	for i := range left.Palette {
+		if left.Palette[i] != right.Palette[i] {
+			t.Fatalf("palette entry %d differs: %v and %v", i, left.Palette[i], right.Palette[i])
+		}
+	}
+}
+
+func TestSharedThresholdSeesFlattenedAlpha(t *testing.T) {
+	mem := NewMemFS()
+	writeHalfTransparentPNG(t, mem, "in.png", 32, 8)
+
+	// Transparent pixels become white, so the shared Otsu threshold must
+	// separate them from the dark blue half rather than from black.
+	paths, err := GridSplit("in.png", 1, 2, SplitOptions{
+		InputFS:  mem,
+		OutputFS: mem,
+		Color:    &ColorOptions{Mode: ColorModeBinary, SharedPalette: true},
+	})
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	for i, want := range []color.Gray{{Y: 0}, {Y: 255}} {
+		tile := readImage(t, mem, paths[i])
+		if got := color.GrayModel.Convert(tile.At(3, 3)); got != want {
+			t.Errorf("tile %d: expected %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestInvalidColorOptions(t *testing.T) {
+	for name, c := range map[string]ColorOptions{
+		"mode":      {Mode: "sepia"},
+		"luminance": {Mode: ColorModeGray, Luminance: "bt2020"},
+		"colors":    {Mode: ColorModePalette, Colors: 300},
+		"one color": {Mode: ColorModePalette, Colors: 1},
+		"quantizer": {Mode: ColorModePalette, Quantizer: "kmeans"},
+	} {
+		if _, err := prepareColor(&c); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: expected ErrInvalidArgument, got %v", name, err)
+		}
+	}
+}
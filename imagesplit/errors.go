@@ -0,0 +1,69 @@
+package imagesplit
+
+import (
+    "errors"
+    "fmt"
+)
+
+var (
+    // ErrInvalidArgument is matched (with errors.Is) by errors caused by
+    // invalid split parameters, such as a non-positive grid size, an unknown
+    // output format or a layout that does not fit the image.
+    ErrInvalidArgument = errors.New("invalid argument")
+    // ErrUnsupportedFormat is matched by errors reporting that an input image
+    // was decoded but its format cannot be split. Inputs that cannot be
+    // decoded at all report image.ErrFormat instead.
+    ErrUnsupportedFormat = errors.New("unsupported image format")
+    // ErrSizeLimit is matched by errors reporting that a tile cannot be
+    // encoded within SplitOptions.SizeLimit.
+    ErrSizeLimit = errors.New("tile exceeds size limit")
+    // ErrAlphaLost is matched by errors reporting that a tile has
+    // transparent pixels its output cannot store while SplitOptions.AlphaPolicy
+    // is AlphaError.
+    ErrAlphaLost = errors.New("transparency would be lost")
+    // ErrOutput is matched by errors reporting that an output directory or
+    // file could not be created or written. The underlying error, such as a
+    // permission error, stays reachable with errors.Is and errors.As.
+    ErrOutput = errors.New("output could not be written")
+)
+
+// classifiedError keeps its message but matches one of the sentinel errors
+// above, so that callers can map failures to their own error classes.
+type classifiedError struct {
+    msg  string
+    kind error
+}
+
+func (e *classifiedError) Error() string {
+    return e.msg
+}
+
+func (e *classifiedError) Is(target error) bool {
+    return target == e.kind
+}
+
+func invalidArgf(format string, args ...any) error {
+    return &classifiedError{msg: fmt.Sprintf(format, args...), kind: ErrInvalidArgument}
+}
+
+// outputError wraps a failure to create or write output so that it matches
+// ErrOutput as well as its cause.
+type outputError struct {
+    err error
+}
+
+func (e *outputError) Error() string {
+    return e.err.Error()
+}
+
+func (e *outputError) Unwrap() error {
+    return e.err
+}
+
+func (e *outputError) Is(target error) bool {
+    return target == ErrOutput
+}
+
+func outputErrorf(format string, args ...any) error {
+    return &outputError{err: fmt.Errorf(format, args...)}
+}
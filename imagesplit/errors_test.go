@@ -0,0 +1,46 @@
+package imagesplit
+
+import (
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrorClasses(t *testing.T) {
+	pngPath, _ := createSampleImages(t)
+	outputDir := t.TempDir()
+
+	if _, err := GridSplit(pngPath, 0, 2, SplitOptions{OutputDir: outputDir}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument for zero rows, got %v", err)
+	}
+	if _, err := TileSplit(pngPath, 4, 4, SplitOptions{OutputDir: outputDir, Format: "gif"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument for unsupported output format, got %v", err)
+	}
+	if _, err := GridSplit(pngPath, 100, 1, SplitOptions{OutputDir: outputDir}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument for too many rows, got %v", err)
+	}
+	if _, err := GridSplitLayout(pngPath, GridLayout{Rows: []GridTrack{Fixed(100)}, Cols: Ratios(1)}, SplitOptions{OutputDir: outputDir}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument for oversized track, got %v", err)
+	}
+
+	garbage := filepath.Join(t.TempDir(), "garbage.png")
+	if err := os.WriteFile(garbage, []byte("not an image"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	_, err := GridSplit(garbage, 1, 1, SplitOptions{OutputDir: outputDir})
+	if !errors.Is(err, image.ErrFormat) || errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected image.ErrFormat for undecodable input, got %v", err)
+	}
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	if err := os.WriteFile(blocked, nil, 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := GridSplit(pngPath, 1, 1, SplitOptions{OutputDir: filepath.Join(blocked, "out")}); !errors.Is(err, ErrOutput) {
+		t.Errorf("expected ErrOutput for an output directory below a file, got %v", err)
+	}
+	if err := checkInputFormat("gif"); !errors.Is(err, ErrUnsupportedFormat) || err.Error() != "unsupported image format: gif" {
+		t.Errorf("unexpected error for unsupported input format: %v", err)
+	}
+}
@@ -6,46 +6,94 @@ import (
 )
 
 func gridSplit(inputPath string, rows, cols int, opts SplitOptions) ([]string, error) {
+    if err := validateGrid(rows, cols); err != nil {
+        return nil, err
+    }
+    return runSplit(inputPath, opts, gridLayout(rows, cols))
+}
+
+func gridSplitLayout(inputPath string, layout GridLayout, opts SplitOptions) ([]string, error) {
+    if err := validateGridLayout(layout); err != nil {
+        return nil, err
+    }
+    return runSplit(inputPath, opts, trackLayout(layout))
+}
+
+func validateGrid(rows, cols int) error {
     if rows <= 0 {
-        return nil, fmt.Errorf("rows must be greater than zero")
+        return invalidArgf("rows must be greater than zero")
     }
     if cols <= 0 {
-        return nil, fmt.Errorf("cols must be greater than zero")
+        return invalidArgf("cols must be greater than zero")
     }
+    return nil
+}
 
-    ctx, err := prepareSplit(inputPath, opts)
-    if err != nil {
-        return nil, err
+func validateGridLayout(layout GridLayout) error {
+    if len(layout.Rows) == 0 {
+        return invalidArgf("at least one row track is required")
+    }
+    if len(layout.Cols) == 0 {
+        return invalidArgf("at least one column track is required")
     }
+    return nil
+}
 
-    colWidths, err := distributeSize(ctx.bounds.Dx(), cols)
-    if err != nil {
-        return nil, err
+// tileLayout computes the tiles of an image with the given bounds. It is
+// shared by the split and plan code paths.
+type tileLayout func(bounds image.Rectangle, opts normalizedOptions) ([]TilePlan, error)
+
+func gridLayout(rows, cols int) tileLayout {
+    return func(bounds image.Rectangle, opts normalizedOptions) ([]TilePlan, error) {
+        colWidths, err := distributeSize(bounds.Dx(), cols)
+        if err != nil {
+            return nil, err
+        }
+        rowHeights, err := distributeSize(bounds.Dy(), rows)
+        if err != nil {
+            return nil, err
+        }
+        return gridTiles(bounds, rowHeights, colWidths, opts), nil
     }
-    rowHeights, err := distributeSize(ctx.bounds.Dy(), rows)
-    if err != nil {
-        return nil, err
+}
+
+func trackLayout(layout GridLayout) tileLayout {
+    return func(bounds image.Rectangle, opts normalizedOptions) ([]TilePlan, error) {
+        colWidths, err := resolveTracks(bounds.Dx(), layout.Cols)
+        if err != nil {
+            return nil, fmt.Errorf("columns: %w", err)
+        }
+        rowHeights, err := resolveTracks(bounds.Dy(), layout.Rows)
+        if err != nil {
+            return nil, fmt.Errorf("rows: %w", err)
+        }
+        return gridTiles(bounds, rowHeights, colWidths, opts), nil
     }
+}
 
-    result := make([]string, 0, rows*cols)
+func gridTiles(bounds image.Rectangle, rowHeights, colWidths []int, opts normalizedOptions) []TilePlan {
+    rows, cols := len(rowHeights), len(colWidths)
+    tiles := make([]TilePlan, 0, rows*cols)
 
-    y := ctx.bounds.Min.Y
+    y := bounds.Min.Y
     for r := 0; r < rows; r++ {
         h := rowHeights[r]
-        x := ctx.bounds.Min.X
+        x := bounds.Min.X
         for c := 0; c < cols; c++ {
             w := colWidths[c]
-            rect := image.Rect(x, y, x+w, y+h)
-            name := fmt.Sprintf("%s_row%d_col%d", ctx.options.prefix, r, c)
-            output, err := saveTile(ctx.img, rect, ctx.options, name)
-            if err != nil {
-                return nil, err
-            }
-            result = append(result, output)
+            name := fmt.Sprintf("%s_row%d_col%d", opts.prefix, r, c)
+            tiles = append(tiles, TilePlan{
+                Name:  name,
+                Path:  tilePath(opts, name),
+                Rect:  image.Rect(x, y, x+w, y+h),
+                Row:   r,
+                Col:   c,
+                Index: r*cols + c,
+            })
             x += w
         }
         y += h
     }
 
-    return result, nil
+    return tiles
 }
@@ -0,0 +1,409 @@
+// Package httpsplit exposes image splitting as an HTTP API.
+//
+// A request POSTs an image, either as the "image" field of a multipart form
+// or as the raw request body, and passes the split parameters as query or
+// form fields:
+//
+//	mode     grid (default) or tile
+//	rows     number of rows in grid mode
+//	cols     number of columns in grid mode
+//	width    tile width in tile mode
+//	height   tile height in tile mode
+//	format   png or jpeg (default: input format)
+//	quality  JPEG quality, 1-100
+//	prefix   file name prefix of the tiles
+//	output   zip (default) or json
+//
+// The response is a ZIP archive of the tiles or a JSON manifest with the
+// tiles embedded as base64. Errors are returned as JSON {"error": "..."}
+// with a 4xx status for invalid requests.
+package httpsplit
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "image"
+    "io"
+    "mime"
+    "net/http"
+    "path"
+    "runtime"
+    "strconv"
+    "strings"
+
+    "github.com/zsq2010/utils/imagesplit"
+)
+
+// Default limits used for zero Config fields.
+const (
+    DefaultMaxBodyBytes = 32 << 20
+    DefaultMaxPixels    = 50_000_000
+    DefaultMaxTiles     = 1024
+)
+
+// Config limits the work a single request may cause.
+type Config struct {
+    // MaxBodyBytes limits the size of the request body.
+    MaxBodyBytes int64
+    // MaxPixels limits width*height of the uploaded image. It is checked from
+    // the image header before any pixels are decoded.
+    MaxPixels int64
+    // MaxTiles limits the number of tiles a request may produce.
+    MaxTiles int
+    // MaxConcurrent limits the number of splits running at the same time.
+    // Further requests wait for a slot until their context is done. Defaults
+    // to runtime.NumCPU().
+    MaxConcurrent int
+}
+
+// Handler serves split requests.
+type Handler struct {
+    cfg   Config
+    slots chan struct{}
+}
+
+// NewHandler returns a Handler using cfg, with defaults for zero fields.
+func NewHandler(cfg Config) *Handler {
+    if cfg.MaxBodyBytes <= 0 {
+        cfg.MaxBodyBytes = DefaultMaxBodyBytes
+    }
+    if cfg.MaxPixels <= 0 {
+        cfg.MaxPixels = DefaultMaxPixels
+    }
+    if cfg.MaxTiles <= 0 {
+        cfg.MaxTiles = DefaultMaxTiles
+    }
+    if cfg.MaxConcurrent <= 0 {
+        cfg.MaxConcurrent = runtime.NumCPU()
+    }
+    return &Handler{cfg: cfg, slots: make(chan struct{}, cfg.MaxConcurrent)}
+}
+
+// requestError carries the HTTP status of a failed request.
+type requestError struct {
+    status int
+    err    error
+}
+
+func (e *requestError) Error() string {
+    return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+    return e.err
+}
+
+func badRequestf(format string, args ...any) error {
+    return &requestError{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.Header().Set("Allow", http.MethodPost)
+        writeError(w, &requestError{status: http.StatusMethodNotAllowed, err: errors.New("method not allowed")})
+        return
+    }
+    r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxBodyBytes)
+
+    req, err := h.parseRequest(r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+
+    if err := h.acquire(r.Context()); err != nil {
+        writeError(w, err)
+        return
+    }
+    defer h.release()
+
+    result, err := h.split(req)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    if req.output == "json" {
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(result.manifest)
+        return
+    }
+    w.Header().Set("Content-Type", "application/zip")
+    w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": req.name + ".zip"}))
+    w.Header().Set("Content-Length", strconv.Itoa(len(result.zip)))
+    w.Write(result.zip)
+}
+
+func (h *Handler) acquire(ctx context.Context) error {
+    select {
+    case h.slots <- struct{}{}:
+        return nil
+    case <-ctx.Done():
+        return &requestError{status: http.StatusServiceUnavailable, err: errors.New("server busy")}
+    }
+}
+
+func (h *Handler) release() {
+    <-h.slots
+}
+
+// splitRequest is a parsed and validated request.
+type splitRequest struct {
+    data   []byte
+    name   string
+    config image.Config
+    format string
+    mode   string
+    rows   int
+    cols   int
+    width  int
+    height int
+    output string
+    opts   imagesplit.SplitOptions
+}
+
+func (h *Handler) parseRequest(r *http.Request) (*splitRequest, error) {
+    req := &splitRequest{name: "image"}
+
+    var err error
+    mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    switch mediaType {
+    case "multipart/form-data":
+        err = h.readMultipart(r, req)
+    case "application/x-www-form-urlencoded":
+        err = badRequestf("expected a multipart form or a raw image body")
+    default:
+        req.data, err = io.ReadAll(r.Body)
+    }
+    if err != nil {
+        return nil, bodyError(err)
+    }
+    if len(req.data) == 0 {
+        return nil, badRequestf("image is required")
+    }
+
+    req.mode = strings.ToLower(r.FormValue("mode"))
+    if req.mode == "" {
+        req.mode = "grid"
+    }
+    ints := map[string]*int{"rows": &req.rows, "cols": &req.cols, "width": &req.width, "height": &req.height, "quality": &req.opts.Quality}
+    for field, target := range ints {
+        value := r.FormValue(field)
+        if value == "" {
+            continue
+        }
+        if *target, err = strconv.Atoi(value); err != nil {
+            return nil, badRequestf("%s must be an integer", field)
+        }
+    }
+    req.opts.Format = r.FormValue("format")
+    req.opts.FilePrefix = r.FormValue("prefix")
+    req.output = strings.ToLower(r.FormValue("output"))
+    if req.output == "" {
+        req.output = "zip"
+    }
+    if req.output != "zip" && req.output != "json" {
+        return nil, badRequestf("unsupported output: %s", req.output)
+    }
+
+    tiles, err := req.tileCount(h.cfg.MaxTiles)
+    if err != nil {
+        return nil, err
+    }
+
+    req.config, req.format, err = image.DecodeConfig(bytes.NewReader(req.data))
+    if err != nil {
+        return nil, &requestError{status: http.StatusUnsupportedMediaType, err: fmt.Errorf("decode image: %w", err)}
+    }
+    if pixels := int64(req.config.Width) * int64(req.config.Height); pixels > h.cfg.MaxPixels {
+        return nil, &requestError{
+            status: http.StatusRequestEntityTooLarge,
+            err:    fmt.Errorf("image has %d pixels, the limit is %d", pixels, h.cfg.MaxPixels),
+        }
+    }
+    if req.mode == "tile" {
+        tiles = ceilDiv(req.config.Width, req.width) * ceilDiv(req.config.Height, req.height)
+    }
+    if tiles > h.cfg.MaxTiles {
+        return nil, badRequestf("split would produce %d tiles, the limit is %d", tiles, h.cfg.MaxTiles)
+    }
+    return req, nil
+}
+
+// readMultipart reads the "image" file field. The remaining form fields are
+// available through r.FormValue afterwards.
+func (h *Handler) readMultipart(r *http.Request, req *splitRequest) error {
+    if err := r.ParseMultipartForm(h.cfg.MaxBodyBytes); err != nil {
+        return err
+    }
+    file, header, err := r.FormFile("image")
+    if err != nil {
+        return badRequestf("multipart field \"image\" is required")
+    }
+    defer file.Close()
+
+    if req.data, err = io.ReadAll(file); err != nil {
+        return err
+    }
+    if name := strings.TrimSuffix(path.Base(header.Filename), path.Ext(header.Filename)); name != "" && name != "." && name != "/" {
+        req.name = name
+    }
+    return nil
+}
+
+// tileCount returns the number of tiles of a grid request; tile requests
+// need the image size, which is applied by the caller. Every dimension is
+// checked on its own before multiplying, so that huge values cannot overflow
+// past maxTiles.
+func (req *splitRequest) tileCount(maxTiles int) (int, error) {
+    switch req.mode {
+    case "grid":
+        if req.rows <= 0 || req.cols <= 0 {
+            return 0, badRequestf("rows and cols must be positive")
+        }
+        if req.rows > maxTiles || req.cols > maxTiles/req.rows {
+            return 0, badRequestf("split would produce more than %d tiles", maxTiles)
+        }
+        return req.rows * req.cols, nil
+    case "tile":
+        if req.width <= 0 || req.height <= 0 {
+            return 0, badRequestf("width and height must be positive")
+        }
+        return 0, nil
+    default:
+        return 0, badRequestf("unsupported mode: %s", req.mode)
+    }
+}
+
+func bodyError(err error) error {
+    var tooLarge *http.MaxBytesError
+    if errors.As(err, &tooLarge) {
+        return &requestError{status: http.StatusRequestEntityTooLarge, err: fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit)}
+    }
+    var reqErr *requestError
+    if errors.As(err, &reqErr) {
+        return err
+    }
+    return badRequestf("read request: %v", err)
+}
+
+// Manifest is the JSON response of a split request.
+type Manifest struct {
+    Width  int            `json:"width"`
+    Height int            `json:"height"`
+    Format string         `json:"format"`
+    Tiles  []ManifestTile `json:"tiles"`
+}
+
+// ManifestTile is a tile of a Manifest. Data holds the encoded tile and is
+// serialized as base64.
+type ManifestTile struct {
+    imagesplit.TilePlan
+    Data []byte `json:"data"`
+}
+
+type splitResult struct {
+    manifest *Manifest
+    zip      []byte
+}
+
+// split runs the split in memory and builds the response body.
+func (h *Handler) split(req *splitRequest) (*splitResult, error) {
+    mem := imagesplit.NewMemFS()
+    inputPath := "input/" + req.name + "." + req.format
+    if err := mem.WriteFile(inputPath, req.data); err != nil {
+        return nil, err
+    }
+    opts := req.opts
+    opts.InputFS = mem
+    opts.OutputFS = mem
+    opts.OutputDir = "tiles"
+
+    if req.output == "json" {
+        // The plan uses the same layout as the split and adds the position
+        // of every tile to the manifest.
+        var plan *imagesplit.SplitPlan
+        var err error
+        if req.mode == "tile" {
+            plan, err = imagesplit.PlanTile(inputPath, req.width, req.height, opts)
+        } else {
+            plan, err = imagesplit.PlanGrid(inputPath, req.rows, req.cols, opts)
+        }
+        if err == nil {
+            err = runSplit(req, inputPath, opts)
+        }
+        if err != nil {
+            return nil, splitError(err)
+        }
+        manifest := &Manifest{Width: plan.Width, Height: plan.Height, Format: plan.Format}
+        for _, tile := range plan.Tiles {
+            data, err := mem.ReadFile(tile.Path)
+            if err != nil {
+                return nil, err
+            }
+            tile.Path = path.Base(tile.Path)
+            manifest.Tiles = append(manifest.Tiles, ManifestTile{TilePlan: tile, Data: data})
+        }
+        return &splitResult{manifest: manifest}, nil
+    }
+
+    var buf bytes.Buffer
+    archive, err := imagesplit.NewArchiveFS(&buf, imagesplit.ArchiveZip)
+    if err != nil {
+        return nil, err
+    }
+    opts.OutputFS = archive
+    opts.OutputDir = "."
+    if err := runSplit(req, inputPath, opts); err != nil {
+        archive.Close()
+        return nil, splitError(err)
+    }
+    if err := archive.Close(); err != nil {
+        return nil, err
+    }
+    return &splitResult{zip: buf.Bytes()}, nil
+}
+
+func runSplit(req *splitRequest, inputPath string, opts imagesplit.SplitOptions) error {
+    var err error
+    if req.mode == "tile" {
+        _, err = imagesplit.TileSplit(inputPath, req.width, req.height, opts)
+    } else {
+        _, err = imagesplit.GridSplit(inputPath, req.rows, req.cols, opts)
+    }
+    return err
+}
+
+// splitError maps imagesplit errors to HTTP statuses.
+func splitError(err error) error {
+    switch {
+    case errors.Is(err, imagesplit.ErrInvalidArgument):
+        return &requestError{status: http.StatusBadRequest, err: err}
+    case errors.Is(err, imagesplit.ErrUnsupportedFormat), errors.Is(err, image.ErrFormat):
+        return &requestError{status: http.StatusUnsupportedMediaType, err: err}
+    default:
+        return err
+    }
+}
+
+func writeError(w http.ResponseWriter, err error) {
+    status := http.StatusInternalServerError
+    var reqErr *requestError
+    if errors.As(err, &reqErr) {
+        status = reqErr.status
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// ceilDiv divides a by b rounding up, without overflowing for huge b.
+func ceilDiv(a, b int) int {
+    if a <= 0 {
+        return 0
+    }
+    return (a-1)/b + 1
+}
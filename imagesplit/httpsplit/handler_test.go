@@ -0,0 +1,176 @@
+package httpsplit
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/png"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	testdata "github.com/zsq2010/utils/imagesplit/testdata"
+)
+
+func gradient(t *testing.T) []byte {
+	t.Helper()
+	data, err := testdata.GradientPNG()
+	if err != nil {
+		t.Fatalf("gradient png: %v", err)
+	}
+	return data
+}
+
+func postRaw(h http.Handler, query string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/split?"+query, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "image/png")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRawBodyZip(t *testing.T) {
+	rec := postRaw(NewHandler(Config{}), "rows=2&cols=2", gradient(t))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if len(names) != 4 || names[0] != "image_row0_col0.png" {
+		t.Fatalf("unexpected zip entries %v", names)
+	}
+}
+
+func TestMultipartJSON(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("image", "photo.png")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	fw.Write(gradient(t))
+	mw.WriteField("mode", "tile")
+	mw.WriteField("width", "6")
+	mw.WriteField("height", "10")
+	mw.WriteField("output", "json")
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/split", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	NewHandler(Config{}).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(rec.Body.Bytes(), &manifest); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if manifest.Width != 10 || manifest.Height != 10 || len(manifest.Tiles) != 2 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+	tile := manifest.Tiles[1]
+	if tile.Name != "photo_tile_1" || tile.Path != "photo_tile_1.png" || tile.Rect.Dx() != 4 {
+		t.Fatalf("unexpected tile %+v", tile.TilePlan)
+	}
+	img, err := png.Decode(bytes.NewReader(tile.Data))
+	if err != nil {
+		t.Fatalf("decode tile: %v", err)
+	}
+	if img.Bounds().Dx() != 4 || img.Bounds().Dy() != 10 {
+		t.Errorf("unexpected tile size %v", img.Bounds())
+	}
+}
+
+func TestErrorStatuses(t *testing.T) {
+	data := gradient(t)
+	cases := []struct {
+		name  string
+		cfg   Config
+		query string
+		body  []byte
+		want  int
+	}{
+		{"zero rows", Config{}, "rows=0&cols=2", data, http.StatusBadRequest},
+		{"too many rows", Config{}, "rows=20&cols=1", data, http.StatusBadRequest},
+		{"bad integer", Config{}, "rows=x&cols=1", data, http.StatusBadRequest},
+		{"bad mode", Config{}, "mode=spiral", data, http.StatusBadRequest},
+		{"bad format", Config{}, "rows=1&cols=1&format=gif", data, http.StatusBadRequest},
+		{"empty body", Config{}, "rows=1&cols=1", nil, http.StatusBadRequest},
+		{"not an image", Config{}, "rows=1&cols=1", []byte("hello"), http.StatusUnsupportedMediaType},
+		{"body limit", Config{MaxBodyBytes: 16}, "rows=1&cols=1", data, http.StatusRequestEntityTooLarge},
+		{"pixel limit", Config{MaxPixels: 99}, "rows=1&cols=1", data, http.StatusRequestEntityTooLarge},
+		{"tile limit", Config{MaxTiles: 3}, "mode=tile&width=5&height=5", data, http.StatusBadRequest},
+		{"overflowing grid", Config{}, "rows=4294967296&cols=4294967296", data, http.StatusBadRequest},
+		{"huge rows", Config{}, "rows=9223372036854775807&cols=2", data, http.StatusBadRequest},
+		{"negative grid", Config{}, "rows=-4&cols=-4", data, http.StatusBadRequest},
+		{"negative width", Config{}, "mode=tile&width=-5&height=5", data, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := postRaw(NewHandler(tc.cfg), tc.query, tc.body)
+			if rec.Code != tc.want {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tc.want, rec.Body)
+			}
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error == "" {
+				t.Fatalf("expected JSON error body, got %q", rec.Body)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	NewHandler(Config{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/split", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestCeilDivDoesNotOverflow(t *testing.T) {
+	for _, tc := range []struct{ a, b, want int }{
+		{10, 3, 4},
+		{9, 3, 3},
+		{0, 3, 0},
+		{10, math.MaxInt, 1},
+		{math.MaxInt, 2, math.MaxInt/2 + 1},
+	} {
+		if got := ceilDiv(tc.a, tc.b); got != tc.want {
+			t.Errorf("ceilDiv(%d, %d) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	h := NewHandler(Config{MaxConcurrent: 1})
+	h.slots <- struct{}{} // occupy the only slot
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodPost, "/split?rows=1&cols=1", bytes.NewReader(gradient(t))).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	h.release()
+	if rec := postRaw(h, "rows=1&cols=1", gradient(t)); rec.Code != http.StatusOK {
+		t.Fatalf("status %d after release: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,251 @@
+package imagesplit
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "path/filepath"
+    "time"
+)
+
+// StateFileName is the name of the state file SplitDirectory keeps in
+// outputDir when DirectorySplitConfig.Incremental is set.
+const StateFileName = ".imagesplit-state.json"
+
+const (
+    stateVersion = 1
+    // stateFlushInterval bounds how much progress an interrupted run can lose
+    // without rewriting the state file after every image.
+    stateFlushInterval = time.Second
+)
+
+type batchState struct {
+    Version int `json:"version"`
+    // Config is a fingerprint of the split configuration. Entries recorded
+    // under a different fingerprint are treated as stale.
+    Config  string                 `json:"config"`
+    Sources map[string]sourceState `json:"sources"`
+}
+
+type sourceState struct {
+    // Hash is the SHA-256 of the source file contents, followed by that of
+    // its sidecar file when DirectorySplitConfig.Sidecars found one.
+    Hash string `json:"hash"`
+    // Outputs lists the generated files relative to outputDir.
+    Outputs []string `json:"outputs"`
+}
+
+type incrementalRun struct {
+    src         inputSource
+    out         WritableFS
+    outputDir   string
+    path        string
+    fingerprint string
+    state       batchState
+    lastSave    time.Time
+}
+
+func startIncremental(outputDir string, cfg DirectorySplitConfig) (*incrementalRun, error) {
+    fingerprint, err := configFingerprint(cfg)
+    if err != nil {
+        return nil, err
+    }
+
+    run := &incrementalRun{
+        src:         newInputSource(cfg.Options.InputFS),
+        out:         outputFS(cfg.Options.OutputFS),
+        outputDir:   outputDir,
+        path:        filepath.Join(outputDir, StateFileName),
+        fingerprint: fingerprint,
+        state:       batchState{Version: stateVersion, Sources: map[string]sourceState{}},
+        lastSave:    time.Now(),
+    }
+
+    data, err := run.out.ReadFile(run.path)
+    if isNotExist(err) {
+        return run, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("read state file: %w", err)
+    }
+
+    var state batchState
+    if err := json.Unmarshal(data, &state); err != nil {
+        return nil, fmt.Errorf("parse state file %s: %w", run.path, err)
+    }
+    if state.Version == stateVersion && state.Sources != nil {
+        run.state = state
+    }
+    return run, nil
+}
+
+// lookup hashes the source of job and reports whether its recorded outputs
+// are still valid. The hash is returned so that it can be recorded later.
+func (r *incrementalRun) lookup(job directoryJob) (string, []string, bool, error) {
+    hash, err := hashFile(r.src, job.inputPath)
+    if err != nil {
+        return "", nil, false, err
+    }
+    if job.sidecarHash != "" {
+        hash += "+" + job.sidecarHash
+    }
+
+    entry, ok := r.state.Sources[filepath.Base(job.inputPath)]
+    if !ok || r.state.Config != r.fingerprint || entry.Hash != hash || len(entry.Outputs) == 0 {
+        return hash, nil, false, nil
+    }
+
+    outputs := make([]string, len(entry.Outputs))
+    for i, rel := range entry.Outputs {
+        outputs[i] = filepath.Join(r.outputDir, rel)
+        if _, err := r.out.Stat(outputs[i]); err != nil {
+            return hash, nil, false, nil
+        }
+    }
+    return hash, outputs, true, nil
+}
+
+// record stores the outputs of a finished image and periodically persists the
+// state so that an interrupted run can resume.
+func (r *incrementalRun) record(job directoryJob, hash string, outputs []string) error {
+    rel := make([]string, 0, len(outputs))
+    for _, output := range outputs {
+        p, err := filepath.Rel(r.outputDir, output)
+        if err != nil {
+            return fmt.Errorf("record output %s: %w", output, err)
+        }
+        rel = append(rel, p)
+    }
+
+    if r.state.Config != r.fingerprint {
+        // The first image finished under a new configuration invalidates every
+        // entry recorded under the old one.
+        for name, entry := range r.state.Sources {
+            entry.Hash = ""
+            r.state.Sources[name] = entry
+        }
+        r.state.Config = r.fingerprint
+    }
+    r.state.Sources[filepath.Base(job.inputPath)] = sourceState{Hash: hash, Outputs: rel}
+
+    if time.Since(r.lastSave) < stateFlushInterval {
+        return nil
+    }
+    return r.save()
+}
+
+// prune removes the recorded outputs of sources that are no longer part of
+// the batch. Only files written by earlier runs are deleted; their directory
+// is removed only if it ends up empty.
+func (r *incrementalRun) prune(jobs []directoryJob) error {
+    current := make(map[string]struct{}, len(jobs))
+    for _, job := range jobs {
+        current[filepath.Base(job.inputPath)] = struct{}{}
+    }
+
+    for name, entry := range r.state.Sources {
+        if _, ok := current[name]; ok {
+            continue
+        }
+        dirs := map[string]struct{}{}
+        for _, rel := range entry.Outputs {
+            path := filepath.Join(r.outputDir, rel)
+            if err := r.out.Remove(path); err != nil && !isNotExist(err) {
+                return fmt.Errorf("prune stale output: %w", err)
+            }
+            dirs[filepath.Dir(path)] = struct{}{}
+        }
+        for dir := range dirs {
+            if dir != filepath.Clean(r.outputDir) {
+                r.out.Remove(dir)
+            }
+        }
+        delete(r.state.Sources, name)
+    }
+    return nil
+}
+
+// save writes the state file atomically.
+func (r *incrementalRun) save() error {
+    data, err := json.MarshalIndent(r.state, "", "  ")
+    if err != nil {
+        return fmt.Errorf("encode state file: %w", err)
+    }
+
+    file, err := r.out.Create(r.path)
+    if err != nil {
+        return fmt.Errorf("create state file: %w", err)
+    }
+    if _, err := file.Write(data); err != nil {
+        file.Abort()
+        return fmt.Errorf("write state file: %w", err)
+    }
+    if err := file.Commit(); err != nil {
+        return fmt.Errorf("write state file: %w", err)
+    }
+
+    r.lastSave = time.Now()
+    return nil
+}
+
+// configFingerprint hashes every configuration field that influences the
+// generated tiles. OutputDir is excluded because SplitDirectory sets it per
+// image.
+func configFingerprint(cfg DirectorySplitConfig) (string, error) {
+    data, err := json.Marshal(struct {
+        Mode        DirectorySplitMode
+        Rows        int
+        Cols        int
+        TileWidth   int
+        TileHeight  int
+        Layout      GridLayout
+        FilePrefix  string
+        Format      string
+        Quality     int
+        Watermark   string
+        Transforms  []Transform
+        Color       *ColorOptions
+        SizeLimit   *SizeLimit
+        Background  string
+        AlphaPolicy AlphaPolicy
+        Select      *TileSelection
+    }{
+        Mode:        cfg.Mode,
+        Rows:        cfg.Rows,
+        Cols:        cfg.Cols,
+        TileWidth:   cfg.TileWidth,
+        TileHeight:  cfg.TileHeight,
+        Layout:      cfg.Layout,
+        FilePrefix:  cfg.Options.FilePrefix,
+        Format:      cfg.Options.Format,
+        Quality:     cfg.Options.Quality,
+        Watermark:   watermarkFingerprint(cfg.Options.Watermark),
+        Transforms:  cfg.Options.Transforms,
+        Color:       cfg.Options.Color,
+        SizeLimit:   cfg.Options.SizeLimit,
+        Background:  backgroundFingerprint(cfg.Options.Background),
+        AlphaPolicy: cfg.Options.AlphaPolicy,
+        Select:      cfg.Options.Select,
+    })
+    if err != nil {
+        return "", fmt.Errorf("fingerprint configuration: %w", err)
+    }
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:]), nil
+}
+
+func hashFile(src inputSource, path string) (string, error) {
+    f, err := src.open(path)
+    if err != nil {
+        return "", fmt.Errorf("open image: %w", err)
+    }
+    defer f.Close()
+
+    h := sha256.New()
+    if _, err := io.Copy(h, f); err != nil {
+        return "", fmt.Errorf("hash image: %w", err)
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
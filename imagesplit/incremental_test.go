@@ -0,0 +1,147 @@
+package imagesplit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	testdata "github.com/zsq2010/utils/imagesplit/testdata"
+)
+
+func incrementalConfig() DirectorySplitConfig {
+	return DirectorySplitConfig{Mode: DirectorySplitModeGrid, Rows: 2, Cols: 2, Incremental: true}
+}
+
+func markOutput(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("marker"), 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+}
+
+func isMarked(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && string(data) == "marker"
+}
+
+func TestSplitDirectoryIncrementalSkipsUnchanged(t *testing.T) {
+	inputDir := t.TempDir()
+	outDir := t.TempDir()
+	if err := testdata.WriteGradientPNG(filepath.Join(inputDir, "a.png")); err != nil {
+		t.Fatalf("write gradient png: %v", err)
+	}
+	if err := testdata.WriteGradientPNG(filepath.Join(inputDir, "b.png")); err != nil {
+		t.Fatalf("write gradient png: %v", err)
+	}
+
+	if _, err := SplitDirectory(inputDir, outDir, incrementalConfig()); err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, StateFileName)); err != nil {
+		t.Fatalf("expected state file: %v", err)
+	}
+
+	tileA := filepath.Join(outDir, "a", "a_row0_col0.png")
+	tileB := filepath.Join(outDir, "b", "b_row0_col0.png")
+	markOutput(t, tileA)
+	markOutput(t, tileB)
+
+	// Change b only.
+	data, err := os.ReadFile(writePanoramaPNG(t, 12, 12))
+	if err != nil {
+		t.Fatalf("read replacement: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "b.png"), data, 0o644); err != nil {
+		t.Fatalf("rewrite b: %v", err)
+	}
+
+	results, err := SplitDirectory(inputDir, outDir, incrementalConfig())
+	if err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if len(results) != 2 || len(results[filepath.Join(inputDir, "a.png")]) != 4 {
+		t.Fatalf("expected results for both images, got %v", results)
+	}
+	if !isMarked(tileA) {
+		t.Errorf("expected unchanged image a to be skipped")
+	}
+	if isMarked(tileB) {
+		t.Errorf("expected changed image b to be split again")
+	}
+
+	cfg := incrementalConfig()
+	cfg.Options.Format = "jpeg"
+	if _, err := SplitDirectory(inputDir, outDir, cfg); err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "a", "a_row0_col0.jpg")); err != nil {
+		t.Errorf("expected configuration change to reprocess a: %v", err)
+	}
+}
+
+func TestSplitDirectoryIncrementalResumes(t *testing.T) {
+	inputDir := t.TempDir()
+	outDir := t.TempDir()
+	if err := testdata.WriteGradientPNG(filepath.Join(inputDir, "a.png")); err != nil {
+		t.Fatalf("write gradient png: %v", err)
+	}
+	broken := filepath.Join(inputDir, "b.png")
+	if err := os.WriteFile(broken, []byte("not an image"), 0o644); err != nil {
+		t.Fatalf("write broken image: %v", err)
+	}
+
+	if _, err := SplitDirectory(inputDir, outDir, incrementalConfig()); err == nil {
+		t.Fatalf("expected error for broken image")
+	}
+
+	tileA := filepath.Join(outDir, "a", "a_row0_col0.png")
+	markOutput(t, tileA)
+	if err := testdata.WriteGradientPNG(broken); err != nil {
+		t.Fatalf("fix broken image: %v", err)
+	}
+
+	if _, err := SplitDirectory(inputDir, outDir, incrementalConfig()); err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if !isMarked(tileA) {
+		t.Errorf("expected image finished before the failure to be skipped")
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "b", "b_row1_col1.png")); err != nil {
+		t.Errorf("expected resumed run to process b: %v", err)
+	}
+}
+
+func TestSplitDirectoryIncrementalPrune(t *testing.T) {
+	inputDir := t.TempDir()
+	outDir := t.TempDir()
+	source := filepath.Join(inputDir, "gone.png")
+	if err := testdata.WriteGradientPNG(source); err != nil {
+		t.Fatalf("write gradient png: %v", err)
+	}
+	if _, err := SplitDirectory(inputDir, outDir, incrementalConfig()); err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if err := os.Remove(source); err != nil {
+		t.Fatalf("remove source: %v", err)
+	}
+
+	if _, err := SplitDirectory(inputDir, outDir, incrementalConfig()); err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "gone")); err != nil {
+		t.Fatalf("expected outputs to be kept without PruneStale: %v", err)
+	}
+
+	cfg := incrementalConfig()
+	cfg.PruneStale = true
+	if _, err := SplitDirectory(inputDir, outDir, cfg); err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "gone")); !os.IsNotExist(err) {
+		t.Fatalf("expected stale outputs to be pruned")
+	}
+
+	if _, err := SplitDirectory(inputDir, outDir, DirectorySplitConfig{Mode: DirectorySplitModeGrid, Rows: 1, Cols: 1, PruneStale: true}); err == nil {
+		t.Fatalf("expected error for PruneStale without Incremental")
+	}
+}
@@ -0,0 +1,580 @@
+package imagesplit
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "image"
+    "image/color"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+
+    "gopkg.in/ini.v1"
+)
+
+// JobSettings is the serializable part of a DirectorySplitConfig. It is
+// shared by job files and sidecar files; zero fields leave the configuration
+// they are applied to unchanged.
+type JobSettings struct {
+    Mode       DirectorySplitMode `json:"mode,omitempty"`
+    Rows       int                `json:"rows,omitempty"`
+    Cols       int                `json:"cols,omitempty"`
+    Layout     *GridLayout        `json:"layout,omitempty"`
+    TileWidth  int                `json:"tileWidth,omitempty"`
+    TileHeight int                `json:"tileHeight,omitempty"`
+    FilePrefix string             `json:"prefix,omitempty"`
+    Format     string             `json:"format,omitempty"`
+    Quality    int                `json:"quality,omitempty"`
+    Transforms []Transform        `json:"transforms,omitempty"`
+    Color      *ColorOptions      `json:"color,omitempty"`
+    SizeLimit  *SizeLimit         `json:"sizeLimit,omitempty"`
+    // Background is the flattening background as #rrggbb or #rrggbbaa; the
+    // # is optional.
+    Background  string      `json:"background,omitempty"`
+    AlphaPolicy AlphaPolicy `json:"alphaPolicy,omitempty"`
+}
+
+// Job is one SplitDirectory run of a job file.
+type Job struct {
+    // Name identifies the job in the report. Defaults to "job N".
+    Name      string `json:"name,omitempty"`
+    InputDir  string `json:"inputDir"`
+    OutputDir string `json:"outputDir"`
+    JobSettings
+    Overwrite   OverwritePolicy `json:"overwrite,omitempty"`
+    Incremental bool            `json:"incremental,omitempty"`
+    PruneStale  bool            `json:"pruneStale,omitempty"`
+    // Sidecars enables per-image overrides, see DirectorySplitConfig.Sidecars.
+    Sidecars bool `json:"sidecars,omitempty"`
+}
+
+// JobFile is a list of jobs, usually loaded with LoadJobFile.
+type JobFile struct {
+    Jobs []Job `json:"jobs"`
+}
+
+// LoadJobFile reads a job file. Files ending in .ini are parsed with
+// ParseJobsINI, all others with ParseJobsJSON.
+func LoadJobFile(path string) (*JobFile, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("read job file: %w", err)
+    }
+    if strings.EqualFold(filepath.Ext(path), ".ini") {
+        return ParseJobsINI(data)
+    }
+    return ParseJobsJSON(data)
+}
+
+// ParseJobsJSON parses a JSON job file of the form
+//
+//	{"defaults": {...}, "jobs": [{"name": "...", "inputDir": "...", ...}]}
+//
+// The optional defaults object holds Job fields that every job inherits
+// unless it sets them itself.
+func ParseJobsJSON(data []byte) (*JobFile, error) {
+    var raw struct {
+        Defaults json.RawMessage   `json:"defaults"`
+        Jobs     []json.RawMessage `json:"jobs"`
+    }
+    if err := json.Unmarshal(data, &raw); err != nil {
+        return nil, invalidArgf("parse job file: %v", err)
+    }
+
+    file := &JobFile{Jobs: make([]Job, 0, len(raw.Jobs))}
+    for i, msg := range raw.Jobs {
+        // Decoding the defaults afresh for every job keeps pointer fields
+        // such as Color from being shared between jobs.
+        var job Job
+        if len(raw.Defaults) > 0 {
+            if err := json.Unmarshal(raw.Defaults, &job); err != nil {
+                return nil, invalidArgf("parse job defaults: %v", err)
+            }
+        }
+        if err := json.Unmarshal(msg, &job); err != nil {
+            return nil, invalidArgf("parse job %d: %v", i+1, err)
+        }
+        file.Jobs = append(file.Jobs, job)
+    }
+    return file, nil
+}
+
+// iniJob is the flat INI form of a Job.
+type iniJob struct {
+    InputDir      string   `ini:"input_dir"`
+    OutputDir     string   `ini:"output_dir"`
+    Mode          string   `ini:"mode"`
+    Rows          int      `ini:"rows"`
+    Cols          int      `ini:"cols"`
+    TileWidth     int      `ini:"tile_width"`
+    TileHeight    int      `ini:"tile_height"`
+    Overwrite     string   `ini:"overwrite"`
+    Incremental   bool     `ini:"incremental"`
+    PruneStale    bool     `ini:"prune_stale"`
+    Sidecars      bool     `ini:"sidecars"`
+    Prefix        string   `ini:"prefix"`
+    Format        string   `ini:"format"`
+    Quality       int      `ini:"quality"`
+    Transforms    []string `ini:"transforms" delim:","`
+    ColorMode     string   `ini:"color_mode"`
+    Luminance     string   `ini:"luminance"`
+    Threshold     int      `ini:"threshold"`
+    Colors        int      `ini:"colors"`
+    Quantizer     string   `ini:"quantizer"`
+    Dither        bool     `ini:"dither"`
+    SharedPalette bool     `ini:"shared_palette"`
+    MaxBytes      int64    `ini:"max_bytes"`
+    MinQuality    int      `ini:"min_quality"`
+    MaxQuality    int      `ini:"max_quality"`
+    SizePolicy    string   `ini:"size_policy"`
+    Background    string   `ini:"background"`
+    AlphaPolicy   string   `ini:"alpha_policy"`
+}
+
+// ParseJobsINI parses an INI job file. Every section is a job named after
+// the section; keys before the first section are defaults for all jobs:
+//
+//	format = jpeg
+//
+//	[scans]
+//	input_dir = /data/scans
+//	output_dir = /data/tiles/scans
+//	mode = tile
+//	tile_width = 512
+//	tile_height = 512
+//	transforms = rotate:90, trim:8
+//
+// The keys are the Job fields in snake case, with the color options
+// (color_mode, luminance, threshold, colors, quantizer, dither,
+// shared_palette) and the size limit (max_bytes, min_quality, max_quality,
+// size_policy) flattened into the section.
+// Write colors without the leading #, which starts a comment in INI.
+// Transforms are listed as rotate:DEGREES, flip-horizontal, flip-vertical,
+// trim[:TOLERANCE] and crop:X0:Y0:X1:Y1.
+func ParseJobsINI(data []byte) (*JobFile, error) {
+    cfg, err := ini.Load(data)
+    if err != nil {
+        return nil, invalidArgf("parse job file: %v", err)
+    }
+
+    defaults := cfg.Section(ini.DefaultSection)
+    file := &JobFile{}
+    for _, sec := range cfg.Sections() {
+        // MapTo ignores unknown keys, so report them to catch typos.
+        for _, key := range sec.KeyStrings() {
+            if !iniJobKeys[key] {
+                return nil, invalidArgf("job file section %s: unknown key %s", sec.Name(), key)
+            }
+        }
+        if sec.Name() == ini.DefaultSection {
+            continue
+        }
+        var flat iniJob
+        if err := defaults.StrictMapTo(&flat); err != nil {
+            return nil, invalidArgf("parse job defaults: %v", err)
+        }
+        if err := sec.StrictMapTo(&flat); err != nil {
+            return nil, invalidArgf("parse job %s: %v", sec.Name(), err)
+        }
+        job, err := flat.job(sec.Name())
+        if err != nil {
+            return nil, err
+        }
+        file.Jobs = append(file.Jobs, job)
+    }
+    return file, nil
+}
+
+// iniJobKeys holds the keys of iniJob.
+var iniJobKeys = func() map[string]bool {
+    keys := make(map[string]bool)
+    t := reflect.TypeOf(iniJob{})
+    for i := 0; i < t.NumField(); i++ {
+        keys[t.Field(i).Tag.Get("ini")] = true
+    }
+    return keys
+}()
+
+func (f iniJob) job(name string) (Job, error) {
+    job := Job{
+        Name:        name,
+        InputDir:    f.InputDir,
+        OutputDir:   f.OutputDir,
+        Overwrite:   OverwritePolicy(f.Overwrite),
+        Incremental: f.Incremental,
+        PruneStale:  f.PruneStale,
+        Sidecars:    f.Sidecars,
+        JobSettings: JobSettings{
+            Mode:        DirectorySplitMode(f.Mode),
+            Rows:        f.Rows,
+            Cols:        f.Cols,
+            TileWidth:   f.TileWidth,
+            TileHeight:  f.TileHeight,
+            FilePrefix:  f.Prefix,
+            Format:      f.Format,
+            Quality:     f.Quality,
+            Background:  f.Background,
+            AlphaPolicy: AlphaPolicy(f.AlphaPolicy),
+        },
+    }
+    for _, s := range f.Transforms {
+        t, err := parseTransform(s)
+        if err != nil {
+            return Job{}, fmt.Errorf("job %s: %w", name, err)
+        }
+        job.Transforms = append(job.Transforms, t)
+    }
+    if f.ColorMode != "" {
+        if f.Threshold < 0 || f.Threshold > 255 {
+            return Job{}, invalidArgf("job %s: threshold must be between 0 and 255", name)
+        }
+        job.Color = &ColorOptions{
+            Mode:          ColorMode(f.ColorMode),
+            Luminance:     LuminanceFormula(f.Luminance),
+            Threshold:     uint8(f.Threshold),
+            Colors:        f.Colors,
+            Quantizer:     Quantizer(f.Quantizer),
+            Dither:        f.Dither,
+            SharedPalette: f.SharedPalette,
+        }
+    }
+    if f.MaxBytes != 0 {
+        job.SizeLimit = &SizeLimit{
+            MaxBytes:   f.MaxBytes,
+            MinQuality: f.MinQuality,
+            MaxQuality: f.MaxQuality,
+            Policy:     SizeLimitPolicy(f.SizePolicy),
+        }
+    }
+    return job, nil
+}
+
+// parseTransform parses the compact transform syntax of INI job files.
+func parseTransform(s string) (Transform, error) {
+    kind, arg, _ := strings.Cut(strings.TrimSpace(s), ":")
+    ints := func(want int) ([]int, error) {
+        parts := strings.Split(arg, ":")
+        if arg == "" || len(parts) != want {
+            return nil, invalidArgf("transform %q needs %d argument(s)", s, want)
+        }
+        values := make([]int, want)
+        for i, p := range parts {
+            v, err := strconv.Atoi(strings.TrimSpace(p))
+            if err != nil {
+                return nil, invalidArgf("transform %q: %v", s, err)
+            }
+            values[i] = v
+        }
+        return values, nil
+    }
+
+    switch TransformKind(strings.ToLower(kind)) {
+    case TransformRotate:
+        v, err := ints(1)
+        if err != nil {
+            return Transform{}, err
+        }
+        return Rotate(v[0]), nil
+    case TransformFlipHorizontal:
+        return FlipHorizontal(), nil
+    case TransformFlipVertical:
+        return FlipVertical(), nil
+    case TransformTrim:
+        if arg == "" {
+            return AutoTrim(0), nil
+        }
+        v, err := ints(1)
+        if err != nil {
+            return Transform{}, err
+        }
+        if v[0] < 0 || v[0] > 255 {
+            return Transform{}, invalidArgf("transform %q: tolerance must be between 0 and 255", s)
+        }
+        return AutoTrim(uint8(v[0])), nil
+    case TransformCrop:
+        v, err := ints(4)
+        if err != nil {
+            return Transform{}, err
+        }
+        return CropTo(image.Rect(v[0], v[1], v[2], v[3])), nil
+    default:
+        return Transform{}, invalidArgf("unsupported transform: %s", s)
+    }
+}
+
+// apply copies the set fields of s onto cfg.
+func (s JobSettings) apply(cfg *DirectorySplitConfig) error {
+    if s.Mode != "" {
+        cfg.Mode = s.Mode
+    }
+    if s.Rows != 0 || s.Cols != 0 {
+        // Explicit rows and columns replace an inherited layout.
+        cfg.Layout = GridLayout{}
+    }
+    if s.Rows != 0 {
+        cfg.Rows = s.Rows
+    }
+    if s.Cols != 0 {
+        cfg.Cols = s.Cols
+    }
+    if s.Layout != nil {
+        cfg.Layout = *s.Layout
+    }
+    if s.TileWidth != 0 {
+        cfg.TileWidth = s.TileWidth
+    }
+    if s.TileHeight != 0 {
+        cfg.TileHeight = s.TileHeight
+    }
+
+    opts := &cfg.Options
+    if s.FilePrefix != "" {
+        opts.FilePrefix = s.FilePrefix
+    }
+    if s.Format != "" {
+        opts.Format = s.Format
+    }
+    if s.Quality != 0 {
+        opts.Quality = s.Quality
+    }
+    if s.Transforms != nil {
+        opts.Transforms = s.Transforms
+    }
+    if s.Color != nil {
+        opts.Color = s.Color
+    }
+    if s.SizeLimit != nil {
+        opts.SizeLimit = s.SizeLimit
+    }
+    if s.Background != "" {
+        c, err := parseHexColor(s.Background)
+        if err != nil {
+            return err
+        }
+        opts.Background = &Background{Color: c}
+    }
+    if s.AlphaPolicy != "" {
+        opts.AlphaPolicy = s.AlphaPolicy
+    }
+    return nil
+}
+
+// parseHexColor parses #rrggbb or #rrggbbaa; the # is optional.
+func parseHexColor(s string) (color.Color, error) {
+    hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+    if len(hex) != 6 && len(hex) != 8 {
+        return nil, invalidArgf("invalid color %q, expected #rrggbb or #rrggbbaa", s)
+    }
+    v, err := strconv.ParseUint(hex, 16, 32)
+    if err != nil {
+        return nil, invalidArgf("invalid color %q, expected #rrggbb or #rrggbbaa", s)
+    }
+    if len(hex) == 6 {
+        v = v<<8 | 0xff
+    }
+    return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// JobResult reports one job of RunJobs.
+type JobResult struct {
+    Name      string `json:"name"`
+    InputDir  string `json:"inputDir"`
+    OutputDir string `json:"outputDir"`
+    // Results maps every input image to its tiles, as SplitDirectory does.
+    Results  map[string][]string `json:"results,omitempty"`
+    Images   int                 `json:"images"`
+    Tiles    int                 `json:"tiles"`
+    Duration time.Duration       `json:"duration"`
+    // Error is the failure of the job, empty when it succeeded.
+    Error string `json:"error,omitempty"`
+}
+
+// JobReport is the combined result of RunJobs.
+type JobReport struct {
+    Jobs      []JobResult   `json:"jobs"`
+    Succeeded int           `json:"succeeded"`
+    Failed    int           `json:"failed"`
+    Duration  time.Duration `json:"duration"`
+}
+
+// RunJobs validates every job of file and then runs them in order with
+// SplitDirectory. Each job starts from base, which supplies the options a job
+// file cannot express, such as the Observer, the file systems and the
+// watermark, and overrides it with its own settings.
+//
+// When any job is invalid, nothing is run and the returned error lists every
+// problem. A job that fails while running does not stop the others; the
+// report records it and the returned error joins the failures, so the report
+// is always returned once validation succeeded.
+func RunJobs(file *JobFile, base SplitOptions) (*JobReport, error) {
+    if file == nil || len(file.Jobs) == 0 {
+        return nil, invalidArgf("job file has no jobs")
+    }
+
+    var (
+        jobs    = make([]preparedJob, 0, len(file.Jobs))
+        invalid []error
+        names   = make(map[string]bool)
+    )
+    for i, job := range file.Jobs {
+        if strings.TrimSpace(job.Name) == "" {
+            job.Name = fmt.Sprintf("job %d", i+1)
+        }
+        if names[job.Name] {
+            invalid = append(invalid, invalidArgf("job %s: duplicate job name", job.Name))
+            continue
+        }
+        names[job.Name] = true
+
+        cfg, err := job.config(base)
+        if err == nil {
+            err = validateJob(job, cfg)
+        }
+        if err != nil {
+            invalid = append(invalid, fmt.Errorf("job %s: %w", job.Name, err))
+            continue
+        }
+        dirs, err := resolveJobDirs(job, cfg)
+        if err != nil {
+            invalid = append(invalid, fmt.Errorf("job %s: %w", job.Name, err))
+            continue
+        }
+        if err := checkJobOverlap(job, dirs, jobs); err != nil {
+            invalid = append(invalid, err)
+            continue
+        }
+        jobs = append(jobs, preparedJob{job: job, cfg: cfg, dirs: dirs})
+    }
+    if len(invalid) > 0 {
+        return nil, errors.Join(invalid...)
+    }
+
+    start := time.Now()
+    report := &JobReport{Jobs: make([]JobResult, 0, len(jobs))}
+    var failed []error
+    for _, p := range jobs {
+        jobStart := time.Now()
+        results, err := SplitDirectory(p.job.InputDir, p.job.OutputDir, p.cfg)
+        result := JobResult{
+            Name:      p.job.Name,
+            InputDir:  p.job.InputDir,
+            OutputDir: p.job.OutputDir,
+            Results:   results,
+            Images:    len(results),
+            Duration:  time.Since(jobStart),
+        }
+        for _, tiles := range results {
+            result.Tiles += len(tiles)
+        }
+        if err != nil {
+            result.Error = err.Error()
+            report.Failed++
+            failed = append(failed, fmt.Errorf("job %s: %w", p.job.Name, err))
+        } else {
+            report.Succeeded++
+        }
+        report.Jobs = append(report.Jobs, result)
+    }
+    report.Duration = time.Since(start)
+    return report, errors.Join(failed...)
+}
+
+type preparedJob struct {
+    job  Job
+    cfg  DirectorySplitConfig
+    dirs jobDirs
+}
+
+// jobDirs holds the resolved directories of a job. input is empty when the
+// input cannot overlap an output, i.e. unless both are the local file system.
+type jobDirs struct {
+    input, output string
+}
+
+func resolveJobDirs(job Job, cfg DirectorySplitConfig) (jobDirs, error) {
+    var dirs jobDirs
+    var err error
+    if !isLocalOutput(outputFS(cfg.Options.OutputFS)) {
+        dirs.output, err = cleanOutputPath(job.OutputDir)
+        return dirs, err
+    }
+    if dirs.output, err = resolvePath(job.OutputDir); err != nil {
+        return dirs, err
+    }
+    if cfg.Options.InputFS == nil {
+        dirs.input, err = resolvePath(job.InputDir)
+    }
+    return dirs, err
+}
+
+// checkJobOverlap refuses a job whose output directory equals or nests with
+// the output of an earlier job, or equals or contains the input of another
+// job, in either order; those jobs would overwrite, or with PruneStale
+// delete, each other's files.
+func checkJobOverlap(job Job, dirs jobDirs, earlier []preparedJob) error {
+    overlaps := func(a, b string) bool {
+        return a != "" && b != "" && (a == b || isParentPath(a, b))
+    }
+    for _, other := range earlier {
+        switch {
+        case overlaps(dirs.output, other.dirs.output) || overlaps(other.dirs.output, dirs.output):
+            return invalidArgf("job %s: output directory %s overlaps the output directory %s of job %s", job.Name, job.OutputDir, other.job.OutputDir, other.job.Name)
+        case overlaps(dirs.output, other.dirs.input):
+            return invalidArgf("job %s: output directory %s contains the input directory %s of job %s", job.Name, job.OutputDir, other.job.InputDir, other.job.Name)
+        case overlaps(other.dirs.output, dirs.input):
+            return invalidArgf("job %s: input directory %s is inside the output directory %s of job %s", job.Name, job.InputDir, other.job.OutputDir, other.job.Name)
+        }
+    }
+    return nil
+}
+
+// config builds the DirectorySplitConfig of job on top of base.
+func (job Job) config(base SplitOptions) (DirectorySplitConfig, error) {
+    cfg := DirectorySplitConfig{
+        Options:     base,
+        Overwrite:   job.Overwrite,
+        Incremental: job.Incremental,
+        PruneStale:  job.PruneStale,
+        Sidecars:    job.Sidecars,
+    }
+    if err := job.JobSettings.apply(&cfg); err != nil {
+        return DirectorySplitConfig{}, err
+    }
+    return cfg, nil
+}
+
+// validateJob checks everything about a job that can be checked without
+// splitting: the directories, the configuration, the split options and any
+// sidecar files.
+func validateJob(job Job, cfg DirectorySplitConfig) error {
+    if err := validateDirectoryArgs(job.InputDir, job.OutputDir, cfg); err != nil {
+        return err
+    }
+    if err := validateSplitOptions(cfg.Options); err != nil {
+        return err
+    }
+    if cfg.Sidecars {
+        jobs, err := directoryJobs(newInputSource(cfg.Options.InputFS), job.InputDir, job.OutputDir)
+        if err != nil {
+            return err
+        }
+        return loadSidecars(cfg, jobs)
+    }
+    return nil
+}
+
+// validateSplitOptions checks opts without an input image. The source
+// format is only known per image, so a size limit without an explicit
+// format is assumed to apply to JPEG sources.
+func validateSplitOptions(opts SplitOptions) error {
+    source := "png"
+    if opts.SizeLimit != nil {
+        source = "jpeg"
+    }
+    _, err := normalizeOptions("", opts, source)
+    return err
+}
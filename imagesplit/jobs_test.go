@@ -0,0 +1,276 @@
+package imagesplit
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseJobsJSONDefaults(t *testing.T) {
+	file, err := ParseJobsJSON([]byte(`{
+        "defaults": {"format": "jpeg", "quality": 80, "color": {"mode": "gray"}, "overwrite": "clean"},
+        "jobs": [
+            {"name": "scans", "inputDir": "in/scans", "outputDir": "out/scans", "mode": "tile", "tileWidth": 256, "tileHeight": 256},
+            {"inputDir": "in/maps", "outputDir": "out/maps", "mode": "grid", "rows": 2, "cols": 3, "quality": 95,
+             "transforms": [{"kind": "rotate", "degrees": 90}]}
+        ]
+    }`))
+	if err != nil {
+		t.Fatalf("ParseJobsJSON returned error: %v", err)
+	}
+	if len(file.Jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(file.Jobs))
+	}
+	scans, maps := file.Jobs[0], file.Jobs[1]
+	if scans.Format != "jpeg" || scans.Quality != 80 || scans.Overwrite != OverwriteClean || scans.TileWidth != 256 {
+		t.Fatalf("unexpected first job %+v", scans)
+	}
+	if maps.Quality != 95 || maps.Rows != 2 || len(maps.Transforms) != 1 || maps.Transforms[0] != Rotate(90) {
+		t.Fatalf("unexpected second job %+v", maps)
+	}
+	if scans.Color == maps.Color || maps.Color.Mode != ColorModeGray {
+		t.Fatal("expected every job to get its own copy of the default color options")
+	}
+
+	if _, err := ParseJobsJSON([]byte(`{"jobs": [{"rows": "two"}]}`)); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected a malformed job to be rejected, got %v", err)
+	}
+}
+
+func TestParseJobsINI(t *testing.T) {
+	file, err := ParseJobsINI([]byte(`
+format = jpeg
+incremental = true
+
+[scans]
+input_dir = in/scans
+output_dir = out/scans
+mode = tile
+tile_width = 512
+tile_height = 512
+transforms = rotate:90, flip-horizontal, trim:8, crop:0:0:100:50
+color_mode = binary
+threshold = 100
+max_bytes = 50000
+size_policy = downscale
+
+[maps]
+input_dir = in/maps
+output_dir = out/maps
+rows = 2
+cols = 2
+format = png
+background = ff000080
+`))
+	if err != nil {
+		t.Fatalf("ParseJobsINI returned error: %v", err)
+	}
+	if len(file.Jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(file.Jobs))
+	}
+
+	scans := file.Jobs[0]
+	if scans.Name != "scans" || scans.Format != "jpeg" || !scans.Incremental || scans.Mode != DirectorySplitModeTile {
+		t.Fatalf("unexpected first job %+v", scans)
+	}
+	want := []Transform{Rotate(90), FlipHorizontal(), AutoTrim(8), CropTo(image.Rect(0, 0, 100, 50))}
+	if len(scans.Transforms) != len(want) {
+		t.Fatalf("expected %d transforms, got %+v", len(want), scans.Transforms)
+	}
+	for i := range want {
+		if scans.Transforms[i] != want[i] {
+			t.Errorf("transform %d: expected %+v, got %+v", i, want[i], scans.Transforms[i])
+		}
+	}
+	if scans.Color == nil || scans.Color.Mode != ColorModeBinary || scans.Color.Threshold != 100 {
+		t.Fatalf("unexpected color options %+v", scans.Color)
+	}
+	if scans.SizeLimit == nil || scans.SizeLimit.MaxBytes != 50000 || scans.SizeLimit.Policy != SizeLimitDownscale {
+		t.Fatalf("unexpected size limit %+v", scans.SizeLimit)
+	}
+
+	maps := file.Jobs[1]
+	if maps.Format != "png" || maps.Rows != 2 || maps.Color != nil || maps.Background != "ff000080" {
+		t.Fatalf("unexpected second job %+v", maps)
+	}
+
+	for name, data := range map[string]string{
+		"unknown key":       "[a]\ninput_dir = in\ntile_widht = 5\n",
+		"unknown transform": "[a]\ntransforms = spin:90\n",
+		"bad number":        "[a]\nrows = many\n",
+	} {
+		if _, err := ParseJobsINI([]byte(data)); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: expected an invalid argument error, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadJobFileByExtension(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jobs.ini")
+	if err := os.WriteFile(path, []byte("[only]\ninput_dir = in\noutput_dir = out\nrows = 1\ncols = 1\n"), 0o644); err != nil {
+		t.Fatalf("write job file: %v", err)
+	}
+	file, err := LoadJobFile(path)
+	if err != nil {
+		t.Fatalf("LoadJobFile returned error: %v", err)
+	}
+	if len(file.Jobs) != 1 || file.Jobs[0].Name != "only" {
+		t.Fatalf("unexpected jobs %+v", file.Jobs)
+	}
+}
+
+func TestRunJobsReport(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "in/a/one.png", 40, 40, color.RGBA{R: 255, A: 255})
+	writeColorPNG(t, mem, "in/a/two.png", 40, 40, color.RGBA{G: 255, A: 255})
+	writeColorPNG(t, mem, "in/b/three.png", 40, 40, color.RGBA{B: 255, A: 255})
+	mem.WriteFile("in/b/broken.png", []byte("not an image"))
+
+	file := &JobFile{Jobs: []Job{
+		{Name: "grid", InputDir: "in/a", OutputDir: "out/a", JobSettings: JobSettings{Mode: DirectorySplitModeGrid, Rows: 2, Cols: 2}},
+		{Name: "tile", InputDir: "in/b", OutputDir: "out/b", JobSettings: JobSettings{Mode: DirectorySplitModeTile, TileWidth: 20, TileHeight: 40}},
+	}}
+	report, err := RunJobs(file, SplitOptions{InputFS: mem, OutputFS: mem})
+	if err == nil || !strings.Contains(err.Error(), "job tile") {
+		t.Fatalf("expected the failing job to be reported, got %v", err)
+	}
+	if report == nil || report.Succeeded != 1 || report.Failed != 1 || len(report.Jobs) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if grid := report.Jobs[0]; grid.Error != "" || grid.Images != 2 || grid.Tiles != 8 {
+		t.Fatalf("unexpected result of the grid job %+v", grid)
+	}
+	if tile := report.Jobs[1]; tile.Error == "" {
+		t.Fatalf("expected the tile job to fail, got %+v", tile)
+	}
+}
+
+func TestRunJobsValidatesEveryJob(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "in/one.png", 40, 40, color.White)
+
+	file := &JobFile{Jobs: []Job{
+		{InputDir: "in", OutputDir: "out/a", JobSettings: JobSettings{Mode: "spiral"}},
+		{InputDir: "missing", OutputDir: "out/b", JobSettings: JobSettings{Mode: DirectorySplitModeGrid, Rows: 1, Cols: 1}},
+		{InputDir: "in", OutputDir: "out/c", JobSettings: JobSettings{Mode: DirectorySplitModeGrid, Rows: 1, Cols: 1, Format: "gif"}},
+		{InputDir: "in", OutputDir: "out/d", JobSettings: JobSettings{Mode: DirectorySplitModeGrid, Rows: 1, Cols: 1}},
+		{InputDir: "in", OutputDir: "out/d", JobSettings: JobSettings{Mode: DirectorySplitModeGrid, Rows: 1, Cols: 1}},
+	}}
+	report, err := RunJobs(file, SplitOptions{InputFS: mem, OutputFS: mem})
+	if err == nil || report != nil {
+		t.Fatalf("expected validation to fail without a report, got %+v, %v", report, err)
+	}
+	for _, job := range []string{"job 1", "job 2", "job 3", "job 5"} {
+		if !strings.Contains(err.Error(), job+":") {
+			t.Errorf("expected %s to be reported in %v", job, err)
+		}
+	}
+	if strings.Contains(err.Error(), "job 4:") {
+		t.Errorf("did not expect the valid job to be reported: %v", err)
+	}
+	if _, err := mem.Stat("out/d"); err == nil {
+		t.Fatal("expected nothing to run when a job is invalid")
+	}
+}
+
+func TestRunJobsRejectsOverlappingDirectories(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for _, in := range []string{"in/a", "in/b"} {
+		if err := os.MkdirAll(in, 0o755); err != nil {
+			t.Fatalf("create input directory: %v", err)
+		}
+	}
+	grid := JobSettings{Mode: DirectorySplitModeGrid, Rows: 1, Cols: 1}
+
+	for name, pair := range map[string][2]Job{
+		"same output spelled differently": {
+			{InputDir: "in/a", OutputDir: "out", JobSettings: grid},
+			{InputDir: "in/b", OutputDir: "./out/", JobSettings: grid},
+		},
+		"absolute output": {
+			{InputDir: "in/a", OutputDir: "out", JobSettings: grid},
+			{InputDir: "in/b", OutputDir: filepath.Join(dir, "out"), JobSettings: grid},
+		},
+		"nested output": {
+			{InputDir: "in/a", OutputDir: "out/x", JobSettings: grid},
+			{InputDir: "in/b", OutputDir: "out", JobSettings: grid},
+		},
+		"output contains input": {
+			{InputDir: "in/a", OutputDir: "out/a", JobSettings: grid},
+			{InputDir: "in/b", OutputDir: "in", JobSettings: grid},
+		},
+		"input inside output": {
+			{InputDir: "in/a", OutputDir: "in/b", JobSettings: grid},
+			{InputDir: "in/b", OutputDir: "out", JobSettings: grid},
+		},
+	} {
+		_, err := RunJobs(&JobFile{Jobs: pair[:]}, SplitOptions{})
+		if !errors.Is(err, ErrInvalidArgument) || !strings.Contains(err.Error(), "job 2:") {
+			t.Errorf("%s: expected the second job to be rejected, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat("out"); err == nil {
+		t.Fatal("expected no job to run")
+	}
+}
+
+func TestSplitDirectorySidecars(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "in/plain.png", 40, 40, color.White)
+	writeColorPNG(t, mem, "in/custom.png", 40, 40, color.White)
+	mem.WriteFile("in/custom.png"+SidecarSuffix, []byte(`{"rows": 1, "cols": 4, "format": "jpeg"}`))
+
+	cfg := DirectorySplitConfig{
+		Mode:        DirectorySplitModeGrid,
+		Rows:        2,
+		Cols:        2,
+		Sidecars:    true,
+		Incremental: true,
+		Options:     SplitOptions{InputFS: mem, OutputFS: mem},
+	}
+	results, err := SplitDirectory("in", "out", cfg)
+	if err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if got := results["in/plain.png"]; len(got) != 4 || got[3] != "out/plain/plain_row1_col1.png" {
+		t.Fatalf("unexpected tiles without sidecar: %v", got)
+	}
+	if got := results["in/custom.png"]; len(got) != 4 || got[3] != "out/custom/custom_row0_col3.jpg" {
+		t.Fatalf("unexpected tiles with sidecar: %v", got)
+	}
+
+	plan, err := PlanDirectory("in", "out", cfg)
+	if err != nil {
+		t.Fatalf("PlanDirectory returned error: %v", err)
+	}
+	for _, p := range plan.Images {
+		if p.InputPath == "in/custom.png" && (p.Format != "jpeg" || p.Tiles[3].Row != 0) {
+			t.Fatalf("expected the plan to honour the sidecar, got %+v", p)
+		}
+	}
+
+	// Editing the sidecar re-splits its image.
+	mem.WriteFile("in/custom.png"+SidecarSuffix, []byte(`{"rows": 4, "cols": 1, "format": "jpeg"}`))
+	results, err = SplitDirectory("in", "out", cfg)
+	if err != nil {
+		t.Fatalf("second SplitDirectory returned error: %v", err)
+	}
+	if got := results["in/custom.png"]; len(got) != 4 || got[3] != "out/custom/custom_row3_col0.jpg" {
+		t.Fatalf("expected the edited sidecar to apply, got %v", got)
+	}
+
+	mem.WriteFile("in/custom.png"+SidecarSuffix, []byte(`{"rows": -1}`))
+	if _, err := SplitDirectory("in", "out", cfg); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected an invalid sidecar to be rejected, got %v", err)
+	}
+	mem.WriteFile("in/custom.png"+SidecarSuffix, []byte(`{"colums": 2}`))
+	if _, err := SplitDirectory("in", "out", cfg); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected an unknown sidecar field to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package imagesplit
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "io/fs"
+    "path"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// MemFS is an in-memory file system that implements both WritableFS and
+// fs.FS, so tiles written to it can be read back or used as input to another
+// split. Names are clean slash-separated paths as accepted by fs.ValidPath. It is
+// safe for concurrent use.
+type MemFS struct {
+    mu      sync.RWMutex
+    entries map[string]*memEntry
+}
+
+type memEntry struct {
+    data    []byte
+    dir     bool
+    modTime time.Time
+}
+
+// NewMemFS returns an empty MemFS.
+func NewMemFS() *MemFS {
+    return &MemFS{entries: map[string]*memEntry{".": {dir: true, modTime: time.Now()}}}
+}
+
+func memName(op, name string) (string, error) {
+    if !fs.ValidPath(name) {
+        return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+    }
+    return name, nil
+}
+
+// WriteFile stores data under name, creating parent directories as needed.
+func (m *MemFS) WriteFile(name string, data []byte) error {
+    clean, err := memName("write", name)
+    if err != nil {
+        return err
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.store(clean, append([]byte(nil), data...))
+}
+
+func (m *MemFS) store(clean string, data []byte) error {
+    if e, ok := m.entries[clean]; ok && e.dir {
+        return &fs.PathError{Op: "write", Path: clean, Err: fmt.Errorf("is a directory")}
+    }
+    if err := m.mkdirAll(path.Dir(clean)); err != nil {
+        return err
+    }
+    m.entries[clean] = &memEntry{data: data, modTime: time.Now()}
+    return nil
+}
+
+// MkdirAll implements WritableFS.
+func (m *MemFS) MkdirAll(dir string) error {
+    clean, err := memName("mkdir", dir)
+    if err != nil {
+        return err
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.mkdirAll(clean)
+}
+
+func (m *MemFS) mkdirAll(clean string) error {
+    for p := clean; ; p = path.Dir(p) {
+        if e, ok := m.entries[p]; ok {
+            if !e.dir {
+                return &fs.PathError{Op: "mkdir", Path: p, Err: fmt.Errorf("not a directory")}
+            }
+        } else {
+            m.entries[p] = &memEntry{dir: true, modTime: time.Now()}
+        }
+        if p == "." {
+            return nil
+        }
+    }
+}
+
+// Create implements WritableFS. The file appears only when it is committed.
+func (m *MemFS) Create(name string) (OutputFile, error) {
+    clean, err := memName("create", name)
+    if err != nil {
+        return nil, err
+    }
+    return &memOutputFile{fs: m, name: clean}, nil
+}
+
+// ReadFile implements WritableFS and fs.ReadFileFS.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+    clean, err := memName("read", name)
+    if err != nil {
+        return nil, err
+    }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    e, ok := m.entries[clean]
+    if !ok {
+        return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+    }
+    if e.dir {
+        return nil, &fs.PathError{Op: "read", Path: name, Err: fmt.Errorf("is a directory")}
+    }
+    return append([]byte(nil), e.data...), nil
+}
+
+// Stat implements WritableFS and fs.StatFS.
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+    clean, err := memName("stat", name)
+    if err != nil {
+        return nil, err
+    }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    e, ok := m.entries[clean]
+    if !ok {
+        return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+    }
+    return memInfo{name: path.Base(clean), entry: e}, nil
+}
+
+// Remove implements WritableFS.
+func (m *MemFS) Remove(name string) error {
+    clean, err := memName("remove", name)
+    if err != nil {
+        return err
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    e, ok := m.entries[clean]
+    if !ok {
+        return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+    }
+    if e.dir && len(m.children(clean)) > 0 {
+        return &fs.PathError{Op: "remove", Path: name, Err: fmt.Errorf("directory not empty")}
+    }
+    if clean != "." {
+        delete(m.entries, clean)
+    }
+    return nil
+}
+
+// RemoveAll implements WritableFS.
+func (m *MemFS) RemoveAll(name string) error {
+    clean, err := memName("removeall", name)
+    if err != nil {
+        return err
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    for p := range m.entries {
+        if p == "." {
+            continue
+        }
+        if clean == "." || p == clean || strings.HasPrefix(p, clean+"/") {
+            delete(m.entries, p)
+        }
+    }
+    return nil
+}
+
+// Open implements fs.FS.
+func (m *MemFS) Open(name string) (fs.File, error) {
+    if !fs.ValidPath(name) {
+        return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+    }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    e, ok := m.entries[name]
+    if !ok {
+        return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+    }
+    info := memInfo{name: path.Base(name), entry: e}
+    if e.dir {
+        return &memDir{info: info, entries: m.dirEntries(name)}, nil
+    }
+    return &memFile{info: info, Reader: bytes.NewReader(e.data)}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+    clean, err := memName("readdir", name)
+    if err != nil {
+        return nil, err
+    }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    e, ok := m.entries[clean]
+    if !ok {
+        return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+    }
+    if !e.dir {
+        return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+    }
+    return m.dirEntries(clean), nil
+}
+
+func (m *MemFS) children(dir string) []string {
+    var names []string
+    for p := range m.entries {
+        if p != "." && p != dir && path.Dir(p) == dir {
+            names = append(names, p)
+        }
+    }
+    sort.Strings(names)
+    return names
+}
+
+func (m *MemFS) dirEntries(dir string) []fs.DirEntry {
+    children := m.children(dir)
+    entries := make([]fs.DirEntry, 0, len(children))
+    for _, p := range children {
+        entries = append(entries, fs.FileInfoToDirEntry(memInfo{name: path.Base(p), entry: m.entries[p]}))
+    }
+    return entries
+}
+
+type memOutputFile struct {
+    fs   *MemFS
+    name string
+    buf  bytes.Buffer
+    done bool
+}
+
+func (f *memOutputFile) Write(p []byte) (int, error) {
+    if f.done {
+        return 0, fmt.Errorf("output file %s already closed", f.name)
+    }
+    return f.buf.Write(p)
+}
+
+func (f *memOutputFile) Commit() error {
+    if f.done {
+        return fmt.Errorf("output file %s already closed", f.name)
+    }
+    f.done = true
+    f.fs.mu.Lock()
+    defer f.fs.mu.Unlock()
+    return f.fs.store(f.name, f.buf.Bytes())
+}
+
+func (f *memOutputFile) Abort() error {
+    f.done = true
+    return nil
+}
+
+type memInfo struct {
+    name  string
+    entry *memEntry
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return int64(len(i.entry.data)) }
+func (i memInfo) ModTime() time.Time { return i.entry.modTime }
+func (i memInfo) IsDir() bool        { return i.entry.dir }
+func (i memInfo) Sys() any           { return nil }
+
+func (i memInfo) Mode() fs.FileMode {
+    if i.entry.dir {
+        return fs.ModeDir | 0o755
+    }
+    return 0o644
+}
+
+type memFile struct {
+    *bytes.Reader
+    info memInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memDir struct {
+    info    memInfo
+    entries []fs.DirEntry
+    offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+    return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fmt.Errorf("is a directory")}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+    rest := d.entries[d.offset:]
+    if n <= 0 {
+        d.offset = len(d.entries)
+        return rest, nil
+    }
+    if len(rest) == 0 {
+        return nil, io.EOF
+    }
+    if n > len(rest) {
+        n = len(rest)
+    }
+    d.offset += n
+    return rest[:n], nil
+}
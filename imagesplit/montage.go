@@ -0,0 +1,201 @@
+package imagesplit
+
+import (
+    "fmt"
+    "image"
+    "image/color"
+    "image/draw"
+    "math"
+    "path"
+    "path/filepath"
+    "strings"
+
+    "golang.org/x/image/font"
+    "golang.org/x/image/math/fixed"
+)
+
+// MontageOptions configures Compose and ComposeDirectory.
+type MontageOptions struct {
+    // Rows and Cols define the number of cells per sheet.
+    Rows int
+    Cols int
+    // CellWidth and CellHeight are the size of a cell in pixels, including
+    // the caption when Captions is set.
+    CellWidth  int
+    CellHeight int
+    // Fit controls how images are adapted to the cell. Defaults to FitCrop.
+    Fit FitMode
+    // Gutter is the spacing in pixels between cells and around the sheet.
+    Gutter int
+    // Background fills the gutters, padding and empty cells. Defaults to
+    // white.
+    Background color.Color
+    // Captions writes the file name below each image.
+    Captions bool
+    // CaptionColor is the color of the captions. Defaults to black.
+    CaptionColor color.Color
+}
+
+// Compose lays out the input images row by row into sheets of
+// mopts.Rows x mopts.Cols cells, the inverse of GridSplit. When there are
+// more images than cells, further sheets are written. Sheets are named
+// "{prefix}_sheet_{n}" with the prefix defaulting to "montage" and are
+// written like tiles: opts.OutputDir defaults to the directory of the first
+// image, opts.Format to PNG, and opts.OutputFS, opts.InputFS and
+// opts.Watermark are honoured. opts.Transforms are applied to every image
+// before it is fitted. It returns the paths of the written sheets.
+func Compose(inputs []string, mopts MontageOptions, opts SplitOptions) ([]string, error) {
+    return compose(inputs, mopts, opts)
+}
+
+// ComposeDirectory composes every supported image of inputDir, in file name
+// order. See Compose.
+func ComposeDirectory(inputDir string, mopts MontageOptions, opts SplitOptions) ([]string, error) {
+    if strings.TrimSpace(inputDir) == "" {
+        return nil, invalidArgf("input directory is required")
+    }
+    jobs, err := directoryJobs(newInputSource(opts.InputFS), inputDir, "")
+    if err != nil {
+        return nil, err
+    }
+    inputs := make([]string, len(jobs))
+    for i, job := range jobs {
+        inputs[i] = job.inputPath
+    }
+    if opts.OutputDir == "" {
+        opts.OutputDir = inputDir
+    }
+    return compose(inputs, mopts, opts)
+}
+
+func validateMontage(inputs []string, mopts MontageOptions) error {
+    if len(inputs) == 0 {
+        return invalidArgf("at least one input image is required")
+    }
+    if mopts.Rows <= 0 || mopts.Cols <= 0 {
+        return invalidArgf("montage rows and cols must be greater than zero")
+    }
+    if mopts.CellWidth <= 0 || mopts.CellHeight <= 0 {
+        return invalidArgf("montage cell size must be greater than zero")
+    }
+    if mopts.Gutter < 0 {
+        return invalidArgf("montage gutter must not be negative")
+    }
+    return nil
+}
+
+func compose(inputs []string, mopts MontageOptions, opts SplitOptions) ([]string, error) {
+    if err := validateMontage(inputs, mopts); err != nil {
+        return nil, err
+    }
+    fit, err := normalizeFitMode(mopts.Fit)
+    if err != nil {
+        return nil, err
+    }
+    if strings.TrimSpace(opts.FilePrefix) == "" {
+        opts.FilePrefix = "montage"
+    }
+    normalized, err := normalizeOptions(inputs[0], opts, "png")
+    if err != nil {
+        return nil, err
+    }
+    if err := normalized.output.MkdirAll(normalized.outputDir); err != nil {
+        return nil, outputErrorf("create output directory: %w", err)
+    }
+
+    background := mopts.Background
+    if background == nil {
+        background = color.White
+    }
+    captionColor := mopts.CaptionColor
+    if captionColor == nil {
+        captionColor = color.Black
+    }
+
+    var face font.Face
+    imageHeight := mopts.CellHeight
+    if mopts.Captions {
+        size := math.Max(10, math.Min(24, float64(mopts.CellHeight)/12))
+        face, err = newFontFace(nil, size)
+        if err != nil {
+            return nil, fmt.Errorf("load caption font: %w", err)
+        }
+        defer face.Close()
+        metrics := face.Metrics()
+        imageHeight -= (metrics.Ascent + metrics.Descent).Ceil() + 4
+        if imageHeight <= 0 {
+            return nil, invalidArgf("montage cell height %d leaves no room for captions", mopts.CellHeight)
+        }
+    }
+
+    perSheet := mopts.Rows * mopts.Cols
+    sheetWidth := mopts.Cols*mopts.CellWidth + (mopts.Cols+1)*mopts.Gutter
+    sheetHeight := mopts.Rows*mopts.CellHeight + (mopts.Rows+1)*mopts.Gutter
+
+    var sheets []string
+    for start, n := 0, 0; start < len(inputs); start, n = start+perSheet, n+1 {
+        sheet := image.NewRGBA(image.Rect(0, 0, sheetWidth, sheetHeight))
+        draw.Draw(sheet, sheet.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+        for i, input := range inputs[start:min(start+perSheet, len(inputs))] {
+            img, _, err := loadImage(normalized.input, input)
+            if err != nil {
+                return sheets, fmt.Errorf("compose %s: %w", input, err)
+            }
+            if img, err = applyTransforms(img, opts.Transforms); err != nil {
+                return sheets, fmt.Errorf("compose %s: %w", input, err)
+            }
+            row, col := i/mopts.Cols, i%mopts.Cols
+            origin := image.Pt(
+                mopts.Gutter+col*(mopts.CellWidth+mopts.Gutter),
+                mopts.Gutter+row*(mopts.CellHeight+mopts.Gutter),
+            )
+            cell := fitImage(img, mopts.CellWidth, imageHeight, fit, background)
+            draw.Draw(sheet, cell.Bounds().Add(origin), cell, image.Point{}, draw.Over)
+
+            if face != nil {
+                caption := image.Rect(0, imageHeight, mopts.CellWidth, mopts.CellHeight).Add(origin)
+                drawCaption(sheet, caption, captionName(input), face, captionColor)
+            }
+        }
+
+        saved, err := saveTile(sheet, sheet.Bounds(), normalized, fmt.Sprintf("%s_sheet_%d", normalized.prefix, n))
+        if err != nil {
+            return sheets, err
+        }
+        sheets = append(sheets, saved.path)
+    }
+    return sheets, nil
+}
+
+// captionName returns the file name of input for both native and
+// slash-separated paths.
+func captionName(input string) string {
+    return path.Base(filepath.ToSlash(input))
+}
+
+// drawCaption writes text centered in r, shortening it with an ellipsis when
+// it is wider than r.
+func drawCaption(dst draw.Image, r image.Rectangle, text string, face font.Face, c color.Color) {
+    width := r.Dx() - 4
+    if font.MeasureString(face, text).Ceil() > width {
+        runes := []rune(text)
+        for len(runes) > 0 && font.MeasureString(face, string(runes)+"…").Ceil() > width {
+            runes = runes[:len(runes)-1]
+        }
+        text = string(runes) + "…"
+    }
+
+    metrics := face.Metrics()
+    textWidth := font.MeasureString(face, text)
+    d := font.Drawer{
+        Dst:  dst,
+        Src:  image.NewUniform(c),
+        Face: face,
+        Dot: fixed.Point26_6{
+            X: fixed.I(r.Min.X) + (fixed.I(r.Dx())-textWidth)/2,
+            Y: fixed.I(r.Min.Y+2) + metrics.Ascent,
+        },
+    }
+    d.DrawString(text)
+}
@@ -0,0 +1,123 @@
+package imagesplit
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func writeColorPNG(t *testing.T, mem *MemFS, name string, w, h int, c color.Color) {
+	t.Helper()
+	writePNG(t, mem, name, solidImage(w, h, c))
+}
+
+func TestComposePaginatesAndPlacesCells(t *testing.T) {
+	mem := NewMemFS()
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 0, 255}, {0, 255, 255, 255}}
+	var inputs []string
+	for i, c := range colors {
+		name := fmt.Sprintf("in/%d.png", i)
+		writeColorPNG(t, mem, name, 40, 20, c)
+		inputs = append(inputs, name)
+	}
+
+	sheets, err := Compose(inputs, MontageOptions{
+		Rows:       1,
+		Cols:       2,
+		CellWidth:  20,
+		CellHeight: 20,
+		Gutter:     2,
+		Fit:        FitPad,
+		Background: color.Black,
+	}, SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "sheets"})
+	if err != nil {
+		t.Fatalf("Compose returned error: %v", err)
+	}
+	if len(sheets) != 3 || sheets[2] != "sheets/montage_sheet_2.png" {
+		t.Fatalf("unexpected sheets %v", sheets)
+	}
+
+	img := readImage(t, mem, sheets[1])
+	sheet := cropImage(img, img.Bounds())
+	if sheet.Bounds() != image.Rect(0, 0, 46, 24) {
+		t.Fatalf("unexpected sheet size %v", sheet.Bounds())
+	}
+	// Second sheet holds images 2 and 3; each 40x20 image is padded to a
+	// 20x10 band centered in its 20x20 cell.
+	if c := sheet.RGBAAt(12, 12); c != colors[2] {
+		t.Errorf("expected image 2 in the first cell, got %v", c)
+	}
+	if c := sheet.RGBAAt(34, 12); c != colors[3] {
+		t.Errorf("expected image 3 in the second cell, got %v", c)
+	}
+	for _, p := range []image.Point{{1, 12}, {22, 12}, {12, 4}} {
+		if c := sheet.RGBAAt(p.X, p.Y); c != (color.RGBA{0, 0, 0, 255}) {
+			t.Errorf("expected background at %v, got %v", p, c)
+		}
+	}
+
+	img = readImage(t, mem, sheets[2])
+	last := cropImage(img, img.Bounds())
+	if c := last.RGBAAt(34, 12); c != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("expected an empty cell on the last sheet, got %v", c)
+	}
+}
+
+func TestComposeDirectoryWithCaptions(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "photos/b.png", 30, 30, color.RGBA{0, 0, 255, 255})
+	writeColorPNG(t, mem, "photos/a.png", 30, 30, color.RGBA{255, 0, 0, 255})
+
+	sheets, err := ComposeDirectory("photos", MontageOptions{
+		Rows:       1,
+		Cols:       2,
+		CellWidth:  120,
+		CellHeight: 120,
+		Captions:   true,
+	}, SplitOptions{InputFS: mem, OutputFS: mem, FilePrefix: "contact", Format: "jpeg"})
+	if err != nil {
+		t.Fatalf("ComposeDirectory returned error: %v", err)
+	}
+	if len(sheets) != 1 || sheets[0] != "photos/contact_sheet_0.jpg" {
+		t.Fatalf("unexpected sheets %v", sheets)
+	}
+	img := readImage(t, mem, sheets[0])
+	// a.png sorts first and lands in the first cell.
+	if r, _, b, _ := img.At(60, 40).RGBA(); r>>8 < 200 || b>>8 > 60 {
+		t.Errorf("expected a.png in the first cell")
+	}
+	// The caption strip below the image contains dark text pixels.
+	dark := false
+	for y := 105; y < 120 && !dark; y++ {
+		for x := 40; x < 80; x++ {
+			if r, g, b, _ := img.At(x, y).RGBA(); r>>8 < 100 && g>>8 < 100 && b>>8 < 100 {
+				dark = true
+				break
+			}
+		}
+	}
+	if !dark {
+		t.Errorf("expected a caption below the first image")
+	}
+}
+
+func TestComposeValidation(t *testing.T) {
+	cases := []struct {
+		inputs []string
+		mopts  MontageOptions
+	}{
+		{nil, MontageOptions{Rows: 1, Cols: 1, CellWidth: 1, CellHeight: 1}},
+		{[]string{"a.png"}, MontageOptions{Rows: 0, Cols: 1, CellWidth: 1, CellHeight: 1}},
+		{[]string{"a.png"}, MontageOptions{Rows: 1, Cols: 1, CellWidth: 0, CellHeight: 1}},
+		{[]string{"a.png"}, MontageOptions{Rows: 1, Cols: 1, CellWidth: 1, CellHeight: 1, Gutter: -1}},
+		{[]string{"a.png"}, MontageOptions{Rows: 1, Cols: 1, CellWidth: 10, CellHeight: 10, Captions: true}},
+		{[]string{"a.png"}, MontageOptions{Rows: 1, Cols: 1, CellWidth: 1, CellHeight: 1, Fit: "zoom"}},
+	}
+	for i, tc := range cases {
+		if _, err := Compose(tc.inputs, tc.mopts, SplitOptions{OutputFS: NewMemFS()}); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("case %d: expected ErrInvalidArgument, got %v", i, err)
+		}
+	}
+}
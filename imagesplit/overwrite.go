@@ -0,0 +1,141 @@
+package imagesplit
+
+import (
+    "fmt"
+    "io/fs"
+    "path/filepath"
+    "strings"
+)
+
+// OverwritePolicy controls what SplitDirectory does when an image's output
+// subdirectory already exists.
+type OverwritePolicy string
+
+const (
+    // OverwriteFiles writes the generated tiles over any existing files with
+    // the same names and leaves every other file in place. It is the default.
+    OverwriteFiles OverwritePolicy = "overwrite"
+    // OverwriteFail refuses to run if any image's output directory exists.
+    // The check happens before anything is written.
+    OverwriteFail OverwritePolicy = "fail"
+    // OverwriteSkip leaves images alone whose output directory already holds
+    // every tile the split would produce.
+    OverwriteSkip OverwritePolicy = "skip"
+    // OverwriteClean removes each image's output directory before writing it.
+    OverwriteClean OverwritePolicy = "clean"
+)
+
+func normalizeOverwritePolicy(policy OverwritePolicy) (OverwritePolicy, error) {
+    switch OverwritePolicy(strings.ToLower(strings.TrimSpace(string(policy)))) {
+    case "", OverwriteFiles:
+        return OverwriteFiles, nil
+    case OverwriteFail:
+        return OverwriteFail, nil
+    case OverwriteSkip:
+        return OverwriteSkip, nil
+    case OverwriteClean:
+        return OverwriteClean, nil
+    default:
+        return "", invalidArgf("unsupported overwrite policy: %s", policy)
+    }
+}
+
+// checkDirectoryJobs refuses per-image output directories that would clash
+// with outputDir, inputDir or one of their parents, and enforces
+// OverwriteFail before any image is processed. The input directory can only
+// overlap when both sides are the local file system.
+func checkDirectoryJobs(opts SplitOptions, inputDir, outputDir string, jobs []directoryJob, policy OverwritePolicy) error {
+    out := outputFS(opts.OutputFS)
+    local := opts.InputFS == nil && isLocalOutput(out)
+
+    resolve := resolvePath
+    if !isLocalOutput(out) {
+        resolve = cleanOutputPath
+    }
+
+    output, err := resolve(outputDir)
+    if err != nil {
+        return err
+    }
+    protected := []string{output}
+    if local {
+        input, err := resolvePath(inputDir)
+        if err != nil {
+            return err
+        }
+        protected = append(protected, input)
+    }
+
+    for _, job := range jobs {
+        target, err := resolve(job.outputDir)
+        if err != nil {
+            return err
+        }
+        for _, p := range protected {
+            if target == p || isParentPath(target, p) {
+                return fmt.Errorf("refusing to write %s: output directory %s overlaps %s", job.inputPath, job.outputDir, p)
+            }
+        }
+
+        if policy == OverwriteFail {
+            if _, err := out.Stat(job.outputDir); err == nil {
+                return fmt.Errorf("output directory already exists: %s: %w", job.outputDir, fs.ErrExist)
+            } else if !isNotExist(err) {
+                return fmt.Errorf("stat output directory: %w", err)
+            }
+        }
+    }
+    return nil
+}
+
+// outputComplete reports whether every planned tile already exists.
+func outputComplete(out WritableFS, plan *SplitPlan) bool {
+    if len(plan.Tiles) == 0 {
+        return false
+    }
+    for _, tile := range plan.Tiles {
+        info, err := out.Stat(tile.Path)
+        if err != nil || !info.Mode().IsRegular() {
+            return false
+        }
+    }
+    return true
+}
+
+// cleanOutputPath normalizes a name of a non-local WritableFS for comparison.
+func cleanOutputPath(path string) (string, error) {
+    return filepath.Clean(filepath.FromSlash(strings.TrimPrefix(filepath.ToSlash(path), "/"))), nil
+}
+
+// resolvePath returns an absolute, symlink-free version of path. Components
+// that do not exist yet are appended to the resolved existing prefix.
+func resolvePath(path string) (string, error) {
+    abs, err := filepath.Abs(path)
+    if err != nil {
+        return "", fmt.Errorf("resolve path %s: %w", path, err)
+    }
+
+    existing := abs
+    var rest []string
+    for {
+        resolved, err := filepath.EvalSymlinks(existing)
+        if err == nil {
+            return filepath.Join(append([]string{resolved}, rest...)...), nil
+        }
+        parent := filepath.Dir(existing)
+        if parent == existing {
+            return abs, nil
+        }
+        rest = append([]string{filepath.Base(existing)}, rest...)
+        existing = parent
+    }
+}
+
+// isParentPath reports whether parent strictly contains child.
+func isParentPath(parent, child string) bool {
+    rel, err := filepath.Rel(parent, child)
+    if err != nil || rel == "." {
+        return false
+    }
+    return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,135 @@
+package imagesplit
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	testdata "github.com/zsq2010/utils/imagesplit/testdata"
+)
+
+func setupOverwriteDirs(t *testing.T) (string, string, string) {
+	t.Helper()
+	inputDir := t.TempDir()
+	if err := testdata.WriteGradientPNG(filepath.Join(inputDir, "photo.png")); err != nil {
+		t.Fatalf("write gradient png: %v", err)
+	}
+	outDir := t.TempDir()
+	curated := filepath.Join(outDir, "photo", "notes.txt")
+	if err := os.MkdirAll(filepath.Dir(curated), 0o755); err != nil {
+		t.Fatalf("create curated dir: %v", err)
+	}
+	if err := os.WriteFile(curated, []byte("keep me"), 0o644); err != nil {
+		t.Fatalf("write curated file: %v", err)
+	}
+	return inputDir, outDir, curated
+}
+
+func gridConfig(policy OverwritePolicy) DirectorySplitConfig {
+	return DirectorySplitConfig{Mode: DirectorySplitModeGrid, Rows: 2, Cols: 2, Overwrite: policy}
+}
+
+func TestSplitDirectoryDefaultKeepsOtherFiles(t *testing.T) {
+	inputDir, outDir, curated := setupOverwriteDirs(t)
+
+	results, err := SplitDirectory(inputDir, outDir, gridConfig(""))
+	if err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if len(results[filepath.Join(inputDir, "photo.png")]) != 4 {
+		t.Fatalf("expected 4 tiles, got %v", results)
+	}
+	if _, err := os.Stat(curated); err != nil {
+		t.Fatalf("expected curated file to survive: %v", err)
+	}
+}
+
+func TestSplitDirectoryCleanRemovesDirectory(t *testing.T) {
+	inputDir, outDir, curated := setupOverwriteDirs(t)
+
+	if _, err := SplitDirectory(inputDir, outDir, gridConfig(OverwriteClean)); err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if _, err := os.Stat(curated); !os.IsNotExist(err) {
+		t.Fatalf("expected clean policy to remove existing files")
+	}
+}
+
+func TestSplitDirectoryFailWhenExists(t *testing.T) {
+	inputDir, outDir, _ := setupOverwriteDirs(t)
+
+	if _, err := SplitDirectory(inputDir, outDir, gridConfig(OverwriteFail)); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected fs.ErrExist for existing output directory, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "photo", "photo_row0_col0.png")); !os.IsNotExist(err) {
+		t.Fatalf("expected no tiles to be written")
+	}
+}
+
+func TestSplitDirectorySkipCompleteOutput(t *testing.T) {
+	inputDir, outDir, _ := setupOverwriteDirs(t)
+
+	if _, err := SplitDirectory(inputDir, outDir, gridConfig("")); err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	marker := filepath.Join(outDir, "photo", "photo_row1_col1.png")
+	if err := os.WriteFile(marker, []byte("untouched"), 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+
+	results, err := SplitDirectory(inputDir, outDir, gridConfig(OverwriteSkip))
+	if err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if len(results[filepath.Join(inputDir, "photo.png")]) != 4 {
+		t.Fatalf("expected skipped image to report its 4 tiles")
+	}
+	data, err := os.ReadFile(marker)
+	if err != nil || string(data) != "untouched" {
+		t.Fatalf("expected skip policy to leave existing output alone")
+	}
+
+	if err := os.Remove(marker); err != nil {
+		t.Fatalf("remove marker: %v", err)
+	}
+	if _, err := SplitDirectory(inputDir, outDir, gridConfig(OverwriteSkip)); err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("expected incomplete output to be regenerated: %v", err)
+	}
+}
+
+func TestSplitDirectoryRefusesOverlappingOutput(t *testing.T) {
+	root := t.TempDir()
+	inputDir := filepath.Join(root, "photos")
+	source := filepath.Join(inputDir, "photos.png")
+	if err := testdata.WriteGradientPNG(source); err != nil {
+		t.Fatalf("write gradient png: %v", err)
+	}
+
+	// photos.png would be written to root/photos, which is the input directory.
+	if _, err := SplitDirectory(inputDir, root, gridConfig(OverwriteClean)); err == nil {
+		t.Fatalf("expected error for output directory resolving to input directory")
+	}
+	if _, err := os.Stat(source); err != nil {
+		t.Fatalf("expected source image to survive: %v", err)
+	}
+
+	dotDir := t.TempDir()
+	if err := testdata.WriteGradientPNG(filepath.Join(dotDir, "..png")); err != nil {
+		t.Fatalf("write gradient png: %v", err)
+	}
+	if _, err := PlanDirectory(dotDir, t.TempDir(), gridConfig(OverwriteClean)); err == nil {
+		t.Fatalf("expected error for output directory resolving to outputDir")
+	}
+}
+
+func TestSplitDirectoryInvalidPolicy(t *testing.T) {
+	inputDir, outDir, _ := setupOverwriteDirs(t)
+	if _, err := SplitDirectory(inputDir, outDir, gridConfig("replace")); err == nil {
+		t.Fatalf("expected error for unsupported overwrite policy")
+	}
+}
@@ -0,0 +1,101 @@
+package imagesplit
+
+import (
+    "bytes"
+    "fmt"
+    "image"
+    "image/jpeg"
+    "io"
+    "strings"
+)
+
+// pdfWriter writes a minimal PDF document in which every page shows one
+// JPEG image covering the whole page. Objects 1 and 2 are reserved for the
+// catalog and the page tree, which are written last.
+type pdfWriter struct {
+    w       *countingWriter
+    offsets map[int]int64
+    next    int
+    pages   []int
+}
+
+func newPDFWriter(w io.Writer) (*pdfWriter, error) {
+    p := &pdfWriter{w: &countingWriter{w: w}, offsets: make(map[int]int64), next: 3}
+    // The binary comment marks the file as binary for transfer tools.
+    if _, err := io.WriteString(p.w, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+func (p *pdfWriter) object(id int, body string, stream []byte) error {
+    p.offsets[id] = p.w.n
+    var buf bytes.Buffer
+    fmt.Fprintf(&buf, "%d 0 obj\n%s\n", id, body)
+    if stream != nil {
+        buf.WriteString("stream\n")
+        buf.Write(stream)
+        buf.WriteString("\nendstream\n")
+    }
+    buf.WriteString("endobj\n")
+    _, err := p.w.Write(buf.Bytes())
+    return err
+}
+
+// addPage adds a page of widthPt x heightPt points showing img encoded as
+// JPEG with the given quality.
+func (p *pdfWriter) addPage(img image.Image, widthPt, heightPt float64, quality int) error {
+    var data bytes.Buffer
+    if err := jpeg.Encode(&data, img, &jpeg.Options{Quality: quality}); err != nil {
+        return fmt.Errorf("encode pdf page: %w", err)
+    }
+
+    imageID, contentID, pageID := p.next, p.next+1, p.next+2
+    p.next += 3
+    b := img.Bounds()
+
+    err := p.object(imageID, fmt.Sprintf(
+        "<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>",
+        b.Dx(), b.Dy(), data.Len()), data.Bytes())
+    if err != nil {
+        return err
+    }
+
+    content := fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q", widthPt, heightPt)
+    if err := p.object(contentID, fmt.Sprintf("<< /Length %d >>", len(content)), []byte(content)); err != nil {
+        return err
+    }
+
+    err = p.object(pageID, fmt.Sprintf(
+        "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>",
+        widthPt, heightPt, imageID, contentID), nil)
+    if err != nil {
+        return err
+    }
+    p.pages = append(p.pages, pageID)
+    return nil
+}
+
+// close writes the page tree, the catalog and the cross-reference table.
+func (p *pdfWriter) close() error {
+    kids := make([]string, len(p.pages))
+    for i, id := range p.pages {
+        kids[i] = fmt.Sprintf("%d 0 R", id)
+    }
+    if err := p.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)), nil); err != nil {
+        return err
+    }
+    if err := p.object(1, "<< /Type /Catalog /Pages 2 0 R >>", nil); err != nil {
+        return err
+    }
+
+    xref := p.w.n
+    var buf bytes.Buffer
+    fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", p.next)
+    for id := 1; id < p.next; id++ {
+        fmt.Fprintf(&buf, "%010d 00000 n \n", p.offsets[id])
+    }
+    fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", p.next, xref)
+    _, err := p.w.Write(buf.Bytes())
+    return err
+}
@@ -0,0 +1,169 @@
+package imagesplit
+
+import (
+    "fmt"
+    "image"
+)
+
+// TilePlan describes a single tile that a split produces.
+type TilePlan struct {
+    // Name is the tile file name without extension.
+    Name string `json:"name"`
+    // Path is the output path the tile is written to.
+    Path string `json:"path"`
+    // Rect is the region of the source image covered by the tile.
+    Rect image.Rectangle `json:"rect"`
+    // Row and Col locate the tile in the grid. In tile mode they count the
+    // tiles along each axis.
+    Row int `json:"row"`
+    Col int `json:"col"`
+    // Index is the position of the tile in the output order.
+    Index int `json:"index"`
+}
+
+// SplitPlan describes the output of splitting a single image.
+type SplitPlan struct {
+    InputPath string     `json:"inputPath"`
+    Width     int        `json:"width"`
+    Height    int        `json:"height"`
+    Format    string     `json:"format"`
+    OutputDir string     `json:"outputDir"`
+    Tiles     []TilePlan `json:"tiles"`
+    // Pixels is the total number of pixels that will be encoded.
+    Pixels int64 `json:"pixels"`
+}
+
+// Paths returns the output paths of the planned tiles in order, matching the
+// slice returned by the corresponding split function.
+func (p *SplitPlan) Paths() []string {
+    paths := make([]string, len(p.Tiles))
+    for i, t := range p.Tiles {
+        paths[i] = t.Path
+    }
+    return paths
+}
+
+// DirectoryPlan describes the output of SplitDirectory.
+type DirectoryPlan struct {
+    InputDir  string       `json:"inputDir"`
+    OutputDir string       `json:"outputDir"`
+    Images    []*SplitPlan `json:"images"`
+    // TotalTiles is the number of tiles across all images.
+    TotalTiles int `json:"totalTiles"`
+    // Pixels is the number of pixels that will be encoded across all images.
+    Pixels int64 `json:"pixels"`
+}
+
+// PlanGrid returns the tiles GridSplit would write for the input image
+// without decoding pixels or touching the output directory.
+func PlanGrid(inputPath string, rows, cols int, opts SplitOptions) (*SplitPlan, error) {
+    if err := validateGrid(rows, cols); err != nil {
+        return nil, err
+    }
+    return planSplit(inputPath, opts, gridLayout(rows, cols))
+}
+
+// PlanGridLayout returns the tiles GridSplitLayout would write for the input
+// image without decoding pixels or touching the output directory.
+func PlanGridLayout(inputPath string, layout GridLayout, opts SplitOptions) (*SplitPlan, error) {
+    if err := validateGridLayout(layout); err != nil {
+        return nil, err
+    }
+    return planSplit(inputPath, opts, trackLayout(layout))
+}
+
+// PlanTile returns the tiles TileSplit would write for the input image
+// without decoding pixels or touching the output directory.
+func PlanTile(inputPath string, tileWidth, tileHeight int, opts SplitOptions) (*SplitPlan, error) {
+    if err := validateTileSize(tileWidth, tileHeight); err != nil {
+        return nil, err
+    }
+    return planSplit(inputPath, opts, fixedTileLayout(tileWidth, tileHeight))
+}
+
+// PlanDirectory returns the tiles SplitDirectory would write for every
+// supported image in inputDir. Only image headers are read and nothing is
+// created or removed, so it can be used to validate a configuration, including
+// the overwrite policy and output directory checks, before a large batch.
+func PlanDirectory(inputDir, outputDir string, cfg DirectorySplitConfig) (*DirectoryPlan, error) {
+    if err := validateDirectoryArgs(inputDir, outputDir, cfg); err != nil {
+        return nil, err
+    }
+
+    policy, err := normalizeOverwritePolicy(cfg.Overwrite)
+    if err != nil {
+        return nil, err
+    }
+
+    jobs, err := directoryJobs(newInputSource(cfg.Options.InputFS), inputDir, outputDir)
+    if err != nil {
+        return nil, err
+    }
+    if cfg.Sidecars {
+        if err := loadSidecars(cfg, jobs); err != nil {
+            return nil, err
+        }
+    }
+    if err := checkDirectoryJobs(cfg.Options, inputDir, outputDir, jobs, policy); err != nil {
+        return nil, err
+    }
+
+    plan := &DirectoryPlan{
+        InputDir:  inputDir,
+        OutputDir: outputDir,
+        Images:    make([]*SplitPlan, 0, len(jobs)),
+    }
+    for _, job := range jobs {
+        jobCfg := job.configFor(cfg)
+        opts := jobCfg.Options
+        opts.OutputDir = job.outputDir
+
+        p, err := planSplit(job.inputPath, opts, jobCfg.layout())
+        if err != nil {
+            return nil, fmt.Errorf("plan image %s: %w", job.inputPath, err)
+        }
+        plan.Images = append(plan.Images, p)
+        plan.TotalTiles += len(p.Tiles)
+        plan.Pixels += p.Pixels
+    }
+    return plan, nil
+}
+
+func planSplit(inputPath string, opts SplitOptions, layout tileLayout) (*SplitPlan, error) {
+    if inputPath == "" {
+        return nil, invalidArgf("input path is required")
+    }
+
+    size, srcFormat, err := loadTransformedSize(newInputSource(opts.InputFS), inputPath, opts.Transforms)
+    if err != nil {
+        return nil, err
+    }
+
+    normalized, err := normalizeOptions(inputPath, opts, srcFormat)
+    if err != nil {
+        return nil, err
+    }
+
+    bounds := image.Rect(0, 0, size.X, size.Y)
+    tiles, err := layout(bounds, normalized)
+    if err != nil {
+        return nil, err
+    }
+    tiles, err = selectTiles(tiles, normalized.selection)
+    if err != nil {
+        return nil, err
+    }
+
+    plan := &SplitPlan{
+        InputPath: inputPath,
+        Width:     size.X,
+        Height:    size.Y,
+        Format:    normalized.format,
+        OutputDir: normalized.outputDir,
+        Tiles:     tiles,
+    }
+    for _, t := range tiles {
+        plan.Pixels += int64(t.Rect.Dx()) * int64(t.Rect.Dy())
+    }
+    return plan, nil
+}
@@ -0,0 +1,117 @@
+package imagesplit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	testdata "github.com/zsq2010/utils/imagesplit/testdata"
+)
+
+func TestPlanGridMatchesSplit(t *testing.T) {
+	pngPath, _ := createSampleImages(t)
+	outDir := filepath.Join(t.TempDir(), "out")
+	opts := SplitOptions{OutputDir: outDir}
+
+	plan, err := PlanGrid(pngPath, 3, 4, opts)
+	if err != nil {
+		t.Fatalf("PlanGrid returned error: %v", err)
+	}
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Fatalf("expected planning not to create the output directory")
+	}
+	if plan.Width != 10 || plan.Height != 10 || plan.Format != "png" {
+		t.Fatalf("unexpected plan header: %+v", plan)
+	}
+	if plan.Pixels != 100 {
+		t.Errorf("expected 100 pixels, got %d", plan.Pixels)
+	}
+
+	files, err := GridSplit(pngPath, 3, 4, opts)
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	if !reflect.DeepEqual(plan.Paths(), files) {
+		t.Fatalf("plan paths %v do not match split output %v", plan.Paths(), files)
+	}
+	for _, tile := range plan.Tiles {
+		b := decodePNGFile(t, tile.Path).Bounds()
+		if b.Dx() != tile.Rect.Dx() || b.Dy() != tile.Rect.Dy() {
+			t.Errorf("tile %s: planned %v, got %dx%d", tile.Name, tile.Rect, b.Dx(), b.Dy())
+		}
+	}
+}
+
+func TestPlanTileMatchesSplit(t *testing.T) {
+	_, jpegPath := createSampleImages(t)
+	opts := SplitOptions{OutputDir: t.TempDir(), FilePrefix: "t", Format: "png"}
+
+	plan, err := PlanTile(jpegPath, 5, 3, opts)
+	if err != nil {
+		t.Fatalf("PlanTile returned error: %v", err)
+	}
+	files, err := TileSplit(jpegPath, 5, 3, opts)
+	if err != nil {
+		t.Fatalf("TileSplit returned error: %v", err)
+	}
+	if !reflect.DeepEqual(plan.Paths(), files) {
+		t.Fatalf("plan paths %v do not match split output %v", plan.Paths(), files)
+	}
+	last := plan.Tiles[len(plan.Tiles)-1]
+	if last.Row != 2 || last.Col != 2 || last.Rect.Dx() != 2 || last.Rect.Dy() != 2 {
+		t.Errorf("unexpected last tile: %+v", last)
+	}
+	if plan.Pixels != 96 {
+		t.Errorf("expected 96 pixels, got %d", plan.Pixels)
+	}
+}
+
+func TestPlanDirectoryMatchesSplit(t *testing.T) {
+	inputDir := t.TempDir()
+	if err := testdata.WriteGradientPNG(filepath.Join(inputDir, "photo.png")); err != nil {
+		t.Fatalf("write gradient png: %v", err)
+	}
+	if err := testdata.WriteBlocksJPEG(filepath.Join(inputDir, "photo.jpg")); err != nil {
+		t.Fatalf("write blocks jpeg: %v", err)
+	}
+	outDir := t.TempDir()
+	cfg := DirectorySplitConfig{Mode: DirectorySplitModeTile, TileWidth: 4, TileHeight: 4}
+
+	plan, err := PlanDirectory(inputDir, outDir, cfg)
+	if err != nil {
+		t.Fatalf("PlanDirectory returned error: %v", err)
+	}
+	if len(plan.Images) != 2 {
+		t.Fatalf("expected 2 planned images, got %d", len(plan.Images))
+	}
+	if plan.TotalTiles != 9+6 {
+		t.Errorf("expected 15 tiles, got %d", plan.TotalTiles)
+	}
+	if plan.Pixels != 100+96 {
+		t.Errorf("expected 196 pixels, got %d", plan.Pixels)
+	}
+
+	results, err := SplitDirectory(inputDir, outDir, cfg)
+	if err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	for _, img := range plan.Images {
+		if !reflect.DeepEqual(img.Paths(), results[img.InputPath]) {
+			t.Errorf("plan for %s does not match split output", img.InputPath)
+		}
+	}
+}
+
+func TestPlanDetectsConfigurationErrors(t *testing.T) {
+	pngPath, _ := createSampleImages(t)
+	if _, err := PlanGrid(pngPath, 20, 2, SplitOptions{}); err == nil {
+		t.Fatalf("expected error for more rows than pixels")
+	}
+	if _, err := PlanTile(pngPath, 4, 4, SplitOptions{Format: "gif"}); err == nil {
+		t.Fatalf("expected error for unsupported output format")
+	}
+	if _, err := PlanDirectory(t.TempDir(), t.TempDir(), DirectorySplitConfig{Mode: "spiral"}); err == nil {
+		t.Fatalf("expected error for unsupported mode")
+	}
+}
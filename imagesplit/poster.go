@@ -0,0 +1,447 @@
+package imagesplit
+
+import (
+    "fmt"
+    "image"
+    "image/color"
+    "image/draw"
+    "math"
+    "path/filepath"
+
+    "golang.org/x/image/font"
+    "golang.org/x/image/math/fixed"
+)
+
+// PaperSize is a sheet of paper in millimetres, in portrait orientation.
+type PaperSize struct {
+    Name     string
+    WidthMM  float64
+    HeightMM float64
+}
+
+// Common paper sizes.
+var (
+    PaperA4     = PaperSize{Name: "A4", WidthMM: 210, HeightMM: 297}
+    PaperA3     = PaperSize{Name: "A3", WidthMM: 297, HeightMM: 420}
+    PaperLetter = PaperSize{Name: "Letter", WidthMM: 215.9, HeightMM: 279.4}
+    PaperLegal  = PaperSize{Name: "Legal", WidthMM: 215.9, HeightMM: 355.6}
+)
+
+// PosterOutput selects how poster pages are written.
+type PosterOutput string
+
+const (
+    // PosterImages writes one image per page, named
+    // "{prefix}_page_{label}".
+    PosterImages PosterOutput = "images"
+    // PosterPDF writes a single multi-page PDF named "{prefix}.pdf".
+    PosterPDF PosterOutput = "pdf"
+)
+
+// maxPosterPixels bounds the rendered poster to keep memory use sane.
+const maxPosterPixels = 500_000_000
+
+// PosterOptions configures PosterSplit. The physical poster size is set by at
+// most one of WidthMM, HeightMM and Scale; the other dimension follows the
+// image aspect ratio.
+type PosterOptions struct {
+    // WidthMM or HeightMM is the printed poster size in millimetres.
+    WidthMM  float64
+    HeightMM float64
+    // Scale prints the image at Scale times its natural size at DPI (one
+    // image pixel per printed dot). It is the default, with a value of 1.
+    Scale float64
+    // Paper is the printer paper. Defaults to PaperA4.
+    Paper PaperSize
+    // Landscape rotates the paper.
+    Landscape bool
+    // MarginMM is the unprinted border on every side of a page, which also
+    // holds the marks and labels. Defaults to 10.
+    MarginMM float64
+    // OverlapMM is the strip shared by neighbouring pages for gluing.
+    OverlapMM float64
+    // DPI is the print resolution. Defaults to 150.
+    DPI float64
+    // NoMarks disables the crop, overlap and registration marks.
+    NoMarks bool
+    // NoLabels disables the page labels such as "B3" (row B, column 3).
+    NoLabels bool
+    // Output selects per-page images (the default) or a single PDF.
+    Output PosterOutput
+}
+
+// PosterPage is one printed page of a poster.
+type PosterPage struct {
+    // Label names the page by row letter and column number, e.g. "B3".
+    Label string `json:"label"`
+    Row   int    `json:"row"`
+    Col   int    `json:"col"`
+    Index int    `json:"index"`
+    // Rect is the region of the poster, in pixels at the print DPI, that is
+    // printed on the page. Regions of neighbouring pages share the overlap.
+    Rect image.Rectangle `json:"rect"`
+}
+
+// PosterPlan describes the page grid of a poster.
+type PosterPlan struct {
+    InputPath string  `json:"inputPath"`
+    WidthMM   float64 `json:"widthMM"`
+    HeightMM  float64 `json:"heightMM"`
+    // WidthPx and HeightPx are the poster size at DPI.
+    WidthPx  int     `json:"widthPx"`
+    HeightPx int     `json:"heightPx"`
+    DPI      float64 `json:"dpi"`
+    // PaperWidthMM and PaperHeightMM are the page size after orientation.
+    PaperWidthMM  float64      `json:"paperWidthMM"`
+    PaperHeightMM float64      `json:"paperHeightMM"`
+    Rows          int          `json:"rows"`
+    Cols          int          `json:"cols"`
+    Pages         []PosterPage `json:"pages"`
+
+    paperPx  image.Point
+    marginPx int
+}
+
+// PlanPoster computes the page grid PosterSplit would print without decoding
+// pixels or writing anything.
+func PlanPoster(inputPath string, popts PosterOptions, opts SplitOptions) (*PosterPlan, error) {
+    if inputPath == "" {
+        return nil, invalidArgf("input path is required")
+    }
+    size, _, err := loadTransformedSize(newInputSource(opts.InputFS), inputPath, opts.Transforms)
+    if err != nil {
+        return nil, err
+    }
+    plan, err := planPoster(size.X, size.Y, popts)
+    if err != nil {
+        return nil, err
+    }
+    plan.InputPath = inputPath
+    return plan, nil
+}
+
+// PosterSplit prints the input image as a poster across several pages of
+// paper. Every page gets the poster region of its cell, crop marks at the
+// printed area, marks where the glue overlap starts, registration crosses
+// and a label such as "B3". Pages are written as images using the format
+// options of opts, or as a single PDF. It returns the written paths.
+func PosterSplit(inputPath string, popts PosterOptions, opts SplitOptions) ([]string, error) {
+    plan, err := PlanPoster(inputPath, popts, opts)
+    if err != nil {
+        return nil, err
+    }
+    ctx, err := prepareSplit(inputPath, opts)
+    if err != nil {
+        return nil, err
+    }
+
+    // Pages are rendered one at a time so only a single sheet is held in
+    // memory next to the poster.
+    poster := resizeImage(ctx.img, plan.WidthPx, plan.HeightPx)
+    render := func(page PosterPage) (*image.RGBA, error) {
+        return renderPosterPage(poster, plan, page, popts)
+    }
+
+    if popts.Output == PosterPDF {
+        path, err := writePosterPDF(render, plan, ctx.options)
+        if err != nil {
+            return nil, err
+        }
+        return []string{path}, nil
+    }
+
+    var written []string
+    cleanup := func() {
+        for _, p := range written {
+            ctx.options.output.Remove(p)
+        }
+    }
+    for _, page := range plan.Pages {
+        sheet, err := render(page)
+        if err != nil {
+            cleanup()
+            return nil, err
+        }
+        name := fmt.Sprintf("%s_page_%s", ctx.options.prefix, page.Label)
+        saved, err := saveTile(sheet, sheet.Bounds(), ctx.options, name)
+        if err != nil {
+            cleanup()
+            return nil, err
+        }
+        written = append(written, saved.path)
+    }
+    return written, nil
+}
+
+func planPoster(imgWidth, imgHeight int, popts PosterOptions) (*PosterPlan, error) {
+    sizes := 0
+    for _, v := range []float64{popts.WidthMM, popts.HeightMM, popts.Scale} {
+        if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+            return nil, invalidArgf("poster size and scale must be positive")
+        }
+        if v > 0 {
+            sizes++
+        }
+    }
+    if sizes > 1 {
+        return nil, invalidArgf("set only one of poster width, height and scale")
+    }
+    if popts.MarginMM < 0 || popts.OverlapMM < 0 || popts.DPI < 0 {
+        return nil, invalidArgf("poster margin, overlap and DPI must not be negative")
+    }
+    switch popts.Output {
+    case "", PosterImages, PosterPDF:
+    default:
+        return nil, invalidArgf("unsupported poster output: %s", popts.Output)
+    }
+
+    dpi := popts.DPI
+    if dpi == 0 {
+        dpi = 150
+    }
+    paper := popts.Paper
+    if paper.WidthMM == 0 && paper.HeightMM == 0 {
+        paper = PaperA4
+    }
+    if paper.WidthMM <= 0 || paper.HeightMM <= 0 {
+        return nil, invalidArgf("paper size must be positive")
+    }
+    if popts.Landscape {
+        paper.WidthMM, paper.HeightMM = paper.HeightMM, paper.WidthMM
+    }
+    margin := popts.MarginMM
+    if margin == 0 {
+        margin = 10
+    }
+
+    plan := &PosterPlan{DPI: dpi, PaperWidthMM: paper.WidthMM, PaperHeightMM: paper.HeightMM}
+    aspect := float64(imgHeight) / float64(imgWidth)
+    switch {
+    case popts.WidthMM > 0:
+        plan.WidthMM, plan.HeightMM = popts.WidthMM, popts.WidthMM*aspect
+    case popts.HeightMM > 0:
+        plan.WidthMM, plan.HeightMM = popts.HeightMM/aspect, popts.HeightMM
+    default:
+        scale := popts.Scale
+        if scale == 0 {
+            scale = 1
+        }
+        plan.WidthMM = float64(imgWidth) / dpi * 25.4 * scale
+        plan.HeightMM = float64(imgHeight) / dpi * 25.4 * scale
+    }
+
+    widthPx, heightPx := math.Round(plan.WidthMM/25.4*dpi), math.Round(plan.HeightMM/25.4*dpi)
+    if !(max(widthPx, 1)*max(heightPx, 1) <= maxPosterPixels) {
+        return nil, invalidArgf("poster of %.0fx%.0f pixels is too large; lower the DPI", widthPx, heightPx)
+    }
+    plan.WidthPx, plan.HeightPx = max(1, int(widthPx)), max(1, int(heightPx))
+    paperWidthPx, paperHeightPx := math.Round(paper.WidthMM/25.4*dpi), math.Round(paper.HeightMM/25.4*dpi)
+    // Compare so that NaN fails too, before any value is converted to int.
+    for _, px := range []float64{dpi, paperWidthPx * paperHeightPx, margin / 25.4 * dpi, popts.OverlapMM / 25.4 * dpi} {
+        if !(px <= maxPosterPixels) {
+            return nil, invalidArgf("paper, margin or overlap is too large at %g DPI", dpi)
+        }
+    }
+    plan.paperPx = image.Pt(int(paperWidthPx), int(paperHeightPx))
+    plan.marginPx = mmToPx(margin, dpi)
+    printable := plan.paperPx.Sub(image.Pt(2*plan.marginPx, 2*plan.marginPx))
+    overlap := mmToPx(popts.OverlapMM, dpi)
+    if printable.X-overlap <= 0 || printable.Y-overlap <= 0 {
+        return nil, invalidArgf("margins and overlap leave no printable area on the paper")
+    }
+
+    cols := posterSpans(plan.WidthPx, printable.X, overlap)
+    rows := posterSpans(plan.HeightPx, printable.Y, overlap)
+    plan.Rows, plan.Cols = len(rows), len(cols)
+    for r, ys := range rows {
+        for c, xs := range cols {
+            plan.Pages = append(plan.Pages, PosterPage{
+                Label: posterRowLabel(r) + fmt.Sprint(c+1),
+                Row:   r,
+                Col:   c,
+                Index: len(plan.Pages),
+                Rect:  image.Rect(xs.X, ys.X, xs.Y, ys.Y),
+            })
+        }
+    }
+    return plan, nil
+}
+
+// posterSpans splits total pixels into [start, end) spans of at most size
+// pixels, each starting overlap pixels before the end of the previous one.
+func posterSpans(total, size, overlap int) []image.Point {
+    var spans []image.Point
+    for start := 0; ; start += size - overlap {
+        end := min(start+size, total)
+        spans = append(spans, image.Pt(start, end))
+        if end == total {
+            return spans
+        }
+    }
+}
+
+// posterRowLabel returns the spreadsheet style letters of row: A..Z, AA...
+func posterRowLabel(row int) string {
+    label := ""
+    for row++; row > 0; row = (row - 1) / 26 {
+        label = string(rune('A'+(row-1)%26)) + label
+    }
+    return label
+}
+
+func mmToPx(mm, dpi float64) int {
+    return int(math.Round(mm / 25.4 * dpi))
+}
+
+// renderPosterPage draws the poster region of page onto a sheet of paper.
+func renderPosterPage(poster *image.RGBA, plan *PosterPlan, page PosterPage, popts PosterOptions) (*image.RGBA, error) {
+    sheet := image.NewRGBA(image.Rect(0, 0, plan.paperPx.X, plan.paperPx.Y))
+    draw.Draw(sheet, sheet.Bounds(), image.White, image.Point{}, draw.Src)
+
+    origin := image.Pt(plan.marginPx, plan.marginPx)
+    content := page.Rect.Sub(page.Rect.Min).Add(origin)
+    draw.Draw(sheet, content, poster, page.Rect.Min, draw.Src)
+
+    mm := func(v float64) int { return max(1, mmToPx(v, plan.DPI)) }
+    ink := image.NewUniform(color.Black)
+    thickness := max(1, mm(0.2))
+
+    if !popts.NoMarks && plan.marginPx > mm(2) {
+        gap := mm(1)
+        length := min(plan.marginPx-gap-1, mm(6))
+
+        // Crop marks at the corners of the printed area.
+        for _, x := range []int{content.Min.X, content.Max.X - thickness} {
+            drawRect(sheet, image.Rect(x, content.Min.Y-gap-length, x+thickness, content.Min.Y-gap), ink)
+            drawRect(sheet, image.Rect(x, content.Max.Y+gap, x+thickness, content.Max.Y+gap+length), ink)
+        }
+        for _, y := range []int{content.Min.Y, content.Max.Y - thickness} {
+            drawRect(sheet, image.Rect(content.Min.X-gap-length, y, content.Min.X-gap, y+thickness), ink)
+            drawRect(sheet, image.Rect(content.Max.X+gap, y, content.Max.X+gap+length, y+thickness), ink)
+        }
+
+        // Overlap marks: dashed ticks where the neighbouring page's region
+        // begins or ends, i.e. the edges of the glue strip.
+        overlap := mmToPx(popts.OverlapMM, plan.DPI)
+        if overlap > 0 {
+            var xs, ys []int
+            if page.Col > 0 {
+                xs = append(xs, content.Min.X+overlap)
+            }
+            if page.Col < plan.Cols-1 {
+                xs = append(xs, content.Max.X-overlap)
+            }
+            if page.Row > 0 {
+                ys = append(ys, content.Min.Y+overlap)
+            }
+            if page.Row < plan.Rows-1 {
+                ys = append(ys, content.Max.Y-overlap)
+            }
+            for _, x := range xs {
+                drawDashed(sheet, image.Rect(x, content.Min.Y-gap-length, x+thickness, content.Min.Y-gap), ink, true)
+                drawDashed(sheet, image.Rect(x, content.Max.Y+gap, x+thickness, content.Max.Y+gap+length), ink, true)
+            }
+            for _, y := range ys {
+                drawDashed(sheet, image.Rect(content.Min.X-gap-length, y, content.Min.X-gap, y+thickness), ink, false)
+                drawDashed(sheet, image.Rect(content.Max.X+gap, y, content.Max.X+gap+length, y+thickness), ink, false)
+            }
+        }
+
+        // Registration crosses centred on every edge of the printed area.
+        radius := length / 2
+        centerX, centerY := (content.Min.X+content.Max.X)/2, (content.Min.Y+content.Max.Y)/2
+        for _, c := range []image.Point{
+            {centerX, content.Min.Y - gap - radius},
+            {centerX, content.Max.Y + gap + radius},
+            {content.Min.X - gap - radius, centerY},
+            {content.Max.X + gap + radius, centerY},
+        } {
+            drawRegistration(sheet, c, radius, thickness, ink)
+        }
+    }
+
+    if !popts.NoLabels && plan.marginPx > mm(3) {
+        face, err := newFontFace(nil, math.Min(float64(plan.marginPx)*0.6, float64(mm(6))))
+        if err != nil {
+            return nil, fmt.Errorf("load label font: %w", err)
+        }
+        defer face.Close()
+        text := fmt.Sprintf("%s  (row %d/%d, column %d/%d)", page.Label, page.Row+1, plan.Rows, page.Col+1, plan.Cols)
+        metrics := face.Metrics()
+        baseline := plan.paperPx.Y - (plan.marginPx-(metrics.Ascent-metrics.Descent).Ceil())/2
+        d := font.Drawer{Dst: sheet, Src: ink, Face: face, Dot: fixed.P(content.Min.X, baseline)}
+        if d.MeasureString(text).Ceil() < content.Dx()/2 {
+            d.DrawString(text)
+        }
+    }
+    return sheet, nil
+}
+
+func drawRect(dst draw.Image, r image.Rectangle, src image.Image) {
+    draw.Draw(dst, r, src, image.Point{}, draw.Src)
+}
+
+// drawDashed draws r as a dashed line along its longer axis.
+func drawDashed(dst draw.Image, r image.Rectangle, src image.Image, vertical bool) {
+    dash := max(2, max(r.Dx(), r.Dy())/7)
+    if vertical {
+        for y := r.Min.Y; y < r.Max.Y; y += 2 * dash {
+            drawRect(dst, image.Rect(r.Min.X, y, r.Max.X, min(y+dash, r.Max.Y)), src)
+        }
+        return
+    }
+    for x := r.Min.X; x < r.Max.X; x += 2 * dash {
+        drawRect(dst, image.Rect(x, r.Min.Y, min(x+dash, r.Max.X), r.Max.Y), src)
+    }
+}
+
+// drawRegistration draws a circle with a cross through its centre.
+func drawRegistration(dst draw.Image, c image.Point, radius, thickness int, src image.Image) {
+    drawRect(dst, image.Rect(c.X-radius, c.Y, c.X+radius+1, c.Y+thickness), src)
+    drawRect(dst, image.Rect(c.X, c.Y-radius, c.X+thickness, c.Y+radius+1), src)
+    r := float64(radius) * 0.6
+    steps := max(16, int(2*math.Pi*r))
+    for i := 0; i < steps; i++ {
+        a := 2 * math.Pi * float64(i) / float64(steps)
+        x := c.X + int(math.Round(r*math.Cos(a)))
+        y := c.Y + int(math.Round(r*math.Sin(a)))
+        drawRect(dst, image.Rect(x, y, x+thickness, y+thickness), src)
+    }
+}
+
+func writePosterPDF(render func(PosterPage) (*image.RGBA, error), plan *PosterPlan, opts normalizedOptions) (string, error) {
+    path := filepath.Join(opts.outputDir, opts.prefix+".pdf")
+    file, err := opts.output.Create(path)
+    if err != nil {
+        return "", outputErrorf("create output file: %w", err)
+    }
+
+    err = func() error {
+        pdf, err := newPDFWriter(file)
+        if err != nil {
+            return err
+        }
+        widthPt, heightPt := plan.PaperWidthMM/25.4*72, plan.PaperHeightMM/25.4*72
+        for _, page := range plan.Pages {
+            sheet, err := render(page)
+            if err != nil {
+                return err
+            }
+            if opts.watermark != nil {
+                opts.watermark.apply(sheet)
+            }
+            if err := pdf.addPage(sheet, widthPt, heightPt, opts.quality); err != nil {
+                return err
+            }
+        }
+        return pdf.close()
+    }()
+    if err != nil {
+        file.Abort()
+        return "", fmt.Errorf("write pdf: %w", err)
+    }
+    if err := file.Commit(); err != nil {
+        return "", outputErrorf("write output file: %w", err)
+    }
+    return path, nil
+}
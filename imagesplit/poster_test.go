@@ -0,0 +1,162 @@
+package imagesplit
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// At 25.4 DPI one millimetre is one pixel, which keeps the geometry readable.
+const posterTestDPI = 25.4
+
+func TestPlanPosterPagesWithOverlap(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "poster.png", 500, 300, color.RGBA{R: 255, A: 255})
+
+	plan, err := PlanPoster("poster.png", PosterOptions{DPI: posterTestDPI, OverlapMM: 10}, SplitOptions{InputFS: mem})
+	if err != nil {
+		t.Fatalf("PlanPoster returned error: %v", err)
+	}
+	if plan.Rows != 2 || plan.Cols != 3 || len(plan.Pages) != 6 {
+		t.Fatalf("expected a 2x3 page grid, got %dx%d with %d pages", plan.Rows, plan.Cols, len(plan.Pages))
+	}
+	if plan.WidthPx != 500 || plan.HeightPx != 300 {
+		t.Fatalf("unexpected poster size %dx%d", plan.WidthPx, plan.HeightPx)
+	}
+
+	// A4 minus 10mm margins leaves 190x277mm, so columns step by 180mm.
+	expected := map[string]image.Rectangle{
+		"A1": image.Rect(0, 0, 190, 277),
+		"A2": image.Rect(180, 0, 370, 277),
+		"B3": image.Rect(360, 267, 500, 300),
+	}
+	for _, page := range plan.Pages {
+		if want, ok := expected[page.Label]; ok && page.Rect != want {
+			t.Errorf("page %s: expected %v, got %v", page.Label, want, page.Rect)
+		}
+	}
+	if last := plan.Pages[5]; last.Label != "B3" || last.Row != 1 || last.Col != 2 {
+		t.Fatalf("unexpected last page %+v", last)
+	}
+}
+
+func TestPlanPosterPhysicalSize(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "poster.png", 200, 100, color.White)
+
+	plan, err := PlanPoster("poster.png", PosterOptions{
+		WidthMM:   1000,
+		DPI:       posterTestDPI,
+		Paper:     PaperA3,
+		Landscape: true,
+	}, SplitOptions{InputFS: mem})
+	if err != nil {
+		t.Fatalf("PlanPoster returned error: %v", err)
+	}
+	if plan.HeightMM != 500 || plan.PaperWidthMM != 420 {
+		t.Fatalf("unexpected poster %vx%vmm on %vmm wide paper", plan.WidthMM, plan.HeightMM, plan.PaperWidthMM)
+	}
+	// 400x277mm printable: three columns and two rows.
+	if plan.Rows != 2 || plan.Cols != 3 {
+		t.Fatalf("expected a 2x3 page grid, got %dx%d", plan.Rows, plan.Cols)
+	}
+}
+
+func TestPosterSplitWritesPages(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "poster.png", 300, 200, color.RGBA{R: 255, A: 255})
+
+	paths, err := PosterSplit("poster.png", PosterOptions{DPI: posterTestDPI}, SplitOptions{
+		InputFS:   mem,
+		OutputFS:  mem,
+		OutputDir: "pages",
+		Format:    "png",
+	})
+	if err != nil {
+		t.Fatalf("PosterSplit returned error: %v", err)
+	}
+	if len(paths) != 2 || paths[0] != "pages/poster_page_A1.png" || paths[1] != "pages/poster_page_A2.png" {
+		t.Fatalf("unexpected pages %v", paths)
+	}
+
+	img := readImage(t, mem, paths[0])
+	page := cropImage(img, img.Bounds())
+	if page.Bounds().Dx() != 210 || page.Bounds().Dy() != 297 {
+		t.Fatalf("expected an A4 page, got %v", page.Bounds())
+	}
+	if !isRed(page.RGBAAt(15, 15)) {
+		t.Fatalf("expected the image inside the margin, got %v", page.At(15, 15))
+	}
+	if c := color.RGBAModel.Convert(page.At(1, 1)).(color.RGBA); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Fatalf("expected a white page corner, got %v", c)
+	}
+	// The crop mark above the top-left corner of the printed area.
+	if c := color.RGBAModel.Convert(page.At(10, 5)).(color.RGBA); c != (color.RGBA{0, 0, 0, 255}) {
+		t.Fatalf("expected a crop mark, got %v", c)
+	}
+}
+
+func TestPosterSplitWritesPDF(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "poster.png", 500, 300, color.RGBA{B: 255, A: 255})
+
+	paths, err := PosterSplit("poster.png", PosterOptions{DPI: posterTestDPI, OverlapMM: 10, Output: PosterPDF}, SplitOptions{
+		InputFS:   mem,
+		OutputFS:  mem,
+		OutputDir: "out",
+	})
+	if err != nil {
+		t.Fatalf("PosterSplit returned error: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "out/poster.pdf" {
+		t.Fatalf("unexpected output %v", paths)
+	}
+	data, err := mem.ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("read pdf: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("output is not a complete PDF")
+	}
+	if n := bytes.Count(data, []byte("/Type /Page ")); n != 6 {
+		t.Fatalf("expected 6 pages, got %d", n)
+	}
+	if !bytes.Contains(data, []byte("/Count 6")) {
+		t.Fatal("page tree does not list 6 pages")
+	}
+}
+
+func TestPosterRowLabel(t *testing.T) {
+	for row, want := range map[int]string{0: "A", 1: "B", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := posterRowLabel(row); got != want {
+			t.Errorf("row %d: expected %s, got %s", row, want, got)
+		}
+	}
+}
+
+func TestPosterRejectsInvalidOptions(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "poster.png", 100, 100, color.White)
+
+	cases := map[string]PosterOptions{
+		"two sizes":       {WidthMM: 500, Scale: 2},
+		"negative margin": {MarginMM: -1},
+		"no printable":    {MarginMM: 105},
+		"overlap":         {OverlapMM: 300},
+		"output":          {Output: "svg"},
+		"huge width":      {WidthMM: 1e300},
+		"huge DPI":        {DPI: 1e300},
+		"infinite DPI":    {DPI: math.Inf(1)},
+		"huge paper":      {Paper: PaperSize{WidthMM: 1e12, HeightMM: 1e12}},
+		"huge margin":     {MarginMM: 1e300},
+	}
+	for name, popts := range cases {
+		_, err := PosterSplit("poster.png", popts, SplitOptions{InputFS: mem, OutputFS: mem})
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: expected ErrInvalidArgument, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package imagesplit
+
+import (
+    "fmt"
+    "image"
+    "image/color"
+    "math"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// Preset describes a platform-specific slide layout such as an Instagram
+// carousel or a 3x3 profile grid.
+type Preset struct {
+    // Name identifies the preset in the registry (case-insensitive).
+    Name string
+    // Width and Height are the exact pixel dimensions of every slide.
+    Width  int
+    Height int
+    // Rows and Cols fix the slide layout, e.g. 3x3 for a profile grid. When
+    // Cols is zero the preset is a carousel: a single row whose slide count is
+    // chosen from the source aspect ratio.
+    Rows int
+    Cols int
+    // MaxSlides caps the automatically chosen carousel slide count. Zero means
+    // no limit.
+    MaxSlides int
+}
+
+// PresetOptions controls how a source image is adapted to a preset.
+type PresetOptions struct {
+    // Fit selects how the source is matched to the preset aspect ratio.
+    // FitCrop (the default) center-crops, FitPad letterboxes with Background
+    // and FitStretch distorts.
+    Fit FitMode
+    // Background fills the padding when Fit is FitPad. Defaults to white.
+    Background color.Color
+    // Slides overrides the automatically chosen carousel slide count.
+    Slides int
+}
+
+func (p Preset) carousel() bool {
+    return p.Cols == 0
+}
+
+func (p Preset) validate() error {
+    if strings.TrimSpace(p.Name) == "" {
+        return invalidArgf("preset name is required")
+    }
+    if p.Width <= 0 || p.Height <= 0 {
+        return invalidArgf("preset %s: slide dimensions must be greater than zero", p.Name)
+    }
+    if p.Width > maxPresetPixels/p.Height {
+        return invalidArgf("preset %s: a %dx%d slide exceeds the %d pixel limit", p.Name, p.Width, p.Height, maxPresetPixels)
+    }
+    if p.Rows < 0 || p.Cols < 0 || p.MaxSlides < 0 {
+        return invalidArgf("preset %s: rows, cols and max slides must not be negative", p.Name)
+    }
+    if p.Cols > 0 && p.Rows == 0 {
+        return invalidArgf("preset %s: rows must be greater than zero when cols is set", p.Name)
+    }
+    return nil
+}
+
+var (
+    presetsMu sync.RWMutex
+    presets   = map[string]Preset{}
+)
+
+func init() {
+    for _, p := range []Preset{
+        {Name: "instagram-portrait", Width: 1080, Height: 1350, MaxSlides: 20},
+        {Name: "instagram-square", Width: 1080, Height: 1080, MaxSlides: 20},
+        {Name: "instagram-landscape", Width: 1080, Height: 566, MaxSlides: 20},
+        {Name: "instagram-grid", Width: 1080, Height: 1080, Rows: 3, Cols: 3},
+        {Name: "instagram-grid-portrait", Width: 1080, Height: 1350, Rows: 3, Cols: 3},
+    } {
+        if err := RegisterPreset(p); err != nil {
+            panic(err)
+        }
+    }
+}
+
+// RegisterPreset adds or replaces a preset in the registry.
+func RegisterPreset(p Preset) error {
+    if err := p.validate(); err != nil {
+        return err
+    }
+    presetsMu.Lock()
+    defer presetsMu.Unlock()
+    presets[strings.ToLower(strings.TrimSpace(p.Name))] = p
+    return nil
+}
+
+// LookupPreset returns the registered preset with the given name.
+func LookupPreset(name string) (Preset, bool) {
+    presetsMu.RLock()
+    defer presetsMu.RUnlock()
+    p, ok := presets[strings.ToLower(strings.TrimSpace(name))]
+    return p, ok
+}
+
+// Presets returns all registered presets sorted by name.
+func Presets() []Preset {
+    presetsMu.RLock()
+    defer presetsMu.RUnlock()
+    list := make([]Preset, 0, len(presets))
+    for _, p := range presets {
+        list = append(list, p)
+    }
+    sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+    return list
+}
+
+// maxPresetPixels bounds the fitted canvas a preset is cut from.
+const maxPresetPixels = 500_000_000
+
+// layout returns the rows and columns used for a source of the given size.
+func (p Preset) layout(srcWidth, srcHeight int, override int) (int, int) {
+    if !p.carousel() {
+        return p.Rows, p.Cols
+    }
+    if override > 0 {
+        return 1, override
+    }
+    slides := int(math.Round(float64(srcWidth) * float64(p.Height) / (float64(srcHeight) * float64(p.Width))))
+    if slides < 1 {
+        slides = 1
+    }
+    if p.MaxSlides > 0 && slides > p.MaxSlides {
+        slides = p.MaxSlides
+    }
+    return 1, slides
+}
+
+func presetSplit(inputPath, name string, popts PresetOptions, opts SplitOptions) ([]string, error) {
+    preset, ok := LookupPreset(name)
+    if !ok {
+        return nil, invalidArgf("unknown preset: %s", name)
+    }
+    if popts.Slides < 0 {
+        return nil, invalidArgf("slides must not be negative")
+    }
+    if preset.MaxSlides > 0 && popts.Slides > preset.MaxSlides {
+        return nil, invalidArgf("preset %s allows at most %d slides, got %d", name, preset.MaxSlides, popts.Slides)
+    }
+    fit, err := normalizeFitMode(popts.Fit)
+    if err != nil {
+        return nil, err
+    }
+    bg := popts.Background
+    if bg == nil {
+        bg = color.White
+    }
+
+    progress := newProgressTracker(opts.Observer, 1)
+    return progress.track(inputPath, func() ([]string, error) {
+        ctx, err := prepareSplit(inputPath, opts)
+        if err != nil {
+            return nil, err
+        }
+        ctx.progress = progress
+
+        rows, cols := preset.layout(ctx.bounds.Dx(), ctx.bounds.Dy(), popts.Slides)
+        if cols > maxPresetPixels/(preset.Width*preset.Height)/rows {
+            return nil, invalidArgf("preset %s: %dx%d slides of %dx%d exceed the %d pixel limit", name, rows, cols, preset.Width, preset.Height, maxPresetPixels)
+        }
+        ctx.img = fitImage(ctx.img, cols*preset.Width, rows*preset.Height, fit, bg)
+        ctx.bounds = ctx.img.Bounds()
+
+        tiles := make([]TilePlan, 0, rows*cols)
+        for r := 0; r < rows; r++ {
+            for c := 0; c < cols; c++ {
+                var name string
+                if preset.carousel() {
+                    name = fmt.Sprintf("%s_slide_%d", ctx.options.prefix, c)
+                } else {
+                    name = fmt.Sprintf("%s_row%d_col%d", ctx.options.prefix, r, c)
+                }
+                tiles = append(tiles, TilePlan{
+                    Name:  name,
+                    Path:  tilePath(ctx.options, name),
+                    Rect:  image.Rect(c*preset.Width, r*preset.Height, (c+1)*preset.Width, (r+1)*preset.Height),
+                    Row:   r,
+                    Col:   c,
+                    Index: r*cols + c,
+                })
+            }
+        }
+        tiles, err = selectTiles(tiles, ctx.options.selection)
+        if err != nil {
+            return nil, err
+        }
+        return writeTiles(ctx, tiles)
+    })
+}
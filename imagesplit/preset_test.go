@@ -0,0 +1,219 @@
+package imagesplit
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePanoramaPNG(t *testing.T, width, height int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 64, A: 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "panorama.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create panorama: %v", err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("encode panorama: %v", err)
+	}
+	return path
+}
+
+func decodePNGFile(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return img
+}
+
+func TestBuiltinPresets(t *testing.T) {
+	for _, name := range []string{"instagram-portrait", "instagram-square", "instagram-grid"} {
+		if _, ok := LookupPreset(name); !ok {
+			t.Errorf("expected built-in preset %s", name)
+		}
+	}
+	if len(Presets()) < 3 {
+		t.Fatalf("expected built-in presets to be listed")
+	}
+}
+
+func TestRegisterPresetInvalid(t *testing.T) {
+	if err := RegisterPreset(Preset{Name: "", Width: 10, Height: 10}); err == nil {
+		t.Fatalf("expected error for missing name")
+	}
+	if err := RegisterPreset(Preset{Name: "bad", Width: 0, Height: 10}); err == nil {
+		t.Fatalf("expected error for zero width")
+	}
+	if err := RegisterPreset(Preset{Name: "bad", Width: 10, Height: 10, Cols: 3}); err == nil {
+		t.Fatalf("expected error for cols without rows")
+	}
+	if err := RegisterPreset(Preset{Name: "bad", Width: 1 << 20, Height: 1 << 20}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument for an oversized slide, got %v", err)
+	}
+}
+
+func TestPresetSplitCarousel(t *testing.T) {
+	if err := RegisterPreset(Preset{Name: "test-carousel", Width: 8, Height: 10, MaxSlides: 10}); err != nil {
+		t.Fatalf("RegisterPreset: %v", err)
+	}
+	src := writePanoramaPNG(t, 41, 10)
+	outDir := t.TempDir()
+
+	files, err := PresetSplit(src, "TEST-CAROUSEL", PresetOptions{}, SplitOptions{OutputDir: outDir})
+	if err != nil {
+		t.Fatalf("PresetSplit returned error: %v", err)
+	}
+	if len(files) != 5 {
+		t.Fatalf("expected 5 slides, got %d", len(files))
+	}
+	for i, path := range files {
+		if !strings.HasSuffix(path, fmt.Sprintf("panorama_slide_%d.png", i)) {
+			t.Errorf("unexpected slide name: %s", path)
+		}
+		b := decodePNGFile(t, path).Bounds()
+		if b.Dx() != 8 || b.Dy() != 10 {
+			t.Errorf("slide %d: expected 8x10, got %dx%d", i, b.Dx(), b.Dy())
+		}
+	}
+
+	files, err = PresetSplit(src, "test-carousel", PresetOptions{Slides: 2}, SplitOptions{OutputDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("PresetSplit with slide override returned error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 slides, got %d", len(files))
+	}
+}
+
+func TestPresetSplitGridPad(t *testing.T) {
+	if err := RegisterPreset(Preset{Name: "test-grid", Width: 4, Height: 4, Rows: 2, Cols: 2}); err != nil {
+		t.Fatalf("RegisterPreset: %v", err)
+	}
+	src := writePanoramaPNG(t, 16, 8)
+
+	files, err := PresetSplit(src, "test-grid", PresetOptions{Fit: FitPad, Background: color.RGBA{R: 255, A: 255}}, SplitOptions{OutputDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("PresetSplit returned error: %v", err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("expected 4 tiles, got %d", len(files))
+	}
+	if !strings.HasSuffix(files[3], "panorama_row1_col1.png") {
+		t.Errorf("unexpected grid tile name: %s", files[3])
+	}
+
+	// The 2:1 source is letterboxed into the square canvas, so the top rows of
+	// the first tile are padding.
+	top := decodePNGFile(t, files[0])
+	if got := color.RGBAModel.Convert(top.At(0, 0)).(color.RGBA); got != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("expected background padding, got %v", got)
+	}
+}
+
+func TestPresetSplitErrors(t *testing.T) {
+	src := writePanoramaPNG(t, 16, 8)
+	if _, err := PresetSplit(src, "no-such-preset", PresetOptions{}, SplitOptions{}); err == nil {
+		t.Fatalf("expected error for unknown preset")
+	}
+	if _, err := PresetSplit(src, "instagram-square", PresetOptions{Fit: "zoom"}, SplitOptions{}); err == nil {
+		t.Fatalf("expected error for unsupported fit mode")
+	}
+	if _, err := PresetSplit(src, "instagram-square", PresetOptions{Slides: 21}, SplitOptions{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument for too many slides, got %v", err)
+	}
+
+	// Without MaxSlides only the canvas size limits the slide count.
+	if err := RegisterPreset(Preset{Name: "test-huge", Width: 10000, Height: 10000}); err != nil {
+		t.Fatalf("RegisterPreset returned error: %v", err)
+	}
+	if _, err := PresetSplit(src, "test-huge", PresetOptions{Slides: 1 << 40}, SplitOptions{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument for a huge slide count, got %v", err)
+	}
+	wide := writePanoramaPNG(t, 48, 8)
+	if _, err := PresetSplit(wide, "test-huge", PresetOptions{}, SplitOptions{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument for an oversized canvas, got %v", err)
+	}
+}
+
+func TestResizeImagePreservesUniformColor(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 13, 7))
+	fill := color.RGBA{R: 10, G: 120, B: 200, A: 255}
+	for i := 0; i < len(src.Pix); i += 4 {
+		src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3] = fill.R, fill.G, fill.B, fill.A
+	}
+	for _, size := range [][2]int{{5, 3}, {40, 21}, {13, 7}} {
+		dst := resizeImage(src, size[0], size[1])
+		if dst.Bounds().Dx() != size[0] || dst.Bounds().Dy() != size[1] {
+			t.Fatalf("unexpected size %v", dst.Bounds())
+		}
+		for y := 0; y < size[1]; y++ {
+			for x := 0; x < size[0]; x++ {
+				if got := dst.RGBAAt(x, y); got != fill {
+					t.Fatalf("resize to %v: pixel (%d,%d) = %v, want %v", size, x, y, got, fill)
+				}
+			}
+		}
+	}
+}
+
+func TestResizeImagePassOrderAgrees(t *testing.T) {
+	// A horizontal ramp in red and a vertical ramp in green, so that both
+	// axes are resampled non-trivially.
+	src := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 5), B: 90, A: 255})
+		}
+	}
+	// 16x24 shrinks horizontally most and takes the horizontal pass first,
+	// 48x6 shrinks vertically most and takes the vertical pass first.
+	for _, size := range [][2]int{{16, 24}, {48, 6}} {
+		dst := resizeImage(src, size[0], size[1])
+		xWeights, yWeights := resampleWeights(64, size[0]), resampleWeights(48, size[1])
+		for y, yws := range yWeights {
+			for x, xws := range xWeights {
+				var r, g float64
+				for _, wy := range yws {
+					for _, wx := range xws {
+						p := src.RGBAAt(wx.index, wy.index)
+						r += float64(p.R) * wx.weight * wy.weight
+						g += float64(p.G) * wx.weight * wy.weight
+					}
+				}
+				want := color.RGBA{R: uint8(math.Round(r)), G: uint8(math.Round(g)), B: 90, A: 255}
+				got := dst.RGBAAt(x, y)
+				if absDiff(got.R, want.R) > 1 || absDiff(got.G, want.G) > 1 || got.B != want.B || got.A != want.A {
+					t.Fatalf("resize to %v: pixel (%d,%d) = %v, want %v", size, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
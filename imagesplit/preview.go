@@ -0,0 +1,238 @@
+package imagesplit
+
+import (
+    "fmt"
+    "image"
+    "image/color"
+    "image/draw"
+    "image/png"
+    "math"
+    "path/filepath"
+    "strings"
+
+    "golang.org/x/image/font"
+    "golang.org/x/image/math/fixed"
+)
+
+// PreviewLabel selects the text drawn into each cell of a preview.
+type PreviewLabel string
+
+const (
+    // PreviewLabelRowCol labels cells with their row and column, e.g. "r0 c2".
+    PreviewLabelRowCol PreviewLabel = "rowcol"
+    // PreviewLabelIndex labels cells with their tile index, e.g. "#5".
+    PreviewLabelIndex PreviewLabel = "index"
+    // PreviewLabelNone draws the cut lines only.
+    PreviewLabelNone PreviewLabel = "none"
+)
+
+// PreviewOptions configures a split preview.
+type PreviewOptions struct {
+    // MaxWidth and MaxHeight bound the preview size. The source image is
+    // scaled down, keeping its aspect ratio, to fit. Zero uses 1024.
+    MaxWidth  int
+    MaxHeight int
+    // LineColor is the color of the cut lines. Defaults to red.
+    LineColor color.Color
+    // LineWidth is the width of the cut lines in preview pixels. Defaults
+    // to 2.
+    LineWidth int
+    // Label selects the cell labels. PreviewGrid defaults to
+    // PreviewLabelRowCol and PreviewTile to PreviewLabelIndex.
+    Label PreviewLabel
+}
+
+const defaultPreviewSize = 1024
+
+// PreviewGrid writes a PNG preview of the input image to outputPath with
+// the cells GridSplit would cut for the same arguments drawn as labelled
+// rectangles. opts.InputFS and opts.OutputFS are honoured.
+func PreviewGrid(inputPath string, rows, cols int, outputPath string, opts SplitOptions, popts PreviewOptions) error {
+    plan, err := PlanGrid(inputPath, rows, cols, opts)
+    if err != nil {
+        return err
+    }
+    if popts.Label == "" {
+        popts.Label = PreviewLabelRowCol
+    }
+    return writePreview(plan, outputPath, opts, popts)
+}
+
+// PreviewTile writes a PNG preview of the tiles TileSplit would cut for the
+// same arguments. See PreviewGrid.
+func PreviewTile(inputPath string, tileWidth, tileHeight int, outputPath string, opts SplitOptions, popts PreviewOptions) error {
+    plan, err := PlanTile(inputPath, tileWidth, tileHeight, opts)
+    if err != nil {
+        return err
+    }
+    if popts.Label == "" {
+        popts.Label = PreviewLabelIndex
+    }
+    return writePreview(plan, outputPath, opts, popts)
+}
+
+// RenderPreview draws the tiles of plan over its source image, read through
+// opts.InputFS and transformed by opts.Transforms, and returns the scaled
+// preview. Transparent areas are drawn over opts.Background when it is set,
+// e.g. a checkerboard. It works with any plan, for
+// example one returned by PlanGridLayout.
+func RenderPreview(plan *SplitPlan, opts SplitOptions, popts PreviewOptions) (*image.RGBA, error) {
+    label, err := normalizePreviewLabel(popts.Label)
+    if err != nil {
+        return nil, err
+    }
+    maxWidth, maxHeight := popts.MaxWidth, popts.MaxHeight
+    if maxWidth <= 0 {
+        maxWidth = defaultPreviewSize
+    }
+    if maxHeight <= 0 {
+        maxHeight = defaultPreviewSize
+    }
+    lineWidth := popts.LineWidth
+    if lineWidth <= 0 {
+        lineWidth = 2
+    }
+    lineColor := popts.LineColor
+    if lineColor == nil {
+        lineColor = color.RGBA{R: 255, A: 255}
+    }
+
+    img, _, err := loadImage(newInputSource(opts.InputFS), plan.InputPath)
+    if err != nil {
+        return nil, err
+    }
+    img, err = applyTransforms(img, opts.Transforms)
+    if err != nil {
+        return nil, err
+    }
+    b := img.Bounds()
+    scale := math.Min(1, math.Min(float64(maxWidth)/float64(b.Dx()), float64(maxHeight)/float64(b.Dy())))
+    width := clampInt(int(math.Round(float64(b.Dx())*scale)), 1, b.Dx())
+    height := clampInt(int(math.Round(float64(b.Dy())*scale)), 1, b.Dy())
+    preview := resizeImage(img, width, height)
+    if opts.Background != nil {
+        background, err := prepareBackground(opts.Background, "")
+        if err != nil {
+            return nil, err
+        }
+        background.flatten(preview, image.Point{})
+    }
+
+    toPreview := func(r image.Rectangle) image.Rectangle {
+        r = r.Sub(b.Min)
+        return image.Rect(
+            int(math.Floor(float64(r.Min.X)*scale)), int(math.Floor(float64(r.Min.Y)*scale)),
+            int(math.Ceil(float64(r.Max.X)*scale)), int(math.Ceil(float64(r.Max.Y)*scale)),
+        )
+    }
+
+    var face font.Face
+    if label != PreviewLabelNone {
+        face, err = newFontFace(nil, previewFontSize(plan, scale))
+        if err != nil {
+            return nil, fmt.Errorf("load preview font: %w", err)
+        }
+        defer face.Close()
+    }
+
+    line := image.NewUniform(lineColor)
+    for _, tile := range plan.Tiles {
+        r := toPreview(tile.Rect)
+        drawOutline(preview, r, lineWidth, line)
+        if face != nil {
+            text := fmt.Sprintf("r%d c%d", tile.Row, tile.Col)
+            if label == PreviewLabelIndex {
+                text = fmt.Sprintf("#%d", tile.Index)
+            }
+            drawLabel(preview, r, lineWidth, text, face)
+        }
+    }
+    return preview, nil
+}
+
+func normalizePreviewLabel(label PreviewLabel) (PreviewLabel, error) {
+    switch PreviewLabel(strings.ToLower(strings.TrimSpace(string(label)))) {
+    case "", PreviewLabelRowCol:
+        return PreviewLabelRowCol, nil
+    case PreviewLabelIndex:
+        return PreviewLabelIndex, nil
+    case PreviewLabelNone:
+        return PreviewLabelNone, nil
+    default:
+        return "", invalidArgf("unsupported preview label: %s", label)
+    }
+}
+
+func writePreview(plan *SplitPlan, outputPath string, opts SplitOptions, popts PreviewOptions) error {
+    if strings.TrimSpace(outputPath) == "" {
+        return invalidArgf("preview output path is required")
+    }
+    preview, err := RenderPreview(plan, opts, popts)
+    if err != nil {
+        return err
+    }
+
+    out := outputFS(opts.OutputFS)
+    if err := out.MkdirAll(filepath.Dir(outputPath)); err != nil {
+        return outputErrorf("create output directory: %w", err)
+    }
+    file, err := out.Create(outputPath)
+    if err != nil {
+        return outputErrorf("create preview file: %w", err)
+    }
+    if err := png.Encode(file, preview); err != nil {
+        file.Abort()
+        return fmt.Errorf("encode preview: %w", err)
+    }
+    if err := file.Commit(); err != nil {
+        return outputErrorf("write preview file: %w", err)
+    }
+    return nil
+}
+
+// previewFontSize picks a label size that fits the smallest cell.
+func previewFontSize(plan *SplitPlan, scale float64) float64 {
+    smallest := math.MaxFloat64
+    for _, tile := range plan.Tiles {
+        smallest = math.Min(smallest, math.Min(float64(tile.Rect.Dx()), float64(tile.Rect.Dy()))*scale)
+    }
+    return math.Max(8, math.Min(32, smallest/4))
+}
+
+// drawOutline draws the border of r with the given width inside r.
+func drawOutline(dst draw.Image, r image.Rectangle, width int, src image.Image) {
+    width = min(width, r.Dx(), r.Dy())
+    edges := []image.Rectangle{
+        image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width),
+        image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y),
+        image.Rect(r.Min.X, r.Min.Y, r.Min.X+width, r.Max.Y),
+        image.Rect(r.Max.X-width, r.Min.Y, r.Max.X, r.Max.Y),
+    }
+    for _, e := range edges {
+        draw.Draw(dst, e, src, image.Point{}, draw.Src)
+    }
+}
+
+// drawLabel writes text in the top-left corner of cell r on a translucent
+// dark box so that it stays readable on any image. Labels that do not fit
+// the cell are skipped.
+func drawLabel(dst draw.Image, r image.Rectangle, inset int, text string, face font.Face) {
+    metrics := face.Metrics()
+    textWidth := font.MeasureString(face, text).Ceil()
+    textHeight := (metrics.Ascent + metrics.Descent).Ceil()
+    pad := max(2, textHeight/6)
+
+    box := image.Rect(0, 0, textWidth+2*pad, textHeight+2*pad).Add(r.Min.Add(image.Pt(inset, inset)))
+    if !box.In(r) {
+        return
+    }
+    draw.Draw(dst, box, image.NewUniform(color.RGBA{A: 160}), image.Point{}, draw.Over)
+
+    d := font.Drawer{
+        Dst:  dst,
+        Src:  image.White,
+        Face: face,
+        Dot:  fixed.P(box.Min.X+pad, box.Min.Y+pad).Add(fixed.Point26_6{Y: metrics.Ascent}),
+    }
+    d.DrawString(text)
+}
@@ -0,0 +1,89 @@
+package imagesplit
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func isRed(c color.RGBA) bool {
+	return c.R == 255 && c.G == 0 && c.B == 0
+}
+
+func TestPreviewGridScaledWithCutLines(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "big.png", 400, 200, color.White)
+	opts := SplitOptions{InputFS: mem, OutputFS: mem}
+
+	if err := PreviewGrid("big.png", 2, 4, "previews/big.png", opts, PreviewOptions{MaxWidth: 200, LineWidth: 1}); err != nil {
+		t.Fatalf("PreviewGrid returned error: %v", err)
+	}
+	img := readImage(t, mem, "previews/big.png")
+	if img.Bounds() != image.Rect(0, 0, 200, 100) {
+		t.Fatalf("unexpected preview size %v", img.Bounds())
+	}
+	preview := cropImage(img, img.Bounds())
+
+	// Columns are cut at 100, 200 and 300 source pixels, i.e. every 50
+	// preview pixels; the row is cut at 100 source pixels.
+	for _, x := range []int{49, 50, 99, 100, 149, 150} {
+		if !isRed(preview.RGBAAt(x, 75)) {
+			t.Errorf("expected a cut line at x=%d, got %v", x, preview.RGBAAt(x, 75))
+		}
+	}
+	if !isRed(preview.RGBAAt(25, 50)) || !isRed(preview.RGBAAt(25, 49)) {
+		t.Errorf("expected a cut line at y=50")
+	}
+	if c := preview.RGBAAt(40, 75); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("expected untouched pixel inside a cell, got %v", c)
+	}
+	// The label box darkens the top-left corner of each cell.
+	if c := preview.RGBAAt(53, 53); c.R == 255 && c.G == 255 {
+		t.Errorf("expected a label in the cell at column 1")
+	}
+}
+
+func TestRenderPreviewMatchesTilePlan(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "img.png", 90, 50, color.White)
+	opts := SplitOptions{InputFS: mem}
+
+	plan, err := PlanTile("img.png", 40, 40, opts)
+	if err != nil {
+		t.Fatalf("PlanTile returned error: %v", err)
+	}
+	preview, err := RenderPreview(plan, opts, PreviewOptions{LineWidth: 1, Label: PreviewLabelNone})
+	if err != nil {
+		t.Fatalf("RenderPreview returned error: %v", err)
+	}
+	if preview.Bounds() != image.Rect(0, 0, 90, 50) {
+		t.Fatalf("preview must not be enlarged, got %v", preview.Bounds())
+	}
+	for _, tile := range plan.Tiles {
+		r := tile.Rect
+		for _, p := range []image.Point{r.Min, {r.Max.X - 1, r.Max.Y - 1}} {
+			if !isRed(preview.RGBAAt(p.X, p.Y)) {
+				t.Errorf("tile %d: expected outline at %v", tile.Index, p)
+			}
+		}
+	}
+	if c := preview.RGBAAt(20, 20); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("expected no label, got %v at cell center", c)
+	}
+}
+
+func TestPreviewValidation(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "img.png", 10, 10, color.White)
+	opts := SplitOptions{InputFS: mem, OutputFS: mem}
+	if err := PreviewTile("img.png", 5, 5, "", opts, PreviewOptions{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected error for empty output path, got %v", err)
+	}
+	if err := PreviewGrid("img.png", 1, 1, "p.png", opts, PreviewOptions{Label: "names"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected error for unsupported label, got %v", err)
+	}
+	if err := PreviewGrid("img.png", 0, 1, "p.png", opts, PreviewOptions{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected error for zero rows, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package imagesplit
+
+import (
+    "image"
+    "sync"
+    "time"
+)
+
+// EventType identifies the kind of progress Event.
+type EventType string
+
+const (
+    // EventImageStarted is sent before an image is decoded.
+    EventImageStarted EventType = "image_started"
+    // EventTileWritten is sent after each tile has been written.
+    EventTileWritten EventType = "tile_written"
+    // EventImageFinished is sent after all tiles of an image were written.
+    EventImageFinished EventType = "image_finished"
+    // EventImageSkipped is sent when SplitDirectory leaves an image alone
+    // because its output is already up to date.
+    EventImageSkipped EventType = "image_skipped"
+    // EventImageFailed is sent when splitting an image returns an error.
+    EventImageFailed EventType = "image_failed"
+    // EventBatchFinished is sent once SplitDirectory returns.
+    EventBatchFinished EventType = "batch_finished"
+    // EventWarning reports a problem that did not stop the split, such as
+    // transparency flattened under AlphaWarn.
+    EventWarning EventType = "warning"
+)
+
+// Event describes progress of a split operation.
+type Event struct {
+    Type EventType
+    // InputPath is the source image. It is empty for EventBatchFinished.
+    InputPath string
+    // TilePath, Rect and TileIndex describe the tile for EventTileWritten.
+    TilePath  string
+    Rect      image.Rectangle
+    TileIndex int
+    // Bytes is the encoded size of the tile for EventTileWritten.
+    Bytes int64
+    // Quality is the JPEG quality the tile was encoded with for
+    // EventTileWritten, which varies per tile with SplitOptions.SizeLimit.
+    // It is zero for PNG tiles.
+    Quality int
+    // EncodedSize is the pixel size of the written tile for
+    // EventTileWritten. It is smaller than Rect when a size limit
+    // downscaled the tile.
+    EncodedSize image.Point
+    // Duration is the elapsed time of the image for EventImageFinished and
+    // EventImageFailed, or of the whole batch for EventBatchFinished.
+    Duration time.Duration
+    // Err is set for EventImageFailed and for a failed EventBatchFinished.
+    Err error
+    // Warning describes the problem for EventWarning.
+    Warning string
+    // Progress holds the aggregate counters after the event was applied.
+    Progress Progress
+}
+
+// Progress holds aggregate counters of a split operation.
+type Progress struct {
+    // ImagesTotal is the number of images in the operation (1 for a single
+    // split).
+    ImagesTotal int
+    // ImagesDone counts finished, skipped and failed images.
+    ImagesDone   int
+    ImagesFailed int
+    TilesWritten int
+    BytesWritten int64
+}
+
+// Observer receives progress events. Calls belonging to one operation are
+// serialized and carry that operation's counters. To share an observer between
+// operations running concurrently, wrap it with SyncObserver.
+type Observer func(Event)
+
+// SyncObserver returns an Observer that serializes calls to o, so that o can be
+// shared by concurrent GridSplit, TileSplit or SplitDirectory calls without
+// its own locking. o must not start another split synchronously.
+func SyncObserver(o Observer) Observer {
+    var mu sync.Mutex
+    return func(e Event) {
+        mu.Lock()
+        defer mu.Unlock()
+        o(e)
+    }
+}
+
+// progressTracker keeps the counters of one operation and forwards events to
+// an observer. A nil tracker ignores all events.
+type progressTracker struct {
+    mu       sync.Mutex
+    observer Observer
+    progress Progress
+}
+
+func newProgressTracker(observer Observer, total int) *progressTracker {
+    if observer == nil {
+        return nil
+    }
+    return &progressTracker{observer: observer, progress: Progress{ImagesTotal: total}}
+}
+
+func (p *progressTracker) setTotal(total int) {
+    if p == nil {
+        return
+    }
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.progress.ImagesTotal = total
+}
+
+func (p *progressTracker) emit(event Event, update func(*Progress)) {
+    if p == nil {
+        return
+    }
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if update != nil {
+        update(&p.progress)
+    }
+    event.Progress = p.progress
+    p.observer(event)
+}
+
+// track runs fn for inputPath and reports its start and outcome.
+func (p *progressTracker) track(inputPath string, fn func() ([]string, error)) ([]string, error) {
+    start := time.Now()
+    p.emit(Event{Type: EventImageStarted, InputPath: inputPath}, nil)
+
+    files, err := fn()
+    if err != nil {
+        p.imageFailed(inputPath, time.Since(start), err)
+        return nil, err
+    }
+
+    p.emit(Event{Type: EventImageFinished, InputPath: inputPath, Duration: time.Since(start)}, func(pr *Progress) {
+        pr.ImagesDone++
+    })
+    return files, nil
+}
+
+func (p *progressTracker) tileWritten(inputPath string, tile TilePlan, saved savedTile) {
+    p.emit(Event{
+        Type:        EventTileWritten,
+        InputPath:   inputPath,
+        TilePath:    saved.path,
+        Rect:        tile.Rect,
+        TileIndex:   tile.Index,
+        Bytes:       saved.bytes,
+        Quality:     saved.quality,
+        EncodedSize: saved.size,
+    }, func(pr *Progress) {
+        pr.TilesWritten++
+        pr.BytesWritten += saved.bytes
+    })
+}
+
+func (p *progressTracker) warning(inputPath, message string) {
+    p.emit(Event{Type: EventWarning, InputPath: inputPath, Warning: message}, nil)
+}
+
+func (p *progressTracker) imageFailed(inputPath string, elapsed time.Duration, err error) {
+    p.emit(Event{Type: EventImageFailed, InputPath: inputPath, Duration: elapsed, Err: err}, func(pr *Progress) {
+        pr.ImagesDone++
+        pr.ImagesFailed++
+    })
+}
+
+func (p *progressTracker) imageSkipped(inputPath string) {
+    p.emit(Event{Type: EventImageSkipped, InputPath: inputPath}, func(pr *Progress) {
+        pr.ImagesDone++
+    })
+}
+
+func (p *progressTracker) batchFinished(start time.Time, err error) {
+    p.emit(Event{Type: EventBatchFinished, Duration: time.Since(start), Err: err}, nil)
+}
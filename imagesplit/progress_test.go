@@ -0,0 +1,152 @@
+package imagesplit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	testdata "github.com/zsq2010/utils/imagesplit/testdata"
+)
+
+func TestGridSplitObserver(t *testing.T) {
+	pngPath, _ := createSampleImages(t)
+
+	var events []Event
+	files, err := GridSplit(pngPath, 2, 2, SplitOptions{
+		OutputDir: t.TempDir(),
+		Observer:  func(e Event) { events = append(events, e) },
+	})
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+
+	if len(events) != 6 {
+		t.Fatalf("expected 6 events, got %d", len(events))
+	}
+	if events[0].Type != EventImageStarted || events[5].Type != EventImageFinished {
+		t.Fatalf("unexpected first/last events: %s, %s", events[0].Type, events[5].Type)
+	}
+	var bytes int64
+	for i, e := range events[1:5] {
+		if e.Type != EventTileWritten {
+			t.Fatalf("expected tile event, got %s", e.Type)
+		}
+		if e.TilePath != files[i] || e.TileIndex != i || e.Rect.Empty() {
+			t.Errorf("unexpected tile event %+v", e)
+		}
+		info, err := os.Stat(e.TilePath)
+		if err != nil {
+			t.Fatalf("stat tile: %v", err)
+		}
+		if e.Bytes != info.Size() {
+			t.Errorf("tile %d: reported %d bytes, file has %d", i, e.Bytes, info.Size())
+		}
+		bytes += e.Bytes
+	}
+	final := events[5].Progress
+	if final.ImagesTotal != 1 || final.ImagesDone != 1 || final.TilesWritten != 4 || final.BytesWritten != bytes {
+		t.Errorf("unexpected final progress: %+v", final)
+	}
+}
+
+func TestSplitDirectoryObserver(t *testing.T) {
+	inputDir := t.TempDir()
+	if err := testdata.WriteGradientPNG(filepath.Join(inputDir, "a.png")); err != nil {
+		t.Fatalf("write gradient png: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "b.png"), []byte("broken"), 0o644); err != nil {
+		t.Fatalf("write broken image: %v", err)
+	}
+
+	counts := map[EventType]int{}
+	var last Event
+	_, err := SplitDirectory(inputDir, t.TempDir(), DirectorySplitConfig{
+		Mode:       DirectorySplitModeTile,
+		Options:    SplitOptions{Observer: func(e Event) { counts[e.Type]++; last = e }},
+		TileWidth:  5,
+		TileHeight: 5,
+	})
+	if err == nil {
+		t.Fatalf("expected error for broken image")
+	}
+
+	if counts[EventImageStarted] != 2 || counts[EventImageFinished] != 1 || counts[EventImageFailed] != 1 {
+		t.Errorf("unexpected event counts: %v", counts)
+	}
+	if counts[EventTileWritten] != 4 {
+		t.Errorf("expected 4 tile events, got %d", counts[EventTileWritten])
+	}
+	if last.Type != EventBatchFinished || last.Err == nil {
+		t.Fatalf("expected failed batch to finish last, got %+v", last)
+	}
+	if last.Progress.ImagesTotal != 2 || last.Progress.ImagesDone != 2 || last.Progress.ImagesFailed != 1 {
+		t.Errorf("unexpected batch progress: %+v", last.Progress)
+	}
+}
+
+func TestObserverConcurrentSplits(t *testing.T) {
+	pngPath, _ := createSampleImages(t)
+
+	tiles := 0
+	observer := SyncObserver(func(e Event) {
+		if e.Type == EventTileWritten {
+			tiles++
+		}
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(outDir string) {
+			defer wg.Done()
+			if _, err := TileSplit(pngPath, 5, 5, SplitOptions{OutputDir: outDir, Observer: observer}); err != nil {
+				t.Errorf("TileSplit returned error: %v", err)
+			}
+		}(t.TempDir())
+	}
+	wg.Wait()
+
+	if tiles != 16 {
+		t.Fatalf("expected 16 tile events, got %d", tiles)
+	}
+}
+
+// failingRemoveFS refuses to remove directories.
+type failingRemoveFS struct {
+	*MemFS
+}
+
+func (failingRemoveFS) RemoveAll(string) error {
+	return errors.New("device busy")
+}
+
+func TestSplitDirectoryObserverCleanFailure(t *testing.T) {
+	mem := NewMemFS()
+	writeNoisePNG(t, mem, "in/a.png", 20, 20)
+	writeNoisePNG(t, mem, "in/b.png", 20, 20)
+
+	counts := map[EventType]int{}
+	var last Event
+	_, err := SplitDirectory("in", "out", DirectorySplitConfig{
+		Mode:      DirectorySplitModeGrid,
+		Rows:      2,
+		Cols:      2,
+		Overwrite: OverwriteClean,
+		Options: SplitOptions{
+			InputFS:  mem,
+			OutputFS: failingRemoveFS{mem},
+			Observer: func(e Event) { counts[e.Type]++; last = e },
+		},
+	})
+	if err == nil {
+		t.Fatal("expected the failed removal to fail the batch")
+	}
+	if counts[EventImageFailed] != 1 {
+		t.Errorf("expected a failure event for the image, got %v", counts)
+	}
+	if last.Type != EventBatchFinished || last.Progress.ImagesDone != last.Progress.ImagesFailed || last.Progress.ImagesFailed != 1 {
+		t.Errorf("unexpected batch progress: %+v", last.Progress)
+	}
+}
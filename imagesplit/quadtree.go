@@ -0,0 +1,329 @@
+package imagesplit
+
+import (
+    "encoding/json"
+    "fmt"
+    "image"
+    "math"
+    "path/filepath"
+    "strings"
+)
+
+// DetailMetric measures how much detail a region of an image contains.
+type DetailMetric string
+
+const (
+    // DetailVariance is the variance of the luminance, from 0 to about
+    // 16256.
+    DetailVariance DetailMetric = "variance"
+    // DetailEdges is the fraction of pixels, from 0 to 1, whose luminance
+    // gradient exceeds QuadtreeOptions.EdgeThreshold.
+    DetailEdges DetailMetric = "edges"
+    // DetailEntropy is the Shannon entropy of the luminance histogram, from
+    // 0 to 8 bits.
+    DetailEntropy DetailMetric = "entropy"
+)
+
+// QuadtreeOptions configures QuadtreeSplit and PlanQuadtree.
+type QuadtreeOptions struct {
+    // Metric measures the detail of a region. Defaults to DetailVariance.
+    Metric DetailMetric
+    // Threshold is the detail above which a region is subdivided. Zero
+    // selects the default of 100 for DetailVariance, 0.05 for DetailEdges
+    // and 4 for DetailEntropy; use a tiny positive value such as 1e-9 to
+    // subdivide every region that is not perfectly flat.
+    Threshold float64
+    // MinSize is the smallest width and height a quadrant may have; regions
+    // whose quadrants would be smaller are not subdivided. Defaults to 64.
+    MinSize int
+    // MaxDepth limits the depth of the tree. Zero means no limit.
+    MaxDepth int
+    // EdgeThreshold is the luminance gradient magnitude at which DetailEdges
+    // counts a pixel as an edge. Defaults to 32.
+    EdgeThreshold float64
+}
+
+// QuadNode is a region of a quadtree. A node either has four children,
+// ordered top-left, top-right, bottom-left and bottom-right, or is a leaf
+// written as a tile.
+type QuadNode struct {
+    // Path lists the child index taken at every level, e.g. "0213"; it is
+    // empty for the root.
+    Path   string          `json:"path"`
+    Rect   image.Rectangle `json:"rect"`
+    Detail float64         `json:"detail"`
+    // Children holds the four quadrants of an inner node.
+    Children []*QuadNode `json:"children,omitempty"`
+    // Tile is the output path of a leaf. It is empty for leaves excluded by
+    // SplitOptions.Select.
+    Tile string `json:"tile,omitempty"`
+}
+
+// Quadtree describes an adaptive split.
+type Quadtree struct {
+    InputPath string       `json:"inputPath"`
+    Width     int          `json:"width"`
+    Height    int          `json:"height"`
+    Metric    DetailMetric `json:"metric"`
+    Threshold float64      `json:"threshold"`
+    Root      *QuadNode    `json:"root"`
+    // Leaves is the number of leaf regions, i.e. of tiles.
+    Leaves int `json:"leaves"`
+}
+
+// QuadtreeFileSuffix is appended to the file prefix to name the JSON file
+// QuadtreeSplit writes next to the tiles.
+const QuadtreeFileSuffix = "_quadtree.json"
+
+// PlanQuadtree returns the quadtree QuadtreeSplit would build for the input
+// image without writing anything, with the same tile paths and selection.
+// Unlike the other Plan functions it has to decode the pixels.
+func PlanQuadtree(inputPath string, qopts QuadtreeOptions, opts SplitOptions) (*Quadtree, error) {
+    if inputPath == "" {
+        return nil, invalidArgf("input path is required")
+    }
+    if err := normalizeQuadtreeOptions(&qopts); err != nil {
+        return nil, err
+    }
+    ctx, err := prepareVerify(inputPath, opts)
+    if err != nil {
+        return nil, err
+    }
+    tree, leaves := buildQuadtree(ctx, qopts)
+    if _, err := quadtreeTiles(ctx.options, leaves); err != nil {
+        return nil, err
+    }
+    return tree, nil
+}
+
+// QuadtreeSplit recursively subdivides the image into quadrants until the
+// detail of a region falls below the threshold or its quadrants would be
+// smaller than MinSize, and writes the leaf regions as tiles named
+// {prefix}_quad_{path}, e.g. image_quad_0213.png; an image that is not
+// subdivided at all yields the single tile {prefix}_quad. The tree is
+// written as JSON to {prefix}_quadtree.json in the output directory and
+// returned together with the tile paths.
+//
+// Leaves are numbered in depth-first order, which is their TilePlan.Index
+// for SplitOptions.Select. Rows and columns are not meaningful for
+// quadtree tiles.
+func QuadtreeSplit(inputPath string, qopts QuadtreeOptions, opts SplitOptions) (*Quadtree, []string, error) {
+    if err := normalizeQuadtreeOptions(&qopts); err != nil {
+        return nil, nil, err
+    }
+
+    var tree *Quadtree
+    progress := newProgressTracker(opts.Observer, 1)
+    paths, err := progress.track(inputPath, func() ([]string, error) {
+        ctx, err := prepareSplit(inputPath, opts)
+        if err != nil {
+            return nil, err
+        }
+        ctx.progress = progress
+
+        var leaves []*QuadNode
+        tree, leaves = buildQuadtree(ctx, qopts)
+        selected, err := quadtreeTiles(ctx.options, leaves)
+        if err != nil {
+            return nil, err
+        }
+
+        written, err := writeTiles(ctx, selected)
+        if err != nil {
+            return nil, err
+        }
+        if err := writeQuadtree(ctx.options, tree); err != nil {
+            for _, p := range written {
+                ctx.options.output.Remove(p)
+            }
+            return nil, err
+        }
+        return written, nil
+    })
+    if err != nil {
+        return nil, nil, err
+    }
+    return tree, paths, nil
+}
+
+func normalizeQuadtreeOptions(q *QuadtreeOptions) error {
+    switch DetailMetric(strings.ToLower(strings.TrimSpace(string(q.Metric)))) {
+    case "", DetailVariance:
+        q.Metric = DetailVariance
+    case DetailEdges:
+        q.Metric = DetailEdges
+    case DetailEntropy:
+        q.Metric = DetailEntropy
+    default:
+        return invalidArgf("unsupported detail metric: %s", q.Metric)
+    }
+    if q.Threshold < 0 || q.MinSize < 0 || q.MaxDepth < 0 || q.EdgeThreshold < 0 {
+        return invalidArgf("quadtree threshold, minimum size, depth and edge threshold must not be negative")
+    }
+    if q.Threshold == 0 {
+        q.Threshold = map[DetailMetric]float64{DetailVariance: 100, DetailEdges: 0.05, DetailEntropy: 4}[q.Metric]
+    }
+    if q.MinSize == 0 {
+        q.MinSize = 64
+    }
+    if q.EdgeThreshold == 0 {
+        q.EdgeThreshold = 32
+    }
+    return nil
+}
+
+// buildQuadtree subdivides the image of ctx and returns the tree and its
+// leaves in depth-first order. Leaf tiles hold the tile name until
+// quadtreeTiles resolves it to a path.
+func buildQuadtree(ctx *splitContext, q QuadtreeOptions) (*Quadtree, []*QuadNode) {
+    m := newDetailMeter(ctx.img, q)
+    origin := ctx.bounds.Min
+
+    var leaves []*QuadNode
+    var build func(path string, r image.Rectangle) *QuadNode
+    build = func(path string, r image.Rectangle) *QuadNode {
+        node := &QuadNode{Path: path, Rect: r.Add(origin), Detail: m.detail(r)}
+        w, h := r.Dx(), r.Dy()
+        if node.Detail <= q.Threshold || w/2 < q.MinSize || h/2 < q.MinSize || q.MaxDepth > 0 && len(path) >= q.MaxDepth {
+            node.Tile = ctx.options.prefix + "_quad"
+            if path != "" {
+                node.Tile += "_" + path
+            }
+            leaves = append(leaves, node)
+            return node
+        }
+        midX, midY := r.Min.X+w/2, r.Min.Y+h/2
+        quadrants := []image.Rectangle{
+            image.Rect(r.Min.X, r.Min.Y, midX, midY),
+            image.Rect(midX, r.Min.Y, r.Max.X, midY),
+            image.Rect(r.Min.X, midY, midX, r.Max.Y),
+            image.Rect(midX, midY, r.Max.X, r.Max.Y),
+        }
+        for i, quad := range quadrants {
+            node.Children = append(node.Children, build(fmt.Sprintf("%s%d", path, i), quad))
+        }
+        return node
+    }
+
+    root := build("", image.Rect(0, 0, ctx.bounds.Dx(), ctx.bounds.Dy()))
+    return &Quadtree{
+        InputPath: ctx.inputPath,
+        Width:     ctx.bounds.Dx(),
+        Height:    ctx.bounds.Dy(),
+        Metric:    q.Metric,
+        Threshold: q.Threshold,
+        Root:      root,
+        Leaves:    len(leaves),
+    }, leaves
+}
+
+// quadtreeTiles resolves the tile paths of leaves and returns the tiles
+// selected by opts; the tiles of unselected leaves are cleared.
+func quadtreeTiles(opts normalizedOptions, leaves []*QuadNode) ([]TilePlan, error) {
+    tiles := make([]TilePlan, len(leaves))
+    for i, leaf := range leaves {
+        tiles[i] = TilePlan{Name: leaf.Tile, Path: tilePath(opts, leaf.Tile), Rect: leaf.Rect, Index: i}
+        leaf.Tile = tiles[i].Path
+    }
+    selected, err := selectTiles(tiles, opts.selection)
+    if err != nil {
+        return nil, err
+    }
+    if len(selected) < len(tiles) {
+        kept := make(map[string]bool, len(selected))
+        for _, t := range selected {
+            kept[t.Path] = true
+        }
+        for _, leaf := range leaves {
+            if !kept[leaf.Tile] {
+                leaf.Tile = ""
+            }
+        }
+    }
+    return selected, nil
+}
+
+func writeQuadtree(opts normalizedOptions, tree *Quadtree) error {
+    data, err := json.MarshalIndent(tree, "", "  ")
+    if err != nil {
+        return fmt.Errorf("encode quadtree: %w", err)
+    }
+    file, err := opts.output.Create(filepath.Join(opts.outputDir, opts.prefix+QuadtreeFileSuffix))
+    if err != nil {
+        return outputErrorf("create quadtree file: %w", err)
+    }
+    if _, err := file.Write(data); err != nil {
+        file.Abort()
+        return fmt.Errorf("write quadtree file: %w", err)
+    }
+    if err := file.Commit(); err != nil {
+        return fmt.Errorf("write quadtree file: %w", err)
+    }
+    return nil
+}
+
+// detailMeter measures the detail of regions of a luminance image. Every
+// metric scans the pixels of the region, so building a tree costs
+// O(pixels × depth) but needs no memory beyond the luminance image.
+type detailMeter struct {
+    metric        DetailMetric
+    edgeThreshold float64
+    luma          *image.Gray
+}
+
+func newDetailMeter(img image.Image, q QuadtreeOptions) *detailMeter {
+    gray, _ := prepareColor(&ColorOptions{Mode: ColorModeGray})
+    return &detailMeter{metric: q.Metric, edgeThreshold: q.EdgeThreshold, luma: gray.gray(img)}
+}
+
+// at returns the luminance at (x, y), clamped to the image.
+func (m *detailMeter) at(x, y int) float64 {
+    x, y = clampInt(x, 0, m.luma.Rect.Dx()-1), clampInt(y, 0, m.luma.Rect.Dy()-1)
+    return float64(m.luma.Pix[y*m.luma.Stride+x])
+}
+
+// detail measures r, in the coordinates of the luminance image.
+func (m *detailMeter) detail(r image.Rectangle) float64 {
+    n := float64(r.Dx() * r.Dy())
+    if n == 0 {
+        return 0
+    }
+    switch m.metric {
+    case DetailEdges:
+        edges := 0
+        for y := r.Min.Y; y < r.Max.Y; y++ {
+            for x := r.Min.X; x < r.Max.X; x++ {
+                gx, gy := m.at(x+1, y)-m.at(x-1, y), m.at(x, y+1)-m.at(x, y-1)
+                if math.Hypot(gx, gy) > m.edgeThreshold {
+                    edges++
+                }
+            }
+        }
+        return float64(edges) / n
+    case DetailEntropy:
+        var hist [256]int
+        for y := r.Min.Y; y < r.Max.Y; y++ {
+            for _, v := range m.luma.Pix[y*m.luma.Stride+r.Min.X : y*m.luma.Stride+r.Max.X] {
+                hist[v]++
+            }
+        }
+        entropy := 0.0
+        for _, c := range hist {
+            if c > 0 {
+                p := float64(c) / n
+                entropy -= p * math.Log2(p)
+            }
+        }
+        return entropy
+    default:
+        var sum, sumSq uint64
+        for y := r.Min.Y; y < r.Max.Y; y++ {
+            for _, v := range m.luma.Pix[y*m.luma.Stride+r.Min.X : y*m.luma.Stride+r.Max.X] {
+                sum += uint64(v)
+                sumSq += uint64(v) * uint64(v)
+            }
+        }
+        mean := float64(sum) / n
+        return math.Max(0, float64(sumSq)/n-mean*mean)
+    }
+}
@@ -0,0 +1,164 @@
+package imagesplit
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// writeQuadrantPNG writes a white 256x256 image whose bottom-right quadrant
+// holds a checkerboard of 4px squares.
+func writeQuadrantPNG(t *testing.T, mem *MemFS, name string) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for y := 128; y < 256; y++ {
+		for x := 128; x < 256; x++ {
+			if (x/4+y/4)%2 == 0 {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	writePNG(t, mem, name, img)
+}
+
+func TestQuadtreeSplitSubdividesDetailedRegions(t *testing.T) {
+	mem := NewMemFS()
+	writeQuadrantPNG(t, mem, "scan.png")
+	opts := SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "out"}
+
+	// A two-tone checkerboard carries at most 1 bit of entropy.
+	for metric, threshold := range map[DetailMetric]float64{DetailVariance: 0, DetailEdges: 0, DetailEntropy: 0.25} {
+		tree, err := PlanQuadtree("scan.png", QuadtreeOptions{Metric: metric, Threshold: threshold}, opts)
+		if err != nil {
+			t.Fatalf("%s: PlanQuadtree returned error: %v", metric, err)
+		}
+		// The root splits, only its detailed quadrant 3 splits again, and
+		// the 64px children of 3 stop at the default MinSize.
+		if tree.Leaves != 7 || len(tree.Root.Children) != 4 {
+			t.Fatalf("%s: expected 7 leaves, got %d", metric, tree.Leaves)
+		}
+		for i, child := range tree.Root.Children[:3] {
+			if child.Children != nil || child.Detail > tree.Threshold {
+				t.Fatalf("%s: expected uniform quadrant %d to be a leaf, got %+v", metric, i, child)
+			}
+		}
+		deep := tree.Root.Children[3].Children[2]
+		if deep.Path != "32" || deep.Rect != image.Rect(128, 192, 192, 256) || deep.Children != nil {
+			t.Fatalf("%s: unexpected node %+v", metric, deep)
+		}
+	}
+
+	tree, paths, err := QuadtreeSplit("scan.png", QuadtreeOptions{}, opts)
+	if err != nil {
+		t.Fatalf("QuadtreeSplit returned error: %v", err)
+	}
+	want := []string{
+		"out/scan_quad_0.png", "out/scan_quad_1.png", "out/scan_quad_2.png",
+		"out/scan_quad_30.png", "out/scan_quad_31.png", "out/scan_quad_32.png", "out/scan_quad_33.png",
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("expected %v, got %v", want, paths)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, paths)
+		}
+	}
+	if tile := readImage(t, mem, "out/scan_quad_31.png"); tile.Bounds().Dx() != 64 || tile.Bounds().Dy() != 64 {
+		t.Fatalf("expected a 64x64 leaf tile, got %v", tile.Bounds())
+	}
+
+	data, err := mem.ReadFile("out/scan" + QuadtreeFileSuffix)
+	if err != nil {
+		t.Fatalf("expected the tree to be written: %v", err)
+	}
+	var written Quadtree
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("decode tree: %v", err)
+	}
+	if written.Leaves != tree.Leaves || written.Root.Children[3].Children[1].Tile != "out/scan_quad_31.png" {
+		t.Fatalf("unexpected tree written: %s", data)
+	}
+}
+
+func TestPlanQuadtreeMatchesSplit(t *testing.T) {
+	mem := NewMemFS()
+	writeQuadrantPNG(t, mem, "scan.png")
+	opts := SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "out", Format: "jpeg"}
+
+	for _, sel := range []*TileSelection{nil, {Indices: []int{1, 4}}} {
+		opts.Select = sel
+		plan, err := PlanQuadtree("scan.png", QuadtreeOptions{}, opts)
+		if err != nil {
+			t.Fatalf("PlanQuadtree returned error: %v", err)
+		}
+		_, paths, err := QuadtreeSplit("scan.png", QuadtreeOptions{}, opts)
+		if err != nil {
+			t.Fatalf("QuadtreeSplit returned error: %v", err)
+		}
+
+		var planned []string
+		var leaves func(n *QuadNode)
+		leaves = func(n *QuadNode) {
+			if n.Tile != "" {
+				planned = append(planned, n.Tile)
+			}
+			for _, c := range n.Children {
+				leaves(c)
+			}
+		}
+		leaves(plan.Root)
+		if sel != nil && len(paths) != 2 || len(planned) != len(paths) {
+			t.Fatalf("select %+v: plan lists %v, split wrote %v", sel, planned, paths)
+		}
+		for i := range paths {
+			if planned[i] != paths[i] {
+				t.Fatalf("select %+v: plan lists %v, split wrote %v", sel, planned, paths)
+			}
+		}
+	}
+}
+
+func TestQuadtreeSplitLimits(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "flat.png", 200, 100, color.White)
+	writeNoisePNG(t, mem, "noise.png", 256, 256)
+	opts := SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "out"}
+
+	_, paths, err := QuadtreeSplit("flat.png", QuadtreeOptions{}, opts)
+	if err != nil {
+		t.Fatalf("QuadtreeSplit returned error: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "out/flat_quad.png" {
+		t.Fatalf("expected a uniform image to stay whole, got %v", paths)
+	}
+
+	tree, err := PlanQuadtree("noise.png", QuadtreeOptions{MinSize: 16, MaxDepth: 2}, opts)
+	if err != nil {
+		t.Fatalf("PlanQuadtree returned error: %v", err)
+	}
+	if tree.Leaves != 16 {
+		t.Fatalf("expected MaxDepth to stop at 16 leaves, got %d", tree.Leaves)
+	}
+	tree, err = PlanQuadtree("noise.png", QuadtreeOptions{MinSize: 100}, opts)
+	if err != nil {
+		t.Fatalf("PlanQuadtree returned error: %v", err)
+	}
+	if tree.Leaves != 4 {
+		t.Fatalf("expected MinSize to stop at 4 leaves, got %d", tree.Leaves)
+	}
+
+	for name, q := range map[string]QuadtreeOptions{
+		"metric":    {Metric: "contrast"},
+		"threshold": {Threshold: -1},
+		"min size":  {MinSize: -8},
+	} {
+		if _, _, err := QuadtreeSplit("noise.png", q, opts); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: expected an invalid argument error, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package imagesplit
+
+import (
+    "image"
+    "image/color"
+    "slices"
+)
+
+// colorBin accumulates the pixels of a histogram bucket. Buckets keep the
+// top five bits of every channel; the exact mean is kept in sum.
+type colorBin struct {
+    count int
+    sum   [4]int64
+}
+
+func (b colorBin) mean() color.RGBA {
+    n := int64(b.count)
+    return color.RGBA{
+        R: uint8((b.sum[0] + n/2) / n),
+        G: uint8((b.sum[1] + n/2) / n),
+        B: uint8((b.sum[2] + n/2) / n),
+        A: uint8((b.sum[3] + n/2) / n),
+    }
+}
+
+func (b *colorBin) add(o colorBin) {
+    b.count += o.count
+    for i := range b.sum {
+        b.sum[i] += o.sum[i]
+    }
+}
+
+// colorHistogram returns the occupied buckets of img in a stable order.
+func colorHistogram(img image.Image) []colorBin {
+    bins := make(map[uint32]*colorBin)
+    addPixel := func(r, g, b, a uint8) {
+        key := uint32(r>>3)<<15 | uint32(g>>3)<<10 | uint32(b>>3)<<5 | uint32(a>>3)
+        bin := bins[key]
+        if bin == nil {
+            bin = &colorBin{}
+            bins[key] = bin
+        }
+        bin.count++
+        bin.sum[0] += int64(r)
+        bin.sum[1] += int64(g)
+        bin.sum[2] += int64(b)
+        bin.sum[3] += int64(a)
+    }
+
+    bounds := img.Bounds()
+    if rgba, ok := img.(*image.RGBA); ok {
+        for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+            row := rgba.Pix[rgba.PixOffset(bounds.Min.X, y):][:4*bounds.Dx()]
+            for i := 0; i < len(row); i += 4 {
+                addPixel(row[i], row[i+1], row[i+2], row[i+3])
+            }
+        }
+    } else {
+        for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+            for x := bounds.Min.X; x < bounds.Max.X; x++ {
+                c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+                addPixel(c.R, c.G, c.B, c.A)
+            }
+        }
+    }
+
+    keys := make([]uint32, 0, len(bins))
+    for k := range bins {
+        keys = append(keys, k)
+    }
+    slices.Sort(keys)
+    hist := make([]colorBin, len(keys))
+    for i, k := range keys {
+        hist[i] = *bins[k]
+    }
+    return hist
+}
+
+// medianCut implements draw.Quantizer with the median cut algorithm.
+type medianCut struct{}
+
+// Quantize appends up to cap(p)-len(p) colors representing m to p.
+func (medianCut) Quantize(p color.Palette, m image.Image) color.Palette {
+    n := cap(p) - len(p)
+    hist := colorHistogram(m)
+    if n <= 0 || len(hist) == 0 {
+        return p
+    }
+
+    boxes := [][]colorBin{hist}
+    for len(boxes) < n {
+        // Split the box with the widest channel range, weighted by its
+        // pixel count so that rare outliers do not win over large areas.
+        pick, channel, bestScore := -1, 0, 0.0
+        for i, box := range boxes {
+            if len(box) < 2 {
+                continue
+            }
+            ch, width := widestChannel(box)
+            count := 0
+            for _, bin := range box {
+                count += bin.count
+            }
+            if score := float64(width) * float64(count); score > bestScore {
+                pick, channel, bestScore = i, ch, score
+            }
+        }
+        if pick < 0 {
+            break
+        }
+
+        box := boxes[pick]
+        slices.SortStableFunc(box, func(a, b colorBin) int {
+            return channelOf(a.mean(), channel) - channelOf(b.mean(), channel)
+        })
+        total := 0
+        for _, bin := range box {
+            total += bin.count
+        }
+        split, seen := 1, box[0].count
+        for split < len(box)-1 && seen+box[split].count <= total/2 {
+            seen += box[split].count
+            split++
+        }
+        boxes[pick] = box[:split]
+        boxes = append(boxes, box[split:])
+    }
+
+    for _, box := range boxes {
+        var merged colorBin
+        for _, bin := range box {
+            merged.add(bin)
+        }
+        p = append(p, merged.mean())
+    }
+    return p
+}
+
+func channelOf(c color.RGBA, channel int) int {
+    return int([4]uint8{c.R, c.G, c.B, c.A}[channel])
+}
+
+// widestChannel returns the channel with the largest range in box.
+func widestChannel(box []colorBin) (channel, width int) {
+    for ch := 0; ch < 4; ch++ {
+        lo, hi := 255, 0
+        for _, bin := range box {
+            v := channelOf(bin.mean(), ch)
+            lo, hi = min(lo, v), max(hi, v)
+        }
+        if hi-lo > width {
+            channel, width = ch, hi-lo
+        }
+    }
+    return channel, width
+}
+
+// octreeQuantizer implements draw.Quantizer with an octree over the RGB
+// bits. Alpha is averaged within each leaf.
+type octreeQuantizer struct{}
+
+type octNode struct {
+    children [8]*octNode
+    leaf     bool
+    // bin holds the pixels of a leaf; weight counts the whole subtree.
+    bin    colorBin
+    weight int
+}
+
+// Quantize appends up to cap(p)-len(p) colors representing m to p.
+func (octreeQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+    n := cap(p) - len(p)
+    hist := colorHistogram(m)
+    if n <= 0 || len(hist) == 0 {
+        return p
+    }
+
+    root := &octNode{}
+    var levels [8][]*octNode
+    levels[0] = []*octNode{root}
+    leaves := 0
+    for _, bin := range hist {
+        c := bin.mean()
+        node := root
+        for level := 0; level < 8; level++ {
+            node.weight += bin.count
+            shift := 7 - level
+            i := (c.R>>shift&1)<<2 | (c.G>>shift&1)<<1 | (c.B >> shift & 1)
+            child := node.children[i]
+            if child == nil {
+                child = &octNode{leaf: level == 7}
+                node.children[i] = child
+                if child.leaf {
+                    leaves++
+                } else {
+                    levels[level+1] = append(levels[level+1], child)
+                }
+            }
+            node = child
+        }
+        node.weight += bin.count
+        node.bin.add(bin)
+    }
+
+    // Merge the lightest nodes of the deepest level into leaves until the
+    // palette fits. All children of a level are leaves by the time it is
+    // reduced, because deeper levels are reduced first.
+    for level := 7; level >= 0 && leaves > n; level-- {
+        nodes := levels[level]
+        slices.SortStableFunc(nodes, func(a, b *octNode) int { return b.weight - a.weight })
+        for len(nodes) > 0 && leaves > n {
+            node := nodes[len(nodes)-1]
+            nodes = nodes[:len(nodes)-1]
+            for i, child := range node.children {
+                if child != nil {
+                    node.bin.add(child.bin)
+                    node.children[i] = nil
+                    leaves--
+                }
+            }
+            node.leaf = true
+            leaves++
+        }
+    }
+
+    var collect func(*octNode)
+    collect = func(node *octNode) {
+        if node.leaf {
+            p = append(p, node.bin.mean())
+            return
+        }
+        for _, child := range node.children {
+            if child != nil {
+                collect(child)
+            }
+        }
+    }
+    collect(root)
+    return p
+}
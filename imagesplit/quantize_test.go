@@ -0,0 +1,53 @@
+package imagesplit
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func gradient(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x * 255 / w), uint8(y * 255 / h), uint8((x + y) % 256), 255})
+		}
+	}
+	return img
+}
+
+func TestQuantizersRespectPaletteSize(t *testing.T) {
+	img := gradient(64, 64)
+	for name, q := range map[string]draw.Quantizer{"median-cut": medianCut{}, "octree": octreeQuantizer{}} {
+		for _, n := range []int{2, 16, 256} {
+			p := q.Quantize(make(color.Palette, 0, n), img)
+			if len(p) == 0 || len(p) > n {
+				t.Errorf("%s: expected up to %d colors, got %d", name, n, len(p))
+			}
+		}
+	}
+}
+
+func TestQuantizersKeepFewColorsExact(t *testing.T) {
+	img := solidImage(4, 4, color.RGBA{10, 20, 30, 255})
+	img.SetRGBA(0, 0, color.RGBA{200, 100, 50, 255})
+	for name, q := range map[string]draw.Quantizer{"median-cut": medianCut{}, "octree": octreeQuantizer{}} {
+		p := q.Quantize(make(color.Palette, 0, 16), img)
+		if len(p) != 2 {
+			t.Fatalf("%s: expected 2 colors, got %v", name, p)
+		}
+		if p.Convert(color.RGBA{200, 100, 50, 255}) != (color.RGBA{200, 100, 50, 255}) {
+			t.Errorf("%s: palette %v lost the outlier color", name, p)
+		}
+	}
+}
+
+func TestMedianCutAppendsToPalette(t *testing.T) {
+	base := make(color.Palette, 1, 4)
+	base[0] = color.Transparent
+	p := medianCut{}.Quantize(base, gradient(16, 16))
+	if len(p) != 4 || p[0] != color.Transparent {
+		t.Fatalf("expected the fixed entry followed by 3 colors, got %v", p)
+	}
+}
@@ -0,0 +1,207 @@
+package imagesplit
+
+import (
+    "image"
+    "image/color"
+    "image/draw"
+    "math"
+    "strings"
+)
+
+// FitMode controls how an image is adapted to a target aspect ratio.
+type FitMode string
+
+const (
+    // FitCrop scales the image to cover the target and center-crops the
+    // overflow.
+    FitCrop FitMode = "crop"
+    // FitPad scales the image to fit inside the target and pads the remaining
+    // area with a background color.
+    FitPad FitMode = "pad"
+    // FitStretch scales the image to the target size, ignoring its aspect
+    // ratio.
+    FitStretch FitMode = "stretch"
+)
+
+func normalizeFitMode(mode FitMode) (FitMode, error) {
+    switch FitMode(strings.ToLower(strings.TrimSpace(string(mode)))) {
+    case "", FitCrop:
+        return FitCrop, nil
+    case FitPad:
+        return FitPad, nil
+    case FitStretch:
+        return FitStretch, nil
+    default:
+        return "", invalidArgf("unsupported fit mode: %s", mode)
+    }
+}
+
+// fitImage scales src into a width x height canvas using mode. Pixels not
+// covered by the scaled image (FitPad) are filled with bg.
+func fitImage(src image.Image, width, height int, mode FitMode, bg color.Color) *image.RGBA {
+    b := src.Bounds()
+    switch mode {
+    case FitStretch:
+        return resizeImage(src, width, height)
+    case FitPad:
+        scale := math.Min(float64(width)/float64(b.Dx()), float64(height)/float64(b.Dy()))
+        w := clampInt(int(math.Round(float64(b.Dx())*scale)), 1, width)
+        h := clampInt(int(math.Round(float64(b.Dy())*scale)), 1, height)
+        dst := image.NewRGBA(image.Rect(0, 0, width, height))
+        draw.Draw(dst, dst.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+        offset := image.Pt((width-w)/2, (height-h)/2)
+        scaled := resizeImage(src, w, h)
+        draw.Draw(dst, scaled.Bounds().Add(offset), scaled, image.Point{}, draw.Over)
+        return dst
+    default:
+        scale := math.Max(float64(width)/float64(b.Dx()), float64(height)/float64(b.Dy()))
+        w := clampInt(int(math.Round(float64(width)/scale)), 1, b.Dx())
+        h := clampInt(int(math.Round(float64(height)/scale)), 1, b.Dy())
+        crop := image.Rect(0, 0, w, h).Add(image.Pt(b.Min.X+(b.Dx()-w)/2, b.Min.Y+(b.Dy()-h)/2))
+        return resizeImage(cropImage(src, crop), width, height)
+    }
+}
+
+// resizeImage resamples src to width x height with a separable triangle
+// filter. When shrinking, the filter is widened to the scale factor so that
+// every source pixel contributes (area averaging); when enlarging it reduces
+// to bilinear interpolation.
+func resizeImage(src image.Image, width, height int) *image.RGBA {
+    b := src.Bounds()
+    dst := image.NewRGBA(image.Rect(0, 0, width, height))
+    if b.Dx() == width && b.Dy() == height {
+        draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+        return dst
+    }
+
+    rgba, ok := src.(*image.RGBA)
+    if !ok || rgba.Rect.Min != (image.Point{}) {
+        rgba = cropImage(src, b)
+    }
+    sw, sh := b.Dx(), b.Dy()
+
+    xWeights := resampleWeights(sw, width)
+    yWeights := resampleWeights(sh, height)
+
+    // The first pass writes a float32 buffer of four channels per pixel;
+    // resampling the axis that shrinks most first keeps it small, which
+    // matters for large sources shrunk into tiles.
+    if width*sh <= sw*height {
+        // Horizontal pass into width x sh, then vertical pass into dst.
+        tmp := make([]float32, width*sh*4)
+        for y := 0; y < sh; y++ {
+            row := rgba.Pix[y*rgba.Stride:]
+            for x, ws := range xWeights {
+                accumulate(tmp[(y*width+x)*4:], ws, func(i int) []uint8 { return row[i*4:] })
+            }
+        }
+        for y, ws := range yWeights {
+            for x := 0; x < width; x++ {
+                storeFloat(dst.Pix[y*dst.Stride+x*4:], ws, func(i int) []float32 { return tmp[(i*width+x)*4:] })
+            }
+        }
+        return dst
+    }
+
+    // Vertical pass into sw x height, then horizontal pass into dst.
+    tmp := make([]float32, sw*height*4)
+    for y, ws := range yWeights {
+        for x := 0; x < sw; x++ {
+            accumulate(tmp[(y*sw+x)*4:], ws, func(i int) []uint8 { return rgba.Pix[i*rgba.Stride+x*4:] })
+        }
+    }
+    for y := 0; y < height; y++ {
+        row := tmp[y*sw*4:]
+        for x, ws := range xWeights {
+            storeFloat(dst.Pix[y*dst.Stride+x*4:], ws, func(i int) []float32 { return row[i*4:] })
+        }
+    }
+    return dst
+}
+
+// accumulate writes the weighted sum of the 8-bit pixels returned by pixel
+// to the first four entries of out.
+func accumulate(out []float32, ws []resampleWeight, pixel func(index int) []uint8) {
+    var r, g, b, a float64
+    for _, w := range ws {
+        p := pixel(w.index)
+        r += float64(p[0]) * w.weight
+        g += float64(p[1]) * w.weight
+        b += float64(p[2]) * w.weight
+        a += float64(p[3]) * w.weight
+    }
+    out[0], out[1], out[2], out[3] = float32(r), float32(g), float32(b), float32(a)
+}
+
+// storeFloat writes the weighted sum of the buffered pixels returned by
+// pixel to out as premultiplied 8-bit RGBA.
+func storeFloat(out []uint8, ws []resampleWeight, pixel func(index int) []float32) {
+    var r, g, b, a float64
+    for _, w := range ws {
+        p := pixel(w.index)
+        r += float64(p[0]) * w.weight
+        g += float64(p[1]) * w.weight
+        b += float64(p[2]) * w.weight
+        a += float64(p[3]) * w.weight
+    }
+    alpha := clampChannel(a)
+    out[0] = min(clampChannel(r), alpha)
+    out[1] = min(clampChannel(g), alpha)
+    out[2] = min(clampChannel(b), alpha)
+    out[3] = alpha
+}
+
+type resampleWeight struct {
+    index  int
+    weight float64
+}
+
+func resampleWeights(srcSize, dstSize int) [][]resampleWeight {
+    scale := float64(srcSize) / float64(dstSize)
+    support := math.Max(1, scale)
+    weights := make([][]resampleWeight, dstSize)
+    for i := range weights {
+        center := (float64(i)+0.5)*scale - 0.5
+        lo := int(math.Ceil(center - support))
+        hi := int(math.Floor(center + support))
+        var ws []resampleWeight
+        sum := 0.0
+        for s := lo; s <= hi; s++ {
+            w := 1 - math.Abs(float64(s)-center)/support
+            if w <= 0 {
+                continue
+            }
+            ws = append(ws, resampleWeight{index: clampInt(s, 0, srcSize-1), weight: w})
+            sum += w
+        }
+        if len(ws) == 0 {
+            ws = []resampleWeight{{index: clampInt(int(math.Round(center)), 0, srcSize-1), weight: 1}}
+            sum = 1
+        }
+        for j := range ws {
+            ws[j].weight /= sum
+        }
+        weights[i] = ws
+    }
+    return weights
+}
+
+func clampInt(v, lo, hi int) int {
+    if v < lo {
+        return lo
+    }
+    if v > hi {
+        return hi
+    }
+    return v
+}
+
+func clampChannel(v float64) uint8 {
+    if v <= 0 {
+        return 0
+    }
+    if v >= 255 {
+        return 255
+    }
+    return uint8(v + 0.5)
+}
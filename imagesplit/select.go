@@ -0,0 +1,83 @@
+package imagesplit
+
+import (
+    "image"
+    "slices"
+)
+
+// TileSelection restricts a split to some of its tiles. Every criterion that
+// is set must match. Selected tiles keep the names, rows, columns and
+// indices they have in a full split, so their files are interchangeable with
+// its output; the other tiles are neither cropped nor encoded.
+type TileSelection struct {
+    // Rows selects the tiles whose row lies in the range.
+    Rows *TileRange
+    // Cols selects the tiles whose column lies in the range.
+    Cols *TileRange
+    // Indices selects the tiles with these output indices.
+    Indices []int
+    // Region selects the tiles whose rect intersects it, in the coordinates
+    // of the transformed image. The zero rectangle selects every tile.
+    Region image.Rectangle
+}
+
+// TileRange is an inclusive range of rows or columns.
+type TileRange struct {
+    First int
+    Last  int
+}
+
+// Span returns the range from first to last, inclusive.
+func Span(first, last int) *TileRange {
+    return &TileRange{First: first, Last: last}
+}
+
+func (r *TileRange) contains(v int) bool {
+    return r == nil || v >= r.First && v <= r.Last
+}
+
+func validateSelection(s *TileSelection) error {
+    if s == nil {
+        return nil
+    }
+    if r := s.Rows; r != nil && (r.First < 0 || r.Last < r.First) {
+        return invalidArgf("row range %d-%d is invalid", r.First, r.Last)
+    }
+    if r := s.Cols; r != nil && (r.First < 0 || r.Last < r.First) {
+        return invalidArgf("column range %d-%d is invalid", r.First, r.Last)
+    }
+    for _, i := range s.Indices {
+        if i < 0 {
+            return invalidArgf("tile index %d must not be negative", i)
+        }
+    }
+    if s.Region != (image.Rectangle{}) && s.Region.Empty() {
+        return invalidArgf("selection region %v is empty", s.Region)
+    }
+    return nil
+}
+
+// selectTiles returns the tiles matching s. A selection that matches no tile
+// is an error, as it most likely does not fit the image.
+func selectTiles(tiles []TilePlan, s *TileSelection) ([]TilePlan, error) {
+    if s == nil {
+        return tiles, nil
+    }
+    selected := make([]TilePlan, 0, len(tiles))
+    for _, t := range tiles {
+        if !s.Rows.contains(t.Row) || !s.Cols.contains(t.Col) {
+            continue
+        }
+        if s.Indices != nil && !slices.Contains(s.Indices, t.Index) {
+            continue
+        }
+        if s.Region != (image.Rectangle{}) && !t.Rect.Overlaps(s.Region) {
+            continue
+        }
+        selected = append(selected, t)
+    }
+    if len(selected) == 0 {
+        return nil, invalidArgf("tile selection matches none of the %d tiles", len(tiles))
+    }
+    return selected, nil
+}
@@ -0,0 +1,110 @@
+package imagesplit
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestGridSplitSelectsCenterTiles(t *testing.T) {
+	mem := NewMemFS()
+	writeNoisePNG(t, mem, "map.png", 80, 80)
+	opts := SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "full"}
+	full, err := GridSplit("map.png", 8, 8, opts)
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+
+	opts.OutputDir = "center"
+	opts.Select = &TileSelection{Rows: Span(3, 4), Cols: Span(3, 4)}
+	paths, err := GridSplit("map.png", 8, 8, opts)
+	if err != nil {
+		t.Fatalf("GridSplit with selection returned error: %v", err)
+	}
+	want := []string{"center/map_row3_col3.png", "center/map_row3_col4.png", "center/map_row4_col3.png", "center/map_row4_col4.png"}
+	if len(paths) != len(want) {
+		t.Fatalf("expected %v, got %v", want, paths)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, paths)
+		}
+	}
+
+	// The selected tiles are byte-identical to those of a full run.
+	selected, _ := mem.ReadFile("center/map_row3_col4.png")
+	original, _ := mem.ReadFile(full[3*8+4])
+	if !bytes.Equal(selected, original) {
+		t.Fatal("expected the selected tile to match the tile of the full split")
+	}
+	if _, err := mem.Stat("center/map_row0_col0.png"); err == nil {
+		t.Fatal("expected unselected tiles not to be written")
+	}
+
+	plan, err := PlanGrid("map.png", 8, 8, opts)
+	if err != nil {
+		t.Fatalf("PlanGrid returned error: %v", err)
+	}
+	if len(plan.Tiles) != 4 || plan.Tiles[1].Index != 28 || plan.Tiles[1].Row != 3 || plan.Tiles[1].Col != 4 {
+		t.Fatalf("expected the plan to keep the full-run positions, got %+v", plan.Tiles)
+	}
+}
+
+func TestTileSplitSelectsByIndexAndRegion(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "scan.png", 100, 60, color.White)
+	opts := SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "out"}
+
+	opts.Select = &TileSelection{Indices: []int{4, 0, 99}}
+	paths, err := TileSplit("scan.png", 30, 30, opts)
+	if err != nil {
+		t.Fatalf("TileSplit returned error: %v", err)
+	}
+	if len(paths) != 2 || paths[0] != "out/scan_tile_0.png" || paths[1] != "out/scan_tile_4.png" {
+		t.Fatalf("unexpected tiles selected by index: %v", paths)
+	}
+
+	// 30x30 tiles: columns start at 0, 30, 60, 90 and rows at 0, 30.
+	opts.Select = &TileSelection{Region: image.Rect(55, 25, 65, 35)}
+	plan, err := PlanTile("scan.png", 30, 30, opts)
+	if err != nil {
+		t.Fatalf("PlanTile returned error: %v", err)
+	}
+	var indices []int
+	for _, tile := range plan.Tiles {
+		indices = append(indices, tile.Index)
+	}
+	if len(indices) != 4 || indices[0] != 1 || indices[1] != 2 || indices[2] != 5 || indices[3] != 6 {
+		t.Fatalf("expected the tiles intersecting the region, got %v", indices)
+	}
+
+	// Criteria combine: the region and the first row.
+	opts.Select.Rows = Span(0, 0)
+	plan, err = PlanTile("scan.png", 30, 30, opts)
+	if err != nil {
+		t.Fatalf("PlanTile returned error: %v", err)
+	}
+	if len(plan.Tiles) != 2 || plan.Tiles[0].Index != 1 || plan.Tiles[1].Index != 2 {
+		t.Fatalf("expected the criteria to be combined, got %+v", plan.Tiles)
+	}
+}
+
+func TestSelectionValidation(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "scan.png", 40, 40, color.White)
+
+	for name, sel := range map[string]*TileSelection{
+		"reversed range":   {Rows: Span(3, 1)},
+		"negative column":  {Cols: Span(-1, 2)},
+		"negative index":   {Indices: []int{-1}},
+		"empty region":     {Region: image.Rect(10, 10, 10, 20)},
+		"no matching tile": {Rows: Span(5, 9)},
+	} {
+		_, err := GridSplit("scan.png", 2, 2, SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "out", Select: sel})
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: expected an invalid argument error, got %v", name, err)
+		}
+	}
+}
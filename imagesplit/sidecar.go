@@ -0,0 +1,63 @@
+package imagesplit
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+)
+
+// SidecarSuffix is appended to the file name of a source image to form the
+// name of its sidecar file, e.g. "scan.png.json" for "scan.png".
+const SidecarSuffix = ".json"
+
+// loadSidecars reads the sidecar file of every job, if it has one, and
+// stores the resulting per-image configuration in the job. Every sidecar is
+// validated, so that a bad override fails the batch before anything is
+// written.
+func loadSidecars(cfg DirectorySplitConfig, jobs []directoryJob) error {
+    src := newInputSource(cfg.Options.InputFS)
+    for i := range jobs {
+        job := &jobs[i]
+        data, err := readSidecar(src, job.inputPath+SidecarSuffix)
+        if isNotExist(err) {
+            continue
+        }
+        if err != nil {
+            return fmt.Errorf("read sidecar of %s: %w", job.inputPath, err)
+        }
+
+        var settings JobSettings
+        dec := json.NewDecoder(bytes.NewReader(data))
+        dec.DisallowUnknownFields()
+        if err := dec.Decode(&settings); err != nil {
+            return invalidArgf("parse sidecar of %s: %v", job.inputPath, err)
+        }
+        override := cfg
+        if err := settings.apply(&override); err != nil {
+            return fmt.Errorf("sidecar of %s: %w", job.inputPath, err)
+        }
+        if err := validateDirectoryConfig(override); err != nil {
+            return fmt.Errorf("sidecar of %s: %w", job.inputPath, err)
+        }
+        if err := validateSplitOptions(override.Options); err != nil {
+            return fmt.Errorf("sidecar of %s: %w", job.inputPath, err)
+        }
+
+        sum := sha256.Sum256(data)
+        job.config = &override
+        job.sidecarHash = hex.EncodeToString(sum[:])
+    }
+    return nil
+}
+
+func readSidecar(src inputSource, path string) ([]byte, error) {
+    f, err := src.open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return io.ReadAll(f)
+}
@@ -0,0 +1,168 @@
+package imagesplit
+
+import (
+    "bytes"
+    "fmt"
+    "image"
+    "math"
+    "strings"
+)
+
+// SizeLimitPolicy selects what happens when a JPEG tile does not fit its
+// byte budget even at the lowest allowed quality.
+type SizeLimitPolicy string
+
+const (
+    // SizeLimitFail fails the split, the default. The error matches
+    // ErrSizeLimit.
+    SizeLimitFail SizeLimitPolicy = "fail"
+    // SizeLimitDownscale shrinks the tile, keeping its aspect ratio, until
+    // it fits.
+    SizeLimitDownscale SizeLimitPolicy = "downscale"
+)
+
+// SizeLimit bounds the encoded size of JPEG tiles. Every tile is encoded
+// with the highest quality in [MinQuality, MaxQuality] whose output fits
+// MaxBytes; the chosen quality is reported in EventTileWritten.
+type SizeLimit struct {
+    // MaxBytes is the largest allowed tile file size.
+    MaxBytes int64
+    // MinQuality is the lowest quality that may be used. Defaults to 10.
+    MinQuality int
+    // MaxQuality is the highest quality that is tried. Defaults to
+    // SplitOptions.Quality.
+    MaxQuality int
+    // Policy handles tiles that are too large at MinQuality. Defaults to
+    // SizeLimitFail.
+    Policy SizeLimitPolicy
+}
+
+// preparedSizeLimit is a validated SizeLimit.
+type preparedSizeLimit struct {
+    maxBytes   int64
+    minQuality int
+    maxQuality int
+    downscale  bool
+}
+
+func prepareSizeLimit(l *SizeLimit, format string, quality int) (*preparedSizeLimit, error) {
+    if l == nil {
+        return nil, nil
+    }
+    if format != "jpeg" {
+        return nil, invalidArgf("size limit requires JPEG output, got %s", format)
+    }
+    if l.MaxBytes <= 0 {
+        return nil, invalidArgf("size limit must be greater than zero")
+    }
+
+    p := &preparedSizeLimit{maxBytes: l.MaxBytes, minQuality: l.MinQuality, maxQuality: l.MaxQuality}
+    if p.minQuality == 0 {
+        p.minQuality = 10
+    }
+    if p.maxQuality == 0 {
+        p.maxQuality = quality
+    }
+    if p.minQuality < 1 || p.maxQuality > 100 || p.minQuality > p.maxQuality {
+        return nil, invalidArgf("size limit quality range %d-%d is invalid", p.minQuality, p.maxQuality)
+    }
+
+    switch SizeLimitPolicy(strings.ToLower(strings.TrimSpace(string(l.Policy)))) {
+    case "", SizeLimitFail:
+    case SizeLimitDownscale:
+        p.downscale = true
+    default:
+        return nil, invalidArgf("unsupported size limit policy: %s", l.Policy)
+    }
+    return p, nil
+}
+
+// encode returns tile encoded within the byte budget together with the
+// chosen quality and the encoded size, which is smaller than the tile when
+// it had to be downscaled.
+func (p *preparedSizeLimit) encode(tile image.Image, opts normalizedOptions) (*bytes.Buffer, int, image.Point, error) {
+    img := tile
+    for {
+        buf, quality, smallest, err := p.search(img, opts)
+        if err != nil {
+            return nil, 0, image.Point{}, err
+        }
+        size := img.Bounds().Size()
+        if buf != nil {
+            return buf, quality, size, nil
+        }
+        if !p.downscale {
+            return nil, 0, image.Point{}, &classifiedError{
+                msg:  fmt.Sprintf("tile of %dx%d pixels needs %d bytes at quality %d, more than the limit of %d bytes", size.X, size.Y, smallest, p.minQuality, p.maxBytes),
+                kind: ErrSizeLimit,
+            }
+        }
+
+        // The encoded size is roughly proportional to the pixel count, so
+        // shrink by the square root of the overshoot plus a little slack.
+        factor := math.Sqrt(float64(p.maxBytes)/float64(smallest)) * 0.95
+        factor = math.Max(0.5, math.Min(0.95, factor))
+        width := int(math.Round(float64(size.X) * factor))
+        height := int(math.Round(float64(size.Y) * factor))
+        if width < 1 || height < 1 || width == size.X && height == size.Y {
+            return nil, 0, image.Point{}, &classifiedError{
+                msg:  fmt.Sprintf("tile cannot be downscaled below %d bytes", p.maxBytes),
+                kind: ErrSizeLimit,
+            }
+        }
+        img = resizeImage(tile, width, height)
+    }
+}
+
+// search finds the highest quality at which img fits the budget. When none
+// does, buf is nil and smallest is the size at the lowest quality.
+func (p *preparedSizeLimit) search(img image.Image, opts normalizedOptions) (buf *bytes.Buffer, quality int, smallest int64, err error) {
+    encodeAt := func(q int) (*bytes.Buffer, error) {
+        var b bytes.Buffer
+        o := opts
+        o.quality = q
+        if err := encodeTile(&b, img, o); err != nil {
+            return nil, err
+        }
+        return &b, nil
+    }
+
+    low, err := encodeAt(p.minQuality)
+    if err != nil {
+        return nil, 0, 0, err
+    }
+    if int64(low.Len()) > p.maxBytes {
+        return nil, 0, int64(low.Len()), nil
+    }
+    buf, quality = low, p.minQuality
+
+    if p.maxQuality == p.minQuality {
+        return buf, quality, int64(low.Len()), nil
+    }
+    // Most tiles fit at the highest quality, so try it before bisecting.
+    top, err := encodeAt(p.maxQuality)
+    if err != nil {
+        return nil, 0, 0, err
+    }
+    if int64(top.Len()) <= p.maxBytes {
+        return top, p.maxQuality, int64(low.Len()), nil
+    }
+
+    // JPEG output grows with the quality, so bisect for the highest quality
+    // that still fits.
+    lo, hi := p.minQuality+1, p.maxQuality-1
+    for lo <= hi {
+        mid := (lo + hi) / 2
+        b, err := encodeAt(mid)
+        if err != nil {
+            return nil, 0, 0, err
+        }
+        if int64(b.Len()) <= p.maxBytes {
+            buf, quality = b, mid
+            lo = mid + 1
+        } else {
+            hi = mid - 1
+        }
+    }
+    return buf, quality, int64(low.Len()), nil
+}
@@ -0,0 +1,144 @@
+package imagesplit
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"testing"
+)
+
+// writeNoisePNG writes an image of random pixels, which compresses poorly
+// and makes the JPEG size depend strongly on the quality.
+func writeNoisePNG(t *testing.T, mem *MemFS, name string, w, h int) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	rng.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	writePNG(t, mem, name, img)
+}
+
+func jpegSize(t *testing.T, mem *MemFS, name string, quality int) int64 {
+	t.Helper()
+	img := readImage(t, mem, name)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return int64(buf.Len())
+}
+
+func TestSizeLimitPicksHighestFittingQuality(t *testing.T) {
+	mem := NewMemFS()
+	writeNoisePNG(t, mem, "noise.png", 64, 64)
+	budget := jpegSize(t, mem, "noise.png", 50)
+
+	var events []Event
+	paths, err := GridSplit("noise.png", 1, 1, SplitOptions{
+		InputFS:   mem,
+		OutputFS:  mem,
+		OutputDir: "out",
+		Format:    "jpeg",
+		SizeLimit: &SizeLimit{MaxBytes: budget},
+		Observer: func(e Event) {
+			if e.Type == EventTileWritten {
+				events = append(events, e)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	data, err := mem.ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("read tile: %v", err)
+	}
+	if int64(len(data)) > budget {
+		t.Fatalf("tile has %d bytes, more than the budget of %d", len(data), budget)
+	}
+	if len(events) != 1 || events[0].Quality < 50 || events[0].Quality >= 90 {
+		t.Fatalf("unexpected reported quality: %+v", events)
+	}
+	if events[0].Bytes != int64(len(data)) || events[0].EncodedSize != image.Pt(64, 64) {
+		t.Fatalf("unexpected event %+v", events[0])
+	}
+	if size := jpegSize(t, mem, "noise.png", events[0].Quality+1); size <= budget {
+		t.Fatalf("quality %d would also fit (%d bytes)", events[0].Quality+1, size)
+	}
+}
+
+func TestSizeLimitFailPolicy(t *testing.T) {
+	mem := NewMemFS()
+	writeNoisePNG(t, mem, "noise.png", 64, 64)
+
+	_, err := GridSplit("noise.png", 1, 2, SplitOptions{
+		InputFS:   mem,
+		OutputFS:  mem,
+		OutputDir: "out",
+		Format:    "jpeg",
+		SizeLimit: &SizeLimit{MaxBytes: 200},
+	})
+	if !errors.Is(err, ErrSizeLimit) {
+		t.Fatalf("expected ErrSizeLimit, got %v", err)
+	}
+	if _, err := mem.Stat("out/noise_row0_col0.jpg"); err == nil {
+		t.Fatal("expected no tiles to be left behind")
+	}
+}
+
+func TestSizeLimitDownscalePolicy(t *testing.T) {
+	mem := NewMemFS()
+	writeNoisePNG(t, mem, "noise.png", 128, 128)
+	budget := jpegSize(t, mem, "noise.png", 30) / 2
+
+	var encoded image.Point
+	paths, err := GridSplit("noise.png", 1, 1, SplitOptions{
+		InputFS:   mem,
+		OutputFS:  mem,
+		OutputDir: "out",
+		Format:    "jpeg",
+		SizeLimit: &SizeLimit{MaxBytes: budget, MinQuality: 30, Policy: SizeLimitDownscale},
+		Observer: func(e Event) {
+			if e.Type == EventTileWritten {
+				encoded = e.EncodedSize
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	data, err := mem.ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("read tile: %v", err)
+	}
+	if int64(len(data)) > budget {
+		t.Fatalf("tile has %d bytes, more than the budget of %d", len(data), budget)
+	}
+	tile := readImage(t, mem, paths[0])
+	if tile.Bounds().Size() != encoded || encoded.X >= 128 || encoded.X != encoded.Y {
+		t.Fatalf("expected a smaller square tile, got %v (reported %v)", tile.Bounds(), encoded)
+	}
+}
+
+func TestSizeLimitValidation(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "in.png", 8, 8, color.White)
+
+	cases := map[string]SplitOptions{
+		"png output": {Format: "png", SizeLimit: &SizeLimit{MaxBytes: 1000}},
+		"no budget":  {Format: "jpeg", SizeLimit: &SizeLimit{}},
+		"range":      {Format: "jpeg", SizeLimit: &SizeLimit{MaxBytes: 1000, MinQuality: 80, MaxQuality: 60}},
+		"policy":     {Format: "jpeg", SizeLimit: &SizeLimit{MaxBytes: 1000, Policy: "crop"}},
+	}
+	for name, opts := range cases {
+		opts.InputFS, opts.OutputFS = mem, mem
+		if _, err := GridSplit("in.png", 1, 1, opts); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: expected ErrInvalidArgument, got %v", name, err)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 // Package imagesplit provides utilities for splitting images into smaller tiles.
 package imagesplit
 
+import "io/fs"
+
 // SplitOptions defines configurable options for image splitting operations.
 type SplitOptions struct {
     // OutputDir is the directory where split images will be written. If empty,
@@ -16,6 +18,44 @@ type SplitOptions struct {
     // Quality controls JPEG encoding quality (1-100). It is ignored for PNG
     // output. When set to 0, a default of 90 is used.
     Quality int
+    // Observer, when set, receives progress events for every image and tile.
+    // For SplitDirectory it also receives batch events and its counters span
+    // the whole batch.
+    Observer Observer
+    // InputFS, when set, is used to read the input image (and, for
+    // SplitDirectory, to list the input directory). Paths are then
+    // slash-separated names within InputFS, e.g. for an embed.FS or a
+    // zip.Reader. When nil, the local file system is used.
+    InputFS fs.FS
+    // OutputFS, when set, receives the generated tiles and OutputDir is a
+    // name within it. When nil, tiles are written to the local file system.
+    // See OSFS, DirFS and MemFS.
+    OutputFS WritableFS
+    // Watermark, when set, is stamped onto every tile before it is encoded.
+    Watermark *Watermark
+    // Transforms rotate, flip, crop or trim the decoded image, in order,
+    // before the split geometry is computed, so rows, columns and tile rects
+    // refer to the transformed image. Plan functions read only the image
+    // header unless the pipeline contains AutoTrim.
+    Transforms []Transform
+    // Color, when set, converts every tile to grayscale, black and white or
+    // a reduced palette before it is encoded.
+    Color *ColorOptions
+    // SizeLimit, when set, encodes every JPEG tile with the highest quality
+    // that fits a byte budget instead of the fixed Quality. It requires JPEG
+    // output.
+    SizeLimit *SizeLimit
+    // Background is composited under transparent pixels when the output
+    // cannot store alpha, i.e. for JPEG tiles and the gray and binary color
+    // modes, and under split previews. Defaults to white for tiles; previews
+    // keep their transparency when it is nil.
+    Background *Background
+    // AlphaPolicy selects whether transparent tiles are flattened silently
+    // (the default), flattened with an EventWarning, or rejected.
+    AlphaPolicy AlphaPolicy
+    // Select, when set, writes only the matching tiles of the split. Plan
+    // functions list only those tiles as well.
+    Select *TileSelection
 }
 
 // GridSplit divides an input image into a grid defined by the provided number
@@ -24,8 +64,36 @@ func GridSplit(inputPath string, rows, cols int, opts SplitOptions) ([]string, e
     return gridSplit(inputPath, rows, cols, opts)
 }
 
+// GridSplitLayout divides an input image into a non-uniform grid described by
+// layout. Each row and column track is either a fixed pixel size or a weighted
+// share of the remaining space, e.g. Ratios(1, 2, 1) for a 1:2:1 split or
+// []GridTrack{Fixed(200), Fill()} for a fixed sidebar next to a flexible
+// column. Tiles are named exactly as with GridSplit.
+func GridSplitLayout(inputPath string, layout GridLayout, opts SplitOptions) ([]string, error) {
+    return gridSplitLayout(inputPath, layout, opts)
+}
+
 // TileSplit divides an input image into tiles of the specified width and height
 // (in pixels). It returns the list of generated file paths on success.
 func TileSplit(inputPath string, tileWidth, tileHeight int, opts SplitOptions) ([]string, error) {
     return tileSplit(inputPath, tileWidth, tileHeight, opts)
 }
+
+// PresetSplit cuts an input image into slides for a registered preset (see
+// RegisterPreset), e.g. "instagram-portrait" for a seamless 1080x1350
+// carousel or "instagram-grid" for a 3x3 profile grid. For carousel presets
+// the slide count is chosen from the source aspect ratio unless overridden.
+// The source is center-cropped or padded to the preset aspect ratio and
+// resized so that every slide has the exact preset dimensions. Carousel slides
+// are named {prefix}_slide_{index}; grid presets use the GridSplit naming.
+func PresetSplit(inputPath, preset string, popts PresetOptions, opts SplitOptions) ([]string, error) {
+    return presetSplit(inputPath, preset, popts, opts)
+}
+
+// AutoGridSplit chooses rows and columns for the input image with ChooseGrid
+// (e.g. "about 12 tiles" or "no tile larger than 1024x1024") and then splits
+// it exactly like GridSplit. It returns the chosen grid together with the
+// generated file paths.
+func AutoGridSplit(inputPath string, target GridTarget, opts SplitOptions) (GridChoice, []string, error) {
+    return autoGridSplit(inputPath, target, opts)
+}
@@ -0,0 +1,285 @@
+package imagesplit
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// WritableFS is the output side of a split. Names use the conventions of the
+// implementation: OSFS accepts native paths, DirFS and MemFS accept
+// slash-separated paths relative to their root.
+type WritableFS interface {
+    // MkdirAll creates a directory along with any missing parents.
+    MkdirAll(dir string) error
+    // Create starts writing a file. The content becomes visible under name
+    // only once Commit succeeds.
+    Create(name string) (OutputFile, error)
+    // ReadFile returns the content of a committed file.
+    ReadFile(name string) ([]byte, error)
+    // Stat describes a file or directory.
+    Stat(name string) (fs.FileInfo, error)
+    // Remove deletes a file or an empty directory.
+    Remove(name string) error
+    // RemoveAll deletes name and everything below it. A missing name is not
+    // an error.
+    RemoveAll(name string) error
+}
+
+// OutputFile is a file being written to a WritableFS.
+type OutputFile interface {
+    io.Writer
+    // Commit publishes the written content under the file's name, replacing
+    // any previous file atomically where the backend supports it.
+    Commit() error
+    // Abort discards the written content. It is a no-op after Commit.
+    Abort() error
+}
+
+// OSFS writes to the local file system using native paths. It is the default
+// output when SplitOptions.OutputFS is nil.
+type OSFS struct{}
+
+// MkdirAll implements WritableFS.
+func (OSFS) MkdirAll(dir string) error {
+    return os.MkdirAll(dir, 0o755)
+}
+
+// Create implements WritableFS. Content is written to a temporary file in the
+// same directory and renamed into place on Commit.
+func (OSFS) Create(name string) (OutputFile, error) {
+    f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+    if err != nil {
+        return nil, err
+    }
+    return &osOutputFile{file: f, name: name}, nil
+}
+
+// ReadFile implements WritableFS.
+func (OSFS) ReadFile(name string) ([]byte, error) {
+    return os.ReadFile(name)
+}
+
+// Stat implements WritableFS.
+func (OSFS) Stat(name string) (fs.FileInfo, error) {
+    return os.Stat(name)
+}
+
+// Remove implements WritableFS.
+func (OSFS) Remove(name string) error {
+    return os.Remove(name)
+}
+
+// RemoveAll implements WritableFS.
+func (OSFS) RemoveAll(name string) error {
+    return os.RemoveAll(name)
+}
+
+type osOutputFile struct {
+    file *os.File
+    name string
+    done bool
+}
+
+func (f *osOutputFile) Write(p []byte) (int, error) {
+    return f.file.Write(p)
+}
+
+func (f *osOutputFile) Commit() error {
+    if f.done {
+        return fmt.Errorf("output file %s already closed", f.name)
+    }
+    if err := f.file.Chmod(0o644); err != nil {
+        f.Abort()
+        return err
+    }
+    if err := f.file.Close(); err != nil {
+        f.done = true
+        os.Remove(f.file.Name())
+        return err
+    }
+    f.done = true
+    if err := os.Rename(f.file.Name(), f.name); err != nil {
+        os.Remove(f.file.Name())
+        return err
+    }
+    return nil
+}
+
+func (f *osOutputFile) Abort() error {
+    if f.done {
+        return nil
+    }
+    f.done = true
+    f.file.Close()
+    return os.Remove(f.file.Name())
+}
+
+// DirFS is an OSFS rooted at a directory. Names are slash-separated and must
+// stay inside the root. It can also be used as an input fs.FS.
+type DirFS struct {
+    root string
+}
+
+// NewDirFS returns a DirFS rooted at root.
+func NewDirFS(root string) *DirFS {
+    return &DirFS{root: root}
+}
+
+func (d *DirFS) resolve(op, name string) (string, error) {
+    clean, err := cleanFSName(name)
+    if err != nil {
+        return "", &fs.PathError{Op: op, Path: name, Err: err}
+    }
+    return filepath.Join(d.root, filepath.FromSlash(clean)), nil
+}
+
+// Open implements fs.FS.
+func (d *DirFS) Open(name string) (fs.File, error) {
+    return os.DirFS(d.root).Open(name)
+}
+
+// MkdirAll implements WritableFS.
+func (d *DirFS) MkdirAll(dir string) error {
+    p, err := d.resolve("mkdir", dir)
+    if err != nil {
+        return err
+    }
+    return OSFS{}.MkdirAll(p)
+}
+
+// Create implements WritableFS.
+func (d *DirFS) Create(name string) (OutputFile, error) {
+    p, err := d.resolve("create", name)
+    if err != nil {
+        return nil, err
+    }
+    return OSFS{}.Create(p)
+}
+
+// ReadFile implements WritableFS.
+func (d *DirFS) ReadFile(name string) ([]byte, error) {
+    p, err := d.resolve("read", name)
+    if err != nil {
+        return nil, err
+    }
+    return os.ReadFile(p)
+}
+
+// Stat implements WritableFS.
+func (d *DirFS) Stat(name string) (fs.FileInfo, error) {
+    p, err := d.resolve("stat", name)
+    if err != nil {
+        return nil, err
+    }
+    return os.Stat(p)
+}
+
+// Remove implements WritableFS.
+func (d *DirFS) Remove(name string) error {
+    p, err := d.resolve("remove", name)
+    if err != nil {
+        return err
+    }
+    return os.Remove(p)
+}
+
+// RemoveAll implements WritableFS. Removing the root itself is refused.
+func (d *DirFS) RemoveAll(name string) error {
+    clean, err := cleanFSName(name)
+    if err != nil {
+        return &fs.PathError{Op: "removeall", Path: name, Err: err}
+    }
+    if clean == "." {
+        return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrPermission}
+    }
+    return os.RemoveAll(filepath.Join(d.root, filepath.FromSlash(clean)))
+}
+
+// cleanFSName converts name to a clean slash-separated path that is valid for
+// fs.FS and does not escape the root.
+func cleanFSName(name string) (string, error) {
+    clean := path.Clean(filepath.ToSlash(name))
+    clean = strings.TrimPrefix(clean, "./")
+    if clean == "" {
+        clean = "."
+    }
+    if !fs.ValidPath(clean) {
+        return "", fs.ErrInvalid
+    }
+    return clean, nil
+}
+
+// inputSource reads source images either from the local file system (native
+// paths) or from an fs.FS (slash-separated names).
+type inputSource struct {
+    fsys fs.FS
+}
+
+func newInputSource(fsys fs.FS) inputSource {
+    return inputSource{fsys: fsys}
+}
+
+func (s inputSource) open(name string) (fs.File, error) {
+    if s.fsys == nil {
+        return os.Open(name)
+    }
+    clean, err := cleanFSName(name)
+    if err != nil {
+        return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+    }
+    return s.fsys.Open(clean)
+}
+
+func (s inputSource) stat(name string) (fs.FileInfo, error) {
+    if s.fsys == nil {
+        return os.Stat(name)
+    }
+    clean, err := cleanFSName(name)
+    if err != nil {
+        return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+    }
+    return fs.Stat(s.fsys, clean)
+}
+
+func (s inputSource) readDir(name string) ([]fs.DirEntry, error) {
+    if s.fsys == nil {
+        return os.ReadDir(name)
+    }
+    clean, err := cleanFSName(name)
+    if err != nil {
+        return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+    }
+    return fs.ReadDir(s.fsys, clean)
+}
+
+func (s inputSource) join(elem ...string) string {
+    if s.fsys == nil {
+        return filepath.Join(elem...)
+    }
+    return path.Join(elem...)
+}
+
+// outputFS returns fsys, or OSFS when it is nil.
+func outputFS(fsys WritableFS) WritableFS {
+    if fsys == nil {
+        return OSFS{}
+    }
+    return fsys
+}
+
+// isLocalOutput reports whether fsys writes native OS paths, in which case
+// paths can be compared with the local input directory.
+func isLocalOutput(fsys WritableFS) bool {
+    _, ok := fsys.(OSFS)
+    return ok
+}
+
+func isNotExist(err error) bool {
+    return errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,175 @@
+package imagesplit
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	testdata "github.com/zsq2010/utils/imagesplit/testdata"
+)
+
+// writePNG encodes img as a PNG file in mem.
+func writePNG(t *testing.T, mem *MemFS, name string, img image.Image) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode %s: %v", name, err)
+	}
+	if err := mem.WriteFile(name, buf.Bytes()); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+// readImage decodes an image file in mem.
+func readImage(t *testing.T, mem *MemFS, name string) image.Image {
+	t.Helper()
+	data, err := mem.ReadFile(name)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode %s: %v", name, err)
+	}
+	return img
+}
+
+func sampleInputFS(t *testing.T) fstest.MapFS {
+	t.Helper()
+	gradient, err := testdata.GradientPNG()
+	if err != nil {
+		t.Fatalf("gradient png: %v", err)
+	}
+	blocks, err := testdata.BlocksJPEG()
+	if err != nil {
+		t.Fatalf("blocks jpeg: %v", err)
+	}
+	return fstest.MapFS{
+		"images/gradient.png": {Data: gradient},
+		"images/blocks.jpg":   {Data: blocks},
+		"images/readme.txt":   {Data: []byte("ignored")},
+	}
+}
+
+func TestGridSplitFSToMemFS(t *testing.T) {
+	out := NewMemFS()
+	files, err := GridSplit("images/gradient.png", 2, 2, SplitOptions{
+		InputFS:   sampleInputFS(t),
+		OutputFS:  out,
+		OutputDir: "tiles",
+	})
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	if len(files) != 4 || files[0] != "tiles/gradient_row0_col0.png" {
+		t.Fatalf("unexpected files: %v", files)
+	}
+	for _, name := range files {
+		if img := readImage(t, out, name); img.Bounds().Dx() != 5 || img.Bounds().Dy() != 5 {
+			t.Errorf("unexpected tile size %v", img.Bounds())
+		}
+	}
+
+	if err := fstest.TestFS(out, files...); err != nil {
+		t.Fatalf("MemFS does not behave like an fs.FS: %v", err)
+	}
+}
+
+func TestSplitDirectoryFSToMemFS(t *testing.T) {
+	out := NewMemFS()
+	cfg := DirectorySplitConfig{
+		Mode:        DirectorySplitModeTile,
+		TileWidth:   6,
+		TileHeight:  6,
+		Incremental: true,
+		Options:     SplitOptions{InputFS: sampleInputFS(t), OutputFS: out},
+	}
+
+	results, err := SplitDirectory("images", "out", cfg)
+	if err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 images, got %v", results)
+	}
+	if got := results["images/blocks.jpg"]; len(got) != 4 || got[0] != "out/blocks/blocks_tile_0.jpg" {
+		t.Fatalf("unexpected outputs for blocks.jpg: %v", got)
+	}
+	if _, err := out.Stat("out/" + StateFileName); err != nil {
+		t.Fatalf("expected state file in MemFS: %v", err)
+	}
+
+	plan, err := PlanDirectory("images", "out", cfg)
+	if err != nil {
+		t.Fatalf("PlanDirectory returned error: %v", err)
+	}
+	if plan.TotalTiles != 8 {
+		t.Errorf("expected 8 planned tiles, got %d", plan.TotalTiles)
+	}
+}
+
+func TestMemFSAsInput(t *testing.T) {
+	mem := NewMemFS()
+	data, err := testdata.GradientPNG()
+	if err != nil {
+		t.Fatalf("gradient png: %v", err)
+	}
+	if err := mem.WriteFile("src/in.png", data); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	files, err := TileSplit("src/in.png", 5, 10, SplitOptions{InputFS: mem, OutputFS: mem})
+	if err != nil {
+		t.Fatalf("TileSplit returned error: %v", err)
+	}
+	if len(files) != 2 || files[1] != "src/in_tile_1.png" {
+		t.Fatalf("unexpected files: %v", files)
+	}
+}
+
+func TestMemFSOutputFileAbort(t *testing.T) {
+	mem := NewMemFS()
+	f, err := mem.Create("a/b.txt")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	f.Write([]byte("data"))
+	if _, err := mem.Stat("a/b.txt"); !isNotExist(err) {
+		t.Fatalf("expected uncommitted file to be invisible")
+	}
+	if err := f.Abort(); err != nil {
+		t.Fatalf("Abort: %v", err)
+	}
+	if _, err := mem.Stat("a/b.txt"); !isNotExist(err) {
+		t.Fatalf("expected aborted file to be absent")
+	}
+	if err := mem.Remove("missing"); !isNotExist(err) {
+		t.Fatalf("expected not-exist error, got %v", err)
+	}
+}
+
+func TestDirFSRooted(t *testing.T) {
+	root := t.TempDir()
+	dir := NewDirFS(root)
+	pngPath, _ := createSampleImages(t)
+
+	files, err := GridSplit(pngPath, 1, 2, SplitOptions{OutputFS: dir, OutputDir: "nested/out"})
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	for _, name := range files {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Errorf("expected %s under root: %v", name, err)
+		}
+	}
+
+	if _, err := dir.Create("../escape.png"); err == nil {
+		t.Fatalf("expected error for path escaping the root")
+	}
+	if err := dir.RemoveAll("."); err == nil {
+		t.Fatalf("expected error for removing the root")
+	}
+}
@@ -6,49 +6,62 @@ import (
 )
 
 func tileSplit(inputPath string, tileWidth, tileHeight int, opts SplitOptions) ([]string, error) {
+    if err := validateTileSize(tileWidth, tileHeight); err != nil {
+        return nil, err
+    }
+    return runSplit(inputPath, opts, fixedTileLayout(tileWidth, tileHeight))
+}
+
+func validateTileSize(tileWidth, tileHeight int) error {
     if tileWidth <= 0 {
-        return nil, fmt.Errorf("tileWidth must be greater than zero")
+        return invalidArgf("tileWidth must be greater than zero")
     }
     if tileHeight <= 0 {
-        return nil, fmt.Errorf("tileHeight must be greater than zero")
-    }
-
-    ctx, err := prepareSplit(inputPath, opts)
-    if err != nil {
-        return nil, err
+        return invalidArgf("tileHeight must be greater than zero")
     }
+    return nil
+}
 
-    result := []string{}
-    index := 0
-
-    for y := ctx.bounds.Min.Y; y < ctx.bounds.Max.Y; y += tileHeight {
-        h := tileHeight
-        if y+h > ctx.bounds.Max.Y {
-            h = ctx.bounds.Max.Y - y
-        }
-        if h <= 0 {
-            break
-        }
+func fixedTileLayout(tileWidth, tileHeight int) tileLayout {
+    return func(bounds image.Rectangle, opts normalizedOptions) ([]TilePlan, error) {
+        tiles := []TilePlan{}
+        index := 0
 
-        for x := ctx.bounds.Min.X; x < ctx.bounds.Max.X; x += tileWidth {
-            w := tileWidth
-            if x+w > ctx.bounds.Max.X {
-                w = ctx.bounds.Max.X - x
+        row := 0
+        for y := bounds.Min.Y; y < bounds.Max.Y; y += tileHeight {
+            h := tileHeight
+            if y+h > bounds.Max.Y {
+                h = bounds.Max.Y - y
             }
-            if w <= 0 {
+            if h <= 0 {
                 break
             }
 
-            rect := image.Rect(x, y, x+w, y+h)
-            name := fmt.Sprintf("%s_tile_%d", ctx.options.prefix, index)
-            output, err := saveTile(ctx.img, rect, ctx.options, name)
-            if err != nil {
-                return nil, err
+            col := 0
+            for x := bounds.Min.X; x < bounds.Max.X; x += tileWidth {
+                w := tileWidth
+                if x+w > bounds.Max.X {
+                    w = bounds.Max.X - x
+                }
+                if w <= 0 {
+                    break
+                }
+
+                name := fmt.Sprintf("%s_tile_%d", opts.prefix, index)
+                tiles = append(tiles, TilePlan{
+                    Name:  name,
+                    Path:  tilePath(opts, name),
+                    Rect:  image.Rect(x, y, x+w, y+h),
+                    Row:   row,
+                    Col:   col,
+                    Index: index,
+                })
+                index++
+                col++
             }
-            result = append(result, output)
-            index++
+            row++
         }
-    }
 
-    return result, nil
+        return tiles, nil
+    }
 }
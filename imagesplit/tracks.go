@@ -0,0 +1,135 @@
+package imagesplit
+
+import "math"
+
+// GridTrack describes the size of a single row or column in a non-uniform grid.
+// A track either has a fixed size in pixels or takes a weighted share of the
+// space left over after all fixed tracks have been placed.
+type GridTrack struct {
+    // Pixels fixes the size of the track. When greater than zero, Weight is
+    // ignored.
+    Pixels int
+    // Weight is the relative share of the remaining space assigned to the
+    // track. A track with neither Pixels nor Weight set behaves like Fill().
+    Weight float64
+}
+
+// GridLayout describes a non-uniform grid by its row and column tracks.
+type GridLayout struct {
+    Rows []GridTrack
+    Cols []GridTrack
+}
+
+func (l GridLayout) hasTracks() bool {
+    return len(l.Rows) > 0 || len(l.Cols) > 0
+}
+
+// Fixed returns a track with a fixed size in pixels.
+func Fixed(pixels int) GridTrack {
+    return GridTrack{Pixels: pixels}
+}
+
+// Weighted returns a track that receives a share of the remaining space
+// proportional to weight.
+func Weighted(weight float64) GridTrack {
+    return GridTrack{Weight: weight}
+}
+
+// Fill returns a track that shares the remaining space equally with other
+// fill tracks.
+func Fill() GridTrack {
+    return GridTrack{Weight: 1}
+}
+
+// Ratios returns weighted tracks for the provided ratios, e.g. Ratios(1, 2, 1)
+// for a 1:2:1 split.
+func Ratios(ratios ...float64) []GridTrack {
+    tracks := make([]GridTrack, len(ratios))
+    for i, r := range ratios {
+        tracks[i] = Weighted(r)
+    }
+    return tracks
+}
+
+// resolveTracks converts tracks into pixel sizes that add up exactly to total.
+// Flexible space is distributed with the largest remainder method so that the
+// result is deterministic and never loses a pixel to rounding.
+func resolveTracks(total int, tracks []GridTrack) ([]int, error) {
+    if len(tracks) == 0 {
+        return nil, invalidArgf("at least one track is required")
+    }
+
+    sizes := make([]int, len(tracks))
+    fixed := 0
+    weightSum := 0.0
+    flexible := 0
+    for i, t := range tracks {
+        switch {
+        case t.Pixels < 0:
+            return nil, invalidArgf("track %d: pixels must not be negative", i)
+        case t.Pixels > 0:
+            sizes[i] = t.Pixels
+            fixed += t.Pixels
+        case t.Weight < 0 || math.IsNaN(t.Weight) || math.IsInf(t.Weight, 0):
+            return nil, invalidArgf("track %d: invalid weight %v", i, t.Weight)
+        default:
+            weightSum += trackWeight(t)
+            flexible++
+        }
+    }
+
+    if fixed > total {
+        return nil, invalidArgf("fixed track sizes (%d) exceed image dimension %d", fixed, total)
+    }
+    remaining := total - fixed
+    if flexible == 0 {
+        if remaining != 0 {
+            return nil, invalidArgf("fixed track sizes (%d) do not match image dimension %d", fixed, total)
+        }
+        return sizes, nil
+    }
+
+    type share struct {
+        index    int
+        fraction float64
+    }
+    shares := make([]share, 0, flexible)
+    assigned := 0
+    for i, t := range tracks {
+        if t.Pixels > 0 {
+            continue
+        }
+        exact := float64(remaining) * trackWeight(t) / weightSum
+        whole := int(math.Floor(exact))
+        sizes[i] = whole
+        assigned += whole
+        shares = append(shares, share{index: i, fraction: exact - float64(whole)})
+    }
+
+    // Hand out the pixels lost to flooring, largest fraction first; ties go to
+    // the earlier track, matching distributeSize.
+    for left := remaining - assigned; left > 0; left-- {
+        best := 0
+        for j := 1; j < len(shares); j++ {
+            if shares[j].fraction > shares[best].fraction {
+                best = j
+            }
+        }
+        sizes[shares[best].index]++
+        shares[best].fraction = -1
+    }
+
+    for i, size := range sizes {
+        if size <= 0 {
+            return nil, invalidArgf("image dimension %d too small for track %d", total, i)
+        }
+    }
+    return sizes, nil
+}
+
+func trackWeight(t GridTrack) float64 {
+    if t.Weight == 0 {
+        return 1
+    }
+    return t.Weight
+}
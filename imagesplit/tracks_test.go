@@ -0,0 +1,148 @@
+package imagesplit
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	testdata "github.com/zsq2010/utils/imagesplit/testdata"
+)
+
+func TestResolveTracks(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  int
+		tracks []GridTrack
+		want   []int
+	}{
+		{"ratios", 100, Ratios(1, 2, 1), []int{25, 50, 25}},
+		{"ratios remainder", 10, Ratios(1, 2, 1), []int{3, 5, 2}},
+		{"fixed and fill", 100, []GridTrack{Fixed(30), Fill()}, []int{30, 70}},
+		{"fixed around fill", 10, []GridTrack{Fixed(2), Fill(), Fill(), Fixed(3)}, []int{2, 3, 2, 3}},
+		{"zero value is fill", 9, []GridTrack{{}, {}, {}}, []int{3, 3, 3}},
+		{"all fixed", 10, []GridTrack{Fixed(4), Fixed(6)}, []int{4, 6}},
+	}
+	for _, tt := range tests {
+		got, err := resolveTracks(tt.total, tt.tracks)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveTracksMatchesDistributeSize(t *testing.T) {
+	for total := 5; total < 40; total++ {
+		for parts := 1; parts <= 5; parts++ {
+			want, err := distributeSize(total, parts)
+			if err != nil {
+				t.Fatalf("distributeSize(%d, %d): %v", total, parts, err)
+			}
+			tracks := make([]GridTrack, parts)
+			for i := range tracks {
+				tracks[i] = Fill()
+			}
+			got, err := resolveTracks(total, tracks)
+			if err != nil {
+				t.Fatalf("resolveTracks(%d, %d): %v", total, parts, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("total %d parts %d: got %v, want %v", total, parts, got, want)
+			}
+		}
+	}
+}
+
+func TestResolveTracksInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  int
+		tracks []GridTrack
+	}{
+		{"empty", 10, nil},
+		{"negative pixels", 10, []GridTrack{Fixed(-1), Fill()}},
+		{"negative weight", 10, []GridTrack{Weighted(-1), Fill()}},
+		{"fixed too large", 10, []GridTrack{Fixed(8), Fixed(4)}},
+		{"fixed too small", 10, []GridTrack{Fixed(4), Fixed(4)}},
+		{"no room for fill", 10, []GridTrack{Fixed(10), Fill()}},
+	}
+	for _, tt := range tests {
+		if _, err := resolveTracks(tt.total, tt.tracks); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestGridSplitLayout(t *testing.T) {
+	pngPath, _ := createSampleImages(t)
+	outDir := t.TempDir()
+
+	layout := GridLayout{
+		Rows: []GridTrack{Fixed(3), Fill()},
+		Cols: Ratios(1, 2, 2),
+	}
+	files, err := GridSplitLayout(pngPath, layout, SplitOptions{OutputDir: outDir})
+	if err != nil {
+		t.Fatalf("GridSplitLayout returned error: %v", err)
+	}
+	if len(files) != 6 {
+		t.Fatalf("expected 6 tiles, got %d", len(files))
+	}
+
+	expectedHeights := []int{3, 7}
+	expectedWidths := []int{2, 4, 4}
+	for i, path := range files {
+		row, col := i/3, i%3
+		want := filepath.Join(outDir, fmt.Sprintf("gradient_row%d_col%d.png", row, col))
+		if path != want {
+			t.Errorf("unexpected path for tile %d: got %s, want %s", i, path, want)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("open tile: %v", err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("decode tile png: %v", err)
+		}
+		if img.Bounds().Dx() != expectedWidths[col] || img.Bounds().Dy() != expectedHeights[row] {
+			t.Errorf("unexpected tile size for row %d col %d: got %dx%d", row, col, img.Bounds().Dx(), img.Bounds().Dy())
+		}
+	}
+}
+
+func TestGridSplitLayoutInvalid(t *testing.T) {
+	pngPath, _ := createSampleImages(t)
+	if _, err := GridSplitLayout(pngPath, GridLayout{Cols: Ratios(1, 1)}, SplitOptions{OutputDir: t.TempDir()}); err == nil {
+		t.Fatalf("expected error for missing row tracks")
+	}
+	layout := GridLayout{Rows: []GridTrack{Fill()}, Cols: []GridTrack{Fixed(20), Fill()}}
+	if _, err := GridSplitLayout(pngPath, layout, SplitOptions{OutputDir: t.TempDir()}); err == nil {
+		t.Fatalf("expected error for fixed track wider than image")
+	}
+}
+
+func TestSplitDirectoryLayout(t *testing.T) {
+	inputDir := t.TempDir()
+	pngPath := filepath.Join(inputDir, "photo.png")
+	if err := testdata.WriteGradientPNG(pngPath); err != nil {
+		t.Fatalf("write gradient png: %v", err)
+	}
+
+	results, err := SplitDirectory(inputDir, t.TempDir(), DirectorySplitConfig{
+		Mode:   DirectorySplitModeGrid,
+		Layout: GridLayout{Rows: []GridTrack{Fill()}, Cols: Ratios(1, 2, 1)},
+	})
+	if err != nil {
+		t.Fatalf("SplitDirectory returned error: %v", err)
+	}
+	if got := len(results[pngPath]); got != 3 {
+		t.Fatalf("expected 3 tiles, got %d", got)
+	}
+}
@@ -0,0 +1,252 @@
+package imagesplit
+
+import "image"
+
+// TransformKind identifies a step of the transform pipeline.
+type TransformKind string
+
+const (
+    // TransformRotate rotates the image clockwise by Degrees.
+    TransformRotate TransformKind = "rotate"
+    // TransformFlipHorizontal mirrors the image left to right.
+    TransformFlipHorizontal TransformKind = "flip-horizontal"
+    // TransformFlipVertical mirrors the image top to bottom.
+    TransformFlipVertical TransformKind = "flip-vertical"
+    // TransformCrop keeps only Rect.
+    TransformCrop TransformKind = "crop"
+    // TransformTrim removes uniform borders.
+    TransformTrim TransformKind = "trim"
+)
+
+// Transform is a step applied to the decoded image before the split geometry
+// is computed. Transforms run in order, each on the result of the previous
+// one; use the constructors Rotate, FlipHorizontal, FlipVertical, CropTo and
+// AutoTrim to build them.
+type Transform struct {
+    Kind TransformKind `json:"kind"`
+    // Degrees is the clockwise rotation of TransformRotate, a multiple of 90.
+    // Negative values rotate counter-clockwise.
+    Degrees int `json:"degrees,omitempty"`
+    // Rect is the region kept by TransformCrop, in the coordinates of the
+    // image produced by the previous transforms. It must lie inside it.
+    Rect image.Rectangle `json:"rect,omitempty"`
+    // Tolerance is the largest per-channel difference, from 0 to 255, at
+    // which TransformTrim still treats a pixel as border.
+    Tolerance uint8 `json:"tolerance,omitempty"`
+}
+
+// Rotate returns a clockwise rotation by degrees, which must be a multiple
+// of 90.
+func Rotate(degrees int) Transform {
+    return Transform{Kind: TransformRotate, Degrees: degrees}
+}
+
+// FlipHorizontal returns a left-to-right mirror.
+func FlipHorizontal() Transform {
+    return Transform{Kind: TransformFlipHorizontal}
+}
+
+// FlipVertical returns a top-to-bottom mirror.
+func FlipVertical() Transform {
+    return Transform{Kind: TransformFlipVertical}
+}
+
+// CropTo returns a crop to r.
+func CropTo(r image.Rectangle) Transform {
+    return Transform{Kind: TransformCrop, Rect: r}
+}
+
+// AutoTrim returns a transform that removes the borders having the color of
+// the top-left pixel, within tolerance per channel. An image made entirely
+// of border color is left unchanged.
+func AutoTrim(tolerance uint8) Transform {
+    return Transform{Kind: TransformTrim, Tolerance: tolerance}
+}
+
+func validateTransforms(ts []Transform) error {
+    for i, t := range ts {
+        switch t.Kind {
+        case TransformRotate:
+            if t.Degrees%90 != 0 {
+                return invalidArgf("transform %d: rotation must be a multiple of 90 degrees, got %d", i, t.Degrees)
+            }
+        case TransformFlipHorizontal, TransformFlipVertical, TransformTrim:
+        case TransformCrop:
+            if t.Rect.Empty() {
+                return invalidArgf("transform %d: crop rectangle %v is empty", i, t.Rect)
+            }
+        default:
+            return invalidArgf("transform %d: unsupported transform: %q", i, t.Kind)
+        }
+    }
+    return nil
+}
+
+// quarterTurns returns the clockwise rotation of t in quarter turns, 0-3.
+func (t Transform) quarterTurns() int {
+    return ((t.Degrees/90)%4 + 4) % 4
+}
+
+func checkCrop(index int, r image.Rectangle, size image.Point) error {
+    if !r.In(image.Rectangle{Max: size}) {
+        return invalidArgf("transform %d: crop rectangle %v is outside the %dx%d image", index, r, size.X, size.Y)
+    }
+    return nil
+}
+
+// transformedSize returns the size of an image of the given size after ts.
+// exact is false when a transform depends on the pixels, in which case the
+// image has to be decoded to know the size.
+func transformedSize(size image.Point, ts []Transform) (result image.Point, exact bool, err error) {
+    if err := validateTransforms(ts); err != nil {
+        return image.Point{}, false, err
+    }
+    for i, t := range ts {
+        switch t.Kind {
+        case TransformRotate:
+            if t.quarterTurns()%2 == 1 {
+                size = image.Pt(size.Y, size.X)
+            }
+        case TransformCrop:
+            if err := checkCrop(i, t.Rect, size); err != nil {
+                return image.Point{}, false, err
+            }
+            size = t.Rect.Size()
+        case TransformTrim:
+            return image.Point{}, false, nil
+        }
+    }
+    return size, true, nil
+}
+
+// loadTransformedSize returns the size of the image at path after ts and
+// its source format. Only the header is read unless a transform depends on
+// the pixels.
+func loadTransformedSize(src inputSource, path string, ts []Transform) (image.Point, string, error) {
+    cfg, format, err := loadImageConfig(src, path)
+    if err != nil {
+        return image.Point{}, "", err
+    }
+    // The standard PNG and JPEG decoders always return images anchored at
+    // the origin, so the header dimensions are the exact image bounds.
+    size, exact, err := transformedSize(image.Pt(cfg.Width, cfg.Height), ts)
+    if err != nil || exact {
+        return size, format, err
+    }
+
+    img, _, err := loadImage(src, path)
+    if err != nil {
+        return image.Point{}, "", err
+    }
+    img, err = applyTransforms(img, ts)
+    if err != nil {
+        return image.Point{}, "", err
+    }
+    return img.Bounds().Size(), format, nil
+}
+
+// applyTransforms runs ts over img in order. The result is anchored at the
+// origin; img is returned unchanged when ts is empty.
+func applyTransforms(img image.Image, ts []Transform) (image.Image, error) {
+    if len(ts) == 0 {
+        return img, nil
+    }
+    if err := validateTransforms(ts); err != nil {
+        return nil, err
+    }
+
+    cur := cropImage(img, img.Bounds())
+    for i, t := range ts {
+        switch t.Kind {
+        case TransformRotate:
+            for n := t.quarterTurns(); n > 0; n-- {
+                cur = rotateClockwise(cur)
+            }
+        case TransformFlipHorizontal:
+            flipHorizontal(cur)
+        case TransformFlipVertical:
+            flipVertical(cur)
+        case TransformCrop:
+            if err := checkCrop(i, t.Rect, cur.Bounds().Size()); err != nil {
+                return nil, err
+            }
+            cur = cropImage(cur, t.Rect)
+        case TransformTrim:
+            if r := trimBounds(cur, t.Tolerance); r != cur.Bounds() {
+                cur = cropImage(cur, r)
+            }
+        }
+    }
+    return cur, nil
+}
+
+// rotateClockwise returns src rotated by 90 degrees clockwise.
+func rotateClockwise(src *image.RGBA) *image.RGBA {
+    b := src.Bounds()
+    dst := image.NewRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+    for y := 0; y < b.Dy(); y++ {
+        for x := 0; x < b.Dx(); x++ {
+            s := src.PixOffset(x, y)
+            d := dst.PixOffset(b.Dy()-1-y, x)
+            copy(dst.Pix[d:d+4], src.Pix[s:s+4])
+        }
+    }
+    return dst
+}
+
+// flipHorizontal mirrors img left to right in place.
+func flipHorizontal(img *image.RGBA) {
+    b := img.Bounds()
+    for y := 0; y < b.Dy(); y++ {
+        for l, r := 0, b.Dx()-1; l < r; l, r = l+1, r-1 {
+            i, j := img.PixOffset(l, y), img.PixOffset(r, y)
+            for k := 0; k < 4; k++ {
+                img.Pix[i+k], img.Pix[j+k] = img.Pix[j+k], img.Pix[i+k]
+            }
+        }
+    }
+}
+
+// flipVertical mirrors img top to bottom in place.
+func flipVertical(img *image.RGBA) {
+    b := img.Bounds()
+    row := make([]byte, 4*b.Dx())
+    for t, u := 0, b.Dy()-1; t < u; t, u = t+1, u-1 {
+        top := img.Pix[img.PixOffset(0, t):][:len(row)]
+        bottom := img.Pix[img.PixOffset(0, u):][:len(row)]
+        copy(row, top)
+        copy(top, bottom)
+        copy(bottom, row)
+    }
+}
+
+// trimBounds returns the smallest rectangle containing every pixel of img
+// that differs from its top-left pixel by more than tolerance in any
+// channel, or the full bounds when there is none.
+func trimBounds(img *image.RGBA, tolerance uint8) image.Rectangle {
+    b := img.Bounds()
+    border := img.Pix[img.PixOffset(0, 0):][:4]
+    isBorder := func(x, y int) bool {
+        p := img.Pix[img.PixOffset(x, y):][:4]
+        for k := range p {
+            d := int(p[k]) - int(border[k])
+            if d > int(tolerance) || -d > int(tolerance) {
+                return false
+            }
+        }
+        return true
+    }
+
+    content := image.Rectangle{}
+    for y := 0; y < b.Dy(); y++ {
+        for x := 0; x < b.Dx(); x++ {
+            if !isBorder(x, y) {
+                content = content.Union(image.Rect(x, y, x+1, y+1))
+            }
+        }
+    }
+    if content.Empty() {
+        return b
+    }
+    return content
+}
@@ -0,0 +1,165 @@
+package imagesplit
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// markedImage returns a white image with a red pixel at (0, 0) and a blue
+// pixel at (w-1, 0).
+func markedImage(w, h int) *image.RGBA {
+	img := solidImage(w, h, color.White)
+	img.SetRGBA(0, 0, color.RGBA{R: 255, A: 255})
+	img.SetRGBA(w-1, 0, color.RGBA{B: 255, A: 255})
+	return img
+}
+
+func TestApplyTransformsGeometry(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+
+	cases := []struct {
+		name       string
+		transforms []Transform
+		size       image.Point
+		red, blue  image.Point
+	}{
+		{"rotate 90", []Transform{Rotate(90)}, image.Pt(3, 4), image.Pt(2, 0), image.Pt(2, 3)},
+		{"rotate 180", []Transform{Rotate(180)}, image.Pt(4, 3), image.Pt(3, 2), image.Pt(0, 2)},
+		{"rotate -90", []Transform{Rotate(-90)}, image.Pt(3, 4), image.Pt(0, 3), image.Pt(0, 0)},
+		{"flip horizontal", []Transform{FlipHorizontal()}, image.Pt(4, 3), image.Pt(3, 0), image.Pt(0, 0)},
+		{"flip vertical", []Transform{FlipVertical()}, image.Pt(4, 3), image.Pt(0, 2), image.Pt(3, 2)},
+		{"crop after rotate", []Transform{Rotate(90), CropTo(image.Rect(1, 0, 3, 4))}, image.Pt(2, 4), image.Pt(1, 0), image.Pt(1, 3)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := applyTransforms(markedImage(4, 3), tc.transforms)
+			if err != nil {
+				t.Fatalf("applyTransforms returned error: %v", err)
+			}
+			if got := out.Bounds(); got != (image.Rectangle{Max: tc.size}) {
+				t.Fatalf("expected bounds %v, got %v", image.Rectangle{Max: tc.size}, got)
+			}
+			rgba := out.(*image.RGBA)
+			if got := rgba.RGBAAt(tc.red.X, tc.red.Y); got != red {
+				t.Errorf("expected red at %v, got %v", tc.red, got)
+			}
+			if got := rgba.RGBAAt(tc.blue.X, tc.blue.Y); got != blue {
+				t.Errorf("expected blue at %v, got %v", tc.blue, got)
+			}
+
+			size, exact, err := transformedSize(image.Pt(4, 3), tc.transforms)
+			if err != nil || !exact || size != tc.size {
+				t.Errorf("transformedSize: expected %v, got %v (exact %v, err %v)", tc.size, size, exact, err)
+			}
+		})
+	}
+}
+
+func TestAutoTrimTolerance(t *testing.T) {
+	img := solidImage(20, 10, color.RGBA{250, 250, 250, 255})
+	// A slightly darker border pixel is trimmed only with enough tolerance.
+	img.SetRGBA(1, 1, color.RGBA{240, 240, 240, 255})
+	for y := 4; y < 7; y++ {
+		for x := 5; x < 12; x++ {
+			img.SetRGBA(x, y, color.RGBA{A: 255})
+		}
+	}
+
+	strict, err := applyTransforms(img, []Transform{AutoTrim(0)})
+	if err != nil {
+		t.Fatalf("applyTransforms returned error: %v", err)
+	}
+	if got := strict.Bounds().Size(); got != image.Pt(11, 6) {
+		t.Fatalf("expected 11x6 without tolerance, got %v", got)
+	}
+
+	tolerant, err := applyTransforms(img, []Transform{AutoTrim(16)})
+	if err != nil {
+		t.Fatalf("applyTransforms returned error: %v", err)
+	}
+	if got := tolerant.Bounds().Size(); got != image.Pt(7, 3) {
+		t.Fatalf("expected 7x3 with tolerance, got %v", got)
+	}
+
+	blank, err := applyTransforms(solidImage(5, 5, color.White), []Transform{AutoTrim(0)})
+	if err != nil {
+		t.Fatalf("applyTransforms returned error: %v", err)
+	}
+	if blank.Bounds().Size() != image.Pt(5, 5) {
+		t.Fatalf("expected a blank image to stay unchanged, got %v", blank.Bounds())
+	}
+}
+
+func TestSplitAppliesTransformsBeforeGeometry(t *testing.T) {
+	mem := NewMemFS()
+	writePNG(t, mem, "scan.png", markedImage(40, 20))
+	opts := SplitOptions{
+		InputFS:    mem,
+		OutputFS:   mem,
+		OutputDir:  "out",
+		Transforms: []Transform{Rotate(90), FlipVertical()},
+	}
+
+	plan, err := PlanGrid("scan.png", 2, 1, opts)
+	if err != nil {
+		t.Fatalf("PlanGrid returned error: %v", err)
+	}
+	if plan.Width != 20 || plan.Height != 40 || plan.Tiles[1].Rect != image.Rect(0, 20, 20, 40) {
+		t.Fatalf("plan does not use the transformed size: %dx%d %v", plan.Width, plan.Height, plan.Tiles[1].Rect)
+	}
+
+	paths, err := GridSplit("scan.png", 2, 1, opts)
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	// Rotating moves the red corner to the top right and the vertical flip
+	// moves it to the bottom right, i.e. into the second tile.
+	img := readImage(t, mem, paths[1])
+	tile := cropImage(img, img.Bounds())
+	if tile.Bounds().Size() != image.Pt(20, 20) || !isRed(tile.RGBAAt(19, 19)) {
+		t.Fatalf("unexpected second tile %v with %v at its corner", tile.Bounds(), tile.RGBAAt(19, 19))
+	}
+}
+
+func TestPlanWithAutoTrimDecodesImage(t *testing.T) {
+	mem := NewMemFS()
+	img := solidImage(30, 30, color.White)
+	for y := 10; y < 20; y++ {
+		for x := 5; x < 25; x++ {
+			img.SetRGBA(x, y, color.RGBA{G: 255, A: 255})
+		}
+	}
+	writePNG(t, mem, "page.png", img)
+
+	plan, err := PlanTile("page.png", 10, 10, SplitOptions{InputFS: mem, Transforms: []Transform{AutoTrim(0)}})
+	if err != nil {
+		t.Fatalf("PlanTile returned error: %v", err)
+	}
+	if plan.Width != 20 || plan.Height != 10 || len(plan.Tiles) != 2 {
+		t.Fatalf("expected a 20x10 plan with 2 tiles, got %dx%d with %d", plan.Width, plan.Height, len(plan.Tiles))
+	}
+}
+
+func TestInvalidTransforms(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "in.png", 10, 10, color.White)
+
+	cases := map[string][]Transform{
+		"rotation":     {Rotate(45)},
+		"empty crop":   {CropTo(image.Rect(2, 2, 2, 5))},
+		"outside crop": {CropTo(image.Rect(0, 0, 11, 5))},
+		"kind":         {{Kind: "blur"}},
+	}
+	for name, transforms := range cases {
+		opts := SplitOptions{InputFS: mem, OutputFS: mem, Transforms: transforms}
+		if _, err := PlanGrid("in.png", 1, 1, opts); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: PlanGrid expected ErrInvalidArgument, got %v", name, err)
+		}
+		if _, err := GridSplit("in.png", 1, 1, opts); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: GridSplit expected ErrInvalidArgument, got %v", name, err)
+		}
+	}
+}
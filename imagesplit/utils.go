@@ -1,36 +1,46 @@
 package imagesplit
 
 import (
+    "bytes"
     "fmt"
     "image"
     "image/draw"
     "image/jpeg"
     "image/png"
-    "os"
+    "io"
     "path/filepath"
     "strings"
 )
 
 type normalizedOptions struct {
-    outputDir string
-    prefix    string
-    format    string
-    extension string
-    quality   int
+    input      inputSource
+    output     WritableFS
+    outputDir  string
+    prefix     string
+    format     string
+    extension  string
+    quality    int
+    watermark  *preparedWatermark
+    color      *preparedColor
+    sizeLimit  *preparedSizeLimit
+    background *preparedBackground
+    selection  *TileSelection
 }
 
 type splitContext struct {
-    img     image.Image
-    bounds  image.Rectangle
-    options normalizedOptions
+    inputPath string
+    img       image.Image
+    bounds    image.Rectangle
+    options   normalizedOptions
+    progress  *progressTracker
 }
 
 func prepareSplit(inputPath string, opts SplitOptions) (*splitContext, error) {
     if inputPath == "" {
-        return nil, fmt.Errorf("input path is required")
+        return nil, invalidArgf("input path is required")
     }
 
-    img, srcFormat, err := loadImage(inputPath)
+    img, srcFormat, err := loadImage(newInputSource(opts.InputFS), inputPath)
     if err != nil {
         return nil, err
     }
@@ -40,15 +50,36 @@ func prepareSplit(inputPath string, opts SplitOptions) (*splitContext, error) {
         return nil, err
     }
 
+    img, err = applyTransforms(img, opts.Transforms)
+    if err != nil {
+        return nil, err
+    }
+    if c := normalized.color; c != nil && c.shared {
+        // The shared palette or threshold must see the tiles as they are
+        // converted, i.e. flattened onto the background.
+        shared := img
+        if normalized.losesAlpha() {
+            flat := cropImage(img, img.Bounds())
+            normalized.background.flatten(flat, img.Bounds().Min)
+            shared = flat
+        }
+        c.share(shared)
+    }
+
+    if err := normalized.output.MkdirAll(normalized.outputDir); err != nil {
+        return nil, outputErrorf("create output directory: %w", err)
+    }
+
     return &splitContext{
-        img:     img,
-        bounds:  img.Bounds(),
-        options: normalized,
+        inputPath: inputPath,
+        img:       img,
+        bounds:    img.Bounds(),
+        options:   normalized,
     }, nil
 }
 
-func loadImage(path string) (image.Image, string, error) {
-    f, err := os.Open(path)
+func loadImage(src inputSource, path string) (image.Image, string, error) {
+    f, err := src.open(path)
     if err != nil {
         return nil, "", fmt.Errorf("open image: %w", err)
     }
@@ -59,14 +90,97 @@ func loadImage(path string) (image.Image, string, error) {
         return nil, "", fmt.Errorf("decode image: %w", err)
     }
 
+    if err := checkInputFormat(format); err != nil {
+        return nil, "", err
+    }
+    return img, format, nil
+}
+
+// loadImageConfig reads only the image header, which is enough to plan a
+// split without decoding any pixels.
+func loadImageConfig(src inputSource, path string) (image.Config, string, error) {
+    f, err := src.open(path)
+    if err != nil {
+        return image.Config{}, "", fmt.Errorf("open image: %w", err)
+    }
+    defer f.Close()
+
+    cfg, format, err := image.DecodeConfig(f)
+    if err != nil {
+        return image.Config{}, "", fmt.Errorf("decode image config: %w", err)
+    }
+
+    if err := checkInputFormat(format); err != nil {
+        return image.Config{}, "", err
+    }
+    return cfg, format, nil
+}
+
+func checkInputFormat(format string) error {
     switch strings.ToLower(format) {
     case "jpeg", "jpg", "png":
-        return img, format, nil
+        return nil
     default:
-        return nil, "", fmt.Errorf("unsupported image format: %s", format)
+        return &classifiedError{msg: fmt.Sprintf("unsupported image format: %s", format), kind: ErrUnsupportedFormat}
     }
 }
 
+// runSplit loads the input image, computes its tiles with layout and writes
+// them. The same layout is used by the Plan functions, so a plan always
+// matches the tiles written here.
+func runSplit(inputPath string, opts SplitOptions, layout tileLayout) ([]string, error) {
+    return runSplitWithProgress(inputPath, opts, layout, newProgressTracker(opts.Observer, 1))
+}
+
+func runSplitWithProgress(inputPath string, opts SplitOptions, layout tileLayout, progress *progressTracker) ([]string, error) {
+    return progress.track(inputPath, func() ([]string, error) {
+        return splitImage(inputPath, opts, layout, progress)
+    })
+}
+
+// splitImage splits one image without reporting its start or end, for
+// callers that track the image themselves.
+func splitImage(inputPath string, opts SplitOptions, layout tileLayout, progress *progressTracker) ([]string, error) {
+    ctx, err := prepareSplit(inputPath, opts)
+    if err != nil {
+        return nil, err
+    }
+    ctx.progress = progress
+
+    tiles, err := layout(ctx.bounds, ctx.options)
+    if err != nil {
+        return nil, err
+    }
+    tiles, err = selectTiles(tiles, ctx.options.selection)
+    if err != nil {
+        return nil, err
+    }
+
+    return writeTiles(ctx, tiles)
+}
+
+// writeTiles writes every tile of an image. If any tile fails, the tiles
+// already written for the image are removed again, so that the output of an
+// image is either complete or absent.
+func writeTiles(ctx *splitContext, tiles []TilePlan) ([]string, error) {
+    result := make([]string, 0, len(tiles))
+    for _, tile := range tiles {
+        saved, err := saveTile(ctx.img, tile.Rect, ctx.options, tile.Name)
+        if err != nil {
+            for _, written := range result {
+                ctx.options.output.Remove(written)
+            }
+            return nil, err
+        }
+        ctx.progress.tileWritten(ctx.inputPath, tile, saved)
+        if saved.flattened && ctx.options.background.policy == AlphaWarn {
+            ctx.progress.warning(ctx.inputPath, fmt.Sprintf("tile %s: transparent pixels were flattened onto the background", tile.Name))
+        }
+        result = append(result, saved.path)
+    }
+    return result, nil
+}
+
 func normalizeOptions(inputPath string, opts SplitOptions, sourceFormat string) (normalizedOptions, error) {
     format := strings.TrimSpace(strings.ToLower(opts.Format))
     if format == "" {
@@ -81,7 +195,7 @@ func normalizeOptions(inputPath string, opts SplitOptions, sourceFormat string)
     case "png":
         extension = "png"
     default:
-        return normalizedOptions{}, fmt.Errorf("unsupported output format: %s", format)
+        return normalizedOptions{}, invalidArgf("unsupported output format: %s", format)
     }
 
     quality := opts.Quality
@@ -94,10 +208,6 @@ func normalizeOptions(inputPath string, opts SplitOptions, sourceFormat string)
         outputDir = filepath.Dir(inputPath)
     }
 
-    if err := os.MkdirAll(outputDir, 0o755); err != nil {
-        return normalizedOptions{}, fmt.Errorf("create output directory: %w", err)
-    }
-
     prefix := strings.TrimSpace(opts.FilePrefix)
     if prefix == "" {
         base := filepath.Base(inputPath)
@@ -110,49 +220,166 @@ func normalizeOptions(inputPath string, opts SplitOptions, sourceFormat string)
         prefix = base
     }
 
+    if err := validateTransforms(opts.Transforms); err != nil {
+        return normalizedOptions{}, err
+    }
+
+    watermark, err := prepareWatermark(opts.Watermark)
+    if err != nil {
+        return normalizedOptions{}, err
+    }
+
+    colorMode, err := prepareColor(opts.Color)
+    if err != nil {
+        return normalizedOptions{}, err
+    }
+
+    sizeLimit, err := prepareSizeLimit(opts.SizeLimit, format, quality)
+    if err != nil {
+        return normalizedOptions{}, err
+    }
+
+    background, err := prepareBackground(opts.Background, opts.AlphaPolicy)
+    if err != nil {
+        return normalizedOptions{}, err
+    }
+
+    if err := validateSelection(opts.Select); err != nil {
+        return normalizedOptions{}, err
+    }
+
     return normalizedOptions{
-        outputDir: outputDir,
-        prefix:    prefix,
-        format:    format,
-        extension: extension,
-        quality:   quality,
+        input:      newInputSource(opts.InputFS),
+        output:     outputFS(opts.OutputFS),
+        outputDir:  outputDir,
+        prefix:     prefix,
+        format:     format,
+        extension:  extension,
+        quality:    quality,
+        watermark:  watermark,
+        color:      colorMode,
+        sizeLimit:  sizeLimit,
+        background: background,
+        selection:  opts.Select,
     }, nil
 }
 
-func saveTile(img image.Image, rect image.Rectangle, opts normalizedOptions, name string) (string, error) {
+// savedTile describes a tile written by saveTile.
+type savedTile struct {
+    path  string
+    bytes int64
+    // quality is the JPEG quality the tile was encoded with, 0 for PNG.
+    quality int
+    // size is the encoded size in pixels. It is smaller than the tile when
+    // a size limit downscaled it.
+    size image.Point
+    // flattened reports that transparent pixels were composited over the
+    // background.
+    flattened bool
+}
+
+// saveTile crops rect from img, stamps the watermark, flattens transparency
+// the output cannot store and converts the colors if configured and writes
+// the tile to its final path atomically:
+// the tile is encoded into a temporary output file that is committed under
+// its final name only after encoding succeeded, so readers never observe a
+// partially written tile.
+func saveTile(img image.Image, rect image.Rectangle, opts normalizedOptions, name string) (savedTile, error) {
     if rect.Dx() <= 0 || rect.Dy() <= 0 {
-        return "", fmt.Errorf("invalid tile dimensions: %dx%d", rect.Dx(), rect.Dy())
+        return savedTile{}, fmt.Errorf("invalid tile dimensions: %dx%d", rect.Dx(), rect.Dy())
     }
 
     tile := cropImage(img, rect)
-    filename := fmt.Sprintf("%s.%s", name, opts.extension)
-    outputPath := filepath.Join(opts.outputDir, filename)
+    if opts.watermark != nil {
+        opts.watermark.apply(tile)
+    }
+    saved := savedTile{path: tilePath(opts, name), size: rect.Size()}
+    if opts.losesAlpha() {
+        if opts.background.policy == AlphaError && hasTransparency(tile) {
+            return savedTile{}, &classifiedError{
+                msg:  fmt.Sprintf("tile %s has transparent pixels that %s output cannot store", name, opts.format),
+                kind: ErrAlphaLost,
+            }
+        }
+        saved.flattened = opts.background.flatten(tile, rect.Min)
+    }
 
-    file, err := os.Create(outputPath)
-    if err != nil {
-        return "", fmt.Errorf("create output file: %w", err)
+    var encoded image.Image = tile
+    if opts.color != nil {
+        encoded = opts.color.convert(tile)
+    }
+    if opts.format == "jpeg" {
+        saved.quality = opts.quality
     }
-    defer func() {
-        file.Close()
+
+    // A size limit needs the encoded bytes before anything is written.
+    var limited *bytes.Buffer
+    if opts.sizeLimit != nil {
+        var err error
+        limited, saved.quality, saved.size, err = opts.sizeLimit.encode(encoded, opts)
         if err != nil {
-            os.Remove(outputPath)
+            return savedTile{}, fmt.Errorf("tile %s: %w", name, err)
         }
-    }()
+    }
+
+    file, err := opts.output.Create(saved.path)
+    if err != nil {
+        return savedTile{}, outputErrorf("create output file: %w", err)
+    }
+
+    w := &countingWriter{w: file}
+    if limited != nil {
+        _, err = limited.WriteTo(w)
+    } else {
+        err = encodeTile(w, encoded, opts)
+    }
+    if err != nil {
+        file.Abort()
+        return savedTile{}, err
+    }
+    if err := file.Commit(); err != nil {
+        return savedTile{}, outputErrorf("write output file: %w", err)
+    }
+
+    saved.bytes = w.n
+    return saved, nil
+}
 
+// losesAlpha reports whether the encoded tiles cannot store transparency.
+func (o normalizedOptions) losesAlpha() bool {
+    return o.format == "jpeg" || o.color != nil && o.color.mode != ColorModePalette
+}
+
+func encodeTile(w io.Writer, tile image.Image, opts normalizedOptions) error {
+    var err error
     switch opts.format {
     case "png":
-        err = png.Encode(file, tile)
+        err = png.Encode(w, tile)
     case "jpeg":
-        err = jpeg.Encode(file, tile, &jpeg.Options{Quality: opts.quality})
+        err = jpeg.Encode(w, tile, &jpeg.Options{Quality: opts.quality})
     default:
         err = fmt.Errorf("unsupported output format: %s", opts.format)
     }
-
     if err != nil {
-        return "", fmt.Errorf("encode image: %w", err)
+        return fmt.Errorf("encode image: %w", err)
     }
+    return nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+    w io.Writer
+    n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+    n, err := c.w.Write(p)
+    c.n += int64(n)
+    return n, err
+}
 
-    return outputPath, nil
+func tilePath(opts normalizedOptions, name string) string {
+    return filepath.Join(opts.outputDir, fmt.Sprintf("%s.%s", name, opts.extension))
 }
 
 func cropImage(img image.Image, rect image.Rectangle) *image.RGBA {
@@ -163,7 +390,7 @@ func cropImage(img image.Image, rect image.Rectangle) *image.RGBA {
 
 func distributeSize(total, parts int) ([]int, error) {
     if parts <= 0 {
-        return nil, fmt.Errorf("parts must be positive")
+        return nil, invalidArgf("parts must be positive")
     }
     base := total / parts
     remainder := total % parts
@@ -175,7 +402,7 @@ func distributeSize(total, parts int) ([]int, error) {
             sizes[i]++
         }
         if sizes[i] == 0 {
-            return nil, fmt.Errorf("image dimension %d too small for %d segments", total, parts)
+            return nil, invalidArgf("image dimension %d too small for %d segments", total, parts)
         }
     }
     return sizes, nil
@@ -0,0 +1,536 @@
+package imagesplit
+
+import (
+    "errors"
+    "fmt"
+    "image"
+    "io/fs"
+    "math"
+    "path/filepath"
+    "regexp"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// TileStatus is the outcome of verifying a single tile.
+type TileStatus string
+
+const (
+    // TileOK means the tile matches the source at its position.
+    TileOK TileStatus = "ok"
+    // TileMissing means an expected tile does not exist.
+    TileMissing TileStatus = "missing"
+    // TileExtra means a file looks like a tile of the image but is not part
+    // of the split.
+    TileExtra TileStatus = "extra"
+    // TileMisplaced means the tile does not match its position but matches
+    // another tile position of the same size.
+    TileMisplaced TileStatus = "misplaced"
+    // TileMismatched means the tile does not match the source.
+    TileMismatched TileStatus = "mismatched"
+)
+
+// VerifyOptions configures Verify.
+type VerifyOptions struct {
+    // Plan lists the expected tiles, for example the SplitPlan returned by
+    // PlanGrid and stored as JSON next to the tiles. When nil, the tiles are
+    // discovered in SplitOptions.OutputDir by the GridSplit and TileSplit
+    // naming conventions.
+    Plan *SplitPlan
+    // TilesFS, when set, is used to read the tiles, for example the MemFS
+    // that was used as OutputFS. When nil, the local file system is used.
+    TilesFS fs.FS
+    // MinPSNR is the lowest peak signal-to-noise ratio in dB at which a
+    // lossy (JPEG) tile still matches. Defaults to 30.
+    MinPSNR float64
+    // MinSSIM is the lowest structural similarity, from 0 to 1, at which a
+    // lossy tile still matches. Defaults to 0.9.
+    MinSSIM float64
+}
+
+// TileCheck reports the verification of one tile.
+type TileCheck struct {
+    Path   string     `json:"path"`
+    Status TileStatus `json:"status"`
+    // Rect is the region of the source the tile belongs to. It is empty for
+    // extra tiles and for missing tiles whose position cannot be derived.
+    Rect image.Rectangle `json:"rect"`
+    // Lossy reports a JPEG tile, which is compared by PSNR and SSIM instead
+    // of pixel by pixel.
+    Lossy bool `json:"lossy,omitempty"`
+    // PSNR in dB, capped at 100 for identical pixels, and SSIM are set for
+    // lossy tiles.
+    PSNR float64 `json:"psnr,omitempty"`
+    SSIM float64 `json:"ssim,omitempty"`
+    // DiffPixels counts the pixels of a lossless tile that differ from the
+    // source.
+    DiffPixels int64 `json:"diffPixels,omitempty"`
+    // FoundAt is the region of the source a misplaced tile matches.
+    FoundAt image.Rectangle `json:"foundAt,omitempty"`
+    // Detail explains a status other than TileOK.
+    Detail string `json:"detail,omitempty"`
+}
+
+// VerifyReport is the result of Verify.
+type VerifyReport struct {
+    InputPath string      `json:"inputPath"`
+    Width     int         `json:"width"`
+    Height    int         `json:"height"`
+    Tiles     []TileCheck `json:"tiles"`
+    // Uncovered counts the source pixels that no existing tile covers.
+    Uncovered int64 `json:"uncovered"`
+}
+
+// OK reports whether every tile matches and the tiles cover the whole
+// source.
+func (r *VerifyReport) OK() bool {
+    return r.Uncovered == 0 && r.Count(TileOK) == len(r.Tiles)
+}
+
+// Count returns the number of tiles with the given status.
+func (r *VerifyReport) Count(status TileStatus) int {
+    n := 0
+    for _, t := range r.Tiles {
+        if t.Status == status {
+            n++
+        }
+    }
+    return n
+}
+
+const psnrIdentical = 100
+
+// Verify proves that the split output of inputPath reassembles to the source.
+// The source is read through opts.InputFS and transformed by opts.Transforms;
+// the expected tiles come from vopts.Plan or from the naming conventions for
+// opts.OutputDir and opts.FilePrefix. Each tile is compared with its region
+// of the source, pixel by pixel for PNG and by PSNR and SSIM for JPEG, and
+// other files carrying the tile prefix are reported as extra. When opts
+// select an output that cannot store transparency, the source is flattened
+// onto opts.Background first, as the split did. Tiles that were watermarked
+// or color converted do not match their source.
+//
+// A report is returned whenever the source could be read; use
+// VerifyReport.OK to check the outcome.
+func Verify(inputPath string, vopts VerifyOptions, opts SplitOptions) (*VerifyReport, error) {
+    if vopts.MinPSNR < 0 || vopts.MinSSIM < 0 || vopts.MinSSIM > 1 {
+        return nil, invalidArgf("verify thresholds must be a positive PSNR and an SSIM between 0 and 1")
+    }
+    if vopts.MinPSNR == 0 {
+        vopts.MinPSNR = 30
+    }
+    if vopts.MinSSIM == 0 {
+        vopts.MinSSIM = 0.9
+    }
+
+    ctx, err := prepareVerify(inputPath, opts)
+    if err != nil {
+        return nil, err
+    }
+    src := cropImage(ctx.img, ctx.bounds)
+    if ctx.options.losesAlpha() {
+        ctx.options.background.flatten(src, ctx.bounds.Min)
+    }
+    tiles := newInputSource(vopts.TilesFS)
+
+    var expected []TileCheck
+    dir := ctx.options.outputDir
+    if vopts.Plan != nil {
+        if vopts.Plan.Width != src.Rect.Dx() || vopts.Plan.Height != src.Rect.Dy() {
+            return nil, invalidArgf("plan is for a %dx%d image, the source is %dx%d",
+                vopts.Plan.Width, vopts.Plan.Height, src.Rect.Dx(), src.Rect.Dy())
+        }
+        dir = vopts.Plan.OutputDir
+        for _, t := range vopts.Plan.Tiles {
+            expected = append(expected, TileCheck{Path: t.Path, Rect: t.Rect})
+        }
+    }
+
+    candidates, err := tileCandidates(tiles, dir, ctx.options.prefix)
+    if err != nil {
+        return nil, err
+    }
+    var extra []string
+    if vopts.Plan == nil {
+        expected, extra, err = discoverTiles(tiles, candidates, ctx.options.prefix, src.Rect.Size())
+        if err != nil {
+            return nil, err
+        }
+    } else {
+        planned := make(map[string]bool, len(expected))
+        for _, t := range expected {
+            planned[filepath.Clean(t.Path)] = true
+        }
+        for _, c := range candidates {
+            if !planned[filepath.Clean(c)] {
+                extra = append(extra, c)
+            }
+        }
+    }
+
+    report := &VerifyReport{InputPath: inputPath, Width: src.Rect.Dx(), Height: src.Rect.Dy()}
+    var present []image.Rectangle
+    for _, check := range expected {
+        check = verifyTile(tiles, src, check, expected, vopts)
+        if check.Status != TileMissing {
+            present = append(present, check.Rect.Intersect(src.Rect))
+        }
+        report.Tiles = append(report.Tiles, check)
+    }
+    for _, p := range extra {
+        report.Tiles = append(report.Tiles, TileCheck{Path: p, Status: TileExtra, Detail: "not part of the split"})
+    }
+    report.Uncovered = uncoveredPixels(src.Rect, present)
+    return report, nil
+}
+
+// prepareVerify loads and transforms the source without touching the output.
+func prepareVerify(inputPath string, opts SplitOptions) (*splitContext, error) {
+    if inputPath == "" {
+        return nil, invalidArgf("input path is required")
+    }
+    img, srcFormat, err := loadImage(newInputSource(opts.InputFS), inputPath)
+    if err != nil {
+        return nil, err
+    }
+    normalized, err := normalizeOptions(inputPath, opts, srcFormat)
+    if err != nil {
+        return nil, err
+    }
+    img, err = applyTransforms(img, opts.Transforms)
+    if err != nil {
+        return nil, err
+    }
+    return &splitContext{inputPath: inputPath, img: img, bounds: img.Bounds(), options: normalized}, nil
+}
+
+// tileCandidates lists the image files in dir whose names start with the
+// tile prefix.
+func tileCandidates(src inputSource, dir, prefix string) ([]string, error) {
+    entries, err := src.readDir(dir)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("read output directory: %w", err)
+    }
+    var names []string
+    for _, e := range entries {
+        name := e.Name()
+        if e.IsDir() || !strings.HasPrefix(name, prefix+"_") || !isTileImage(name) {
+            continue
+        }
+        names = append(names, src.join(dir, name))
+    }
+    return names, nil
+}
+
+// discoverTiles derives the expected tiles from file names following the
+// GridSplit ({prefix}_row{r}_col{c}) or TileSplit ({prefix}_tile_{i})
+// convention. Candidates that do not fit the derived layout are extra.
+func discoverTiles(src inputSource, candidates []string, prefix string, size image.Point) ([]TileCheck, []string, error) {
+    quoted := regexp.QuoteMeta(prefix)
+    gridName := regexp.MustCompile(`^` + quoted + `_row(\d+)_col(\d+)\.\w+$`)
+    tileName := regexp.MustCompile(`^` + quoted + `_tile_(\d+)\.\w+$`)
+
+    type found struct {
+        path   string
+        a, b   int
+        size   image.Point
+        sizeOK bool
+    }
+    var grid, fixed []found
+    var extra []string
+    for _, c := range candidates {
+        base := filepath.Base(c)
+        f := found{path: c}
+        if cfg, _, err := loadImageConfig(src, c); err == nil {
+            f.size, f.sizeOK = image.Pt(cfg.Width, cfg.Height), true
+        }
+        if m := gridName.FindStringSubmatch(base); m != nil {
+            // Indices too large for an int saturate and are rejected below.
+            f.a, _ = strconv.Atoi(m[1])
+            f.b, _ = strconv.Atoi(m[2])
+            grid = append(grid, f)
+        } else if m := tileName.FindStringSubmatch(base); m != nil {
+            f.a, _ = strconv.Atoi(m[1])
+            fixed = append(fixed, f)
+        } else {
+            extra = append(extra, c)
+        }
+    }
+
+    var expected []TileCheck
+    switch {
+    case len(grid) > 0:
+        for _, f := range fixed {
+            extra = append(extra, f.path)
+        }
+        // Row heights and column widths are measured on the tiles, which
+        // covers uniform grids as well as GridSplitLayout.
+        // A grid cannot have more rows or columns than there are files, nor
+        // than the image has pixels; stray names beyond that are extra
+        // instead of sizing the grid without limit.
+        rows, cols := 0, 0
+        inGrid := grid[:0]
+        for _, f := range grid {
+            if f.a >= min(len(candidates), size.Y) || f.b >= min(len(candidates), size.X) {
+                extra = append(extra, f.path)
+                continue
+            }
+            rows, cols = max(rows, f.a+1), max(cols, f.b+1)
+            inGrid = append(inGrid, f)
+        }
+        grid = inGrid
+        if len(grid) == 0 {
+            break
+        }
+        heights, widths := make([]int, rows), make([]int, cols)
+        at := make(map[image.Point]string)
+        for _, f := range grid {
+            key := image.Pt(f.b, f.a)
+            if _, dup := at[key]; dup {
+                extra = append(extra, f.path)
+                continue
+            }
+            at[key] = f.path
+            if f.sizeOK {
+                heights[f.a], widths[f.b] = f.size.Y, f.size.X
+            }
+        }
+        dir, ext := filepath.Dir(grid[0].path), filepath.Ext(grid[0].path)
+        for r := 0; r < rows; r++ {
+            for c := 0; c < cols; c++ {
+                check := TileCheck{Path: at[image.Pt(c, r)]}
+                if heights[r] > 0 && widths[c] > 0 {
+                    x, y := sum(widths[:c]), sum(heights[:r])
+                    check.Rect = image.Rect(x, y, x+widths[c], y+heights[r])
+                }
+                if check.Path == "" {
+                    check.Path = filepath.Join(dir, fmt.Sprintf("%s_row%d_col%d%s", prefix, r, c, ext))
+                }
+                expected = append(expected, check)
+            }
+        }
+    case len(fixed) > 0:
+        // Full tiles are the largest ones; edge tiles may be smaller.
+        var tile image.Point
+        for _, f := range fixed {
+            tile.X, tile.Y = max(tile.X, f.size.X), max(tile.Y, f.size.Y)
+        }
+        if tile.X == 0 || tile.Y == 0 {
+            return nil, nil, fmt.Errorf("cannot read the size of any %s_tile_ image", prefix)
+        }
+        cols, rows := ceilDiv(size.X, tile.X), ceilDiv(size.Y, tile.Y)
+        at := make(map[int]string)
+        for _, f := range fixed {
+            if _, dup := at[f.a]; dup || f.a >= rows*cols {
+                extra = append(extra, f.path)
+                continue
+            }
+            at[f.a] = f.path
+        }
+        dir, ext := filepath.Dir(fixed[0].path), filepath.Ext(fixed[0].path)
+        for i := 0; i < rows*cols; i++ {
+            x, y := (i%cols)*tile.X, (i/cols)*tile.Y
+            check := TileCheck{
+                Path: at[i],
+                Rect: image.Rect(x, y, min(x+tile.X, size.X), min(y+tile.Y, size.Y)),
+            }
+            if check.Path == "" {
+                check.Path = filepath.Join(dir, fmt.Sprintf("%s_tile_%d%s", prefix, i, ext))
+            }
+            expected = append(expected, check)
+        }
+    }
+    return expected, extra, nil
+}
+
+// verifyTile compares one expected tile with the source.
+func verifyTile(src inputSource, source *image.RGBA, check TileCheck, expected []TileCheck, vopts VerifyOptions) TileCheck {
+    f, err := src.open(check.Path)
+    if err != nil {
+        check.Status = TileMissing
+        check.Detail = "file does not exist"
+        if !errors.Is(err, fs.ErrNotExist) {
+            check.Detail = err.Error()
+        }
+        return check
+    }
+    img, format, err := image.Decode(f)
+    f.Close()
+    if err != nil {
+        check.Status = TileMismatched
+        check.Detail = fmt.Sprintf("decode tile: %v", err)
+        return check
+    }
+    tile := cropImage(img, img.Bounds())
+    check.Lossy = format == "jpeg"
+
+    if check.Rect.Empty() {
+        check.Status = TileMismatched
+        check.Detail = "position cannot be derived from the other tiles"
+        return check
+    }
+    if tile.Rect.Size() != check.Rect.Size() {
+        check.Status = TileMismatched
+        check.Detail = fmt.Sprintf("tile is %dx%d, expected %dx%d", tile.Rect.Dx(), tile.Rect.Dy(), check.Rect.Dx(), check.Rect.Dy())
+        return check
+    }
+
+    matches := func(rect image.Rectangle, c *TileCheck) bool {
+        region := cropImage(source, rect)
+        if !c.Lossy {
+            c.DiffPixels = diffPixels(tile, region)
+            return c.DiffPixels == 0
+        }
+        c.PSNR = psnr(tile, region)
+        c.SSIM = ssim(tile, region)
+        return c.PSNR >= vopts.MinPSNR && c.SSIM >= vopts.MinSSIM
+    }
+    if matches(check.Rect, &check) {
+        check.Status = TileOK
+        return check
+    }
+
+    for _, other := range expected {
+        if other.Rect == check.Rect || other.Rect.Size() != check.Rect.Size() {
+            continue
+        }
+        probe := TileCheck{Lossy: check.Lossy}
+        if matches(other.Rect, &probe) {
+            check.Status = TileMisplaced
+            check.FoundAt = other.Rect
+            check.Detail = fmt.Sprintf("content belongs at %v", other.Rect)
+            return check
+        }
+    }
+    check.Status = TileMismatched
+    if check.Lossy {
+        check.Detail = fmt.Sprintf("PSNR %.2f dB, SSIM %.4f below the thresholds", check.PSNR, check.SSIM)
+    } else {
+        check.Detail = fmt.Sprintf("%d pixels differ", check.DiffPixels)
+    }
+    return check
+}
+
+func diffPixels(a, b *image.RGBA) int64 {
+    var n int64
+    for i := 0; i < len(a.Pix); i += 4 {
+        if [4]uint8(a.Pix[i:i+4]) != [4]uint8(b.Pix[i:i+4]) {
+            n++
+        }
+    }
+    return n
+}
+
+// psnr returns the peak signal-to-noise ratio of the RGB channels in dB.
+func psnr(a, b *image.RGBA) float64 {
+    var sum float64
+    for i := 0; i < len(a.Pix); i += 4 {
+        for k := 0; k < 3; k++ {
+            d := float64(a.Pix[i+k]) - float64(b.Pix[i+k])
+            sum += d * d
+        }
+    }
+    if sum == 0 {
+        return psnrIdentical
+    }
+    mse := sum / float64(3*len(a.Pix)/4)
+    return math.Min(psnrIdentical, 10*math.Log10(255*255/mse))
+}
+
+// ssim returns the mean structural similarity of the luminance of a and b
+// over 8x8 windows moved in steps of 4 pixels.
+func ssim(a, b *image.RGBA) float64 {
+    const (
+        window = 8
+        step   = 4
+        c1     = (0.01 * 255) * (0.01 * 255)
+        c2     = (0.03 * 255) * (0.03 * 255)
+    )
+    luma := func(img *image.RGBA, x, y int) float64 {
+        i := img.PixOffset(x, y)
+        return 0.299*float64(img.Pix[i]) + 0.587*float64(img.Pix[i+1]) + 0.114*float64(img.Pix[i+2])
+    }
+
+    w, h := a.Rect.Dx(), a.Rect.Dy()
+    ww, wh := min(window, w), min(window, h)
+    var total float64
+    var windows int
+    for y := 0; y+wh <= h; y += step {
+        for x := 0; x+ww <= w; x += step {
+            var sa, sb, saa, sbb, sab float64
+            for dy := 0; dy < wh; dy++ {
+                for dx := 0; dx < ww; dx++ {
+                    va, vb := luma(a, x+dx, y+dy), luma(b, x+dx, y+dy)
+                    sa, sb = sa+va, sb+vb
+                    saa, sbb, sab = saa+va*va, sbb+vb*vb, sab+va*vb
+                }
+            }
+            n := float64(ww * wh)
+            ma, mb := sa/n, sb/n
+            va, vb, cov := saa/n-ma*ma, sbb/n-mb*mb, sab/n-ma*mb
+            total += ((2*ma*mb + c1) * (2*cov + c2)) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+            windows++
+        }
+    }
+    if windows == 0 {
+        return 1
+    }
+    return total / float64(windows)
+}
+
+// uncoveredPixels returns the area of bounds not covered by any of rects,
+// using coordinate compression over the rectangle edges.
+func uncoveredPixels(bounds image.Rectangle, rects []image.Rectangle) int64 {
+    xs := []int{bounds.Min.X, bounds.Max.X}
+    ys := []int{bounds.Min.Y, bounds.Max.Y}
+    for _, r := range rects {
+        xs = append(xs, r.Min.X, r.Max.X)
+        ys = append(ys, r.Min.Y, r.Max.Y)
+    }
+    slices.Sort(xs)
+    slices.Sort(ys)
+    xs, ys = slices.Compact(xs), slices.Compact(ys)
+
+    covered := make([]bool, len(xs)*len(ys))
+    for _, r := range rects {
+        x0, _ := slices.BinarySearch(xs, r.Min.X)
+        x1, _ := slices.BinarySearch(xs, r.Max.X)
+        y0, _ := slices.BinarySearch(ys, r.Min.Y)
+        y1, _ := slices.BinarySearch(ys, r.Max.Y)
+        for y := y0; y < y1; y++ {
+            for x := x0; x < x1; x++ {
+                covered[y*len(xs)+x] = true
+            }
+        }
+    }
+
+    var area int64
+    for y := 0; y+1 < len(ys); y++ {
+        for x := 0; x+1 < len(xs); x++ {
+            if !covered[y*len(xs)+x] {
+                area += int64(xs[x+1]-xs[x]) * int64(ys[y+1]-ys[y])
+            }
+        }
+    }
+    return area
+}
+
+func isTileImage(name string) bool {
+    switch strings.ToLower(filepath.Ext(name)) {
+    case ".png", ".jpg", ".jpeg":
+        return true
+    }
+    return false
+}
+
+func sum(values []int) int {
+    total := 0
+    for _, v := range values {
+        total += v
+    }
+    return total
+}
@@ -0,0 +1,165 @@
+package imagesplit
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func splitNoise(t *testing.T, format string) (*MemFS, SplitOptions) {
+	t.Helper()
+	mem := NewMemFS()
+	writeNoisePNG(t, mem, "noise.png", 64, 48)
+	opts := SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "tiles", Format: format}
+	if _, err := GridSplit("noise.png", 2, 2, opts); err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	return mem, opts
+}
+
+func verifyNoise(t *testing.T, mem *MemFS, vopts VerifyOptions, opts SplitOptions) *VerifyReport {
+	t.Helper()
+	vopts.TilesFS = mem
+	report, err := Verify("noise.png", vopts, opts)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	return report
+}
+
+func TestVerifyLosslessGrid(t *testing.T) {
+	mem, opts := splitNoise(t, "png")
+
+	report := verifyNoise(t, mem, VerifyOptions{}, opts)
+	if !report.OK() || len(report.Tiles) != 4 || report.Uncovered != 0 {
+		t.Fatalf("expected a clean report with 4 tiles, got %+v", report)
+	}
+	if got := report.Tiles[3].Rect; got != image.Rect(32, 24, 64, 48) {
+		t.Fatalf("unexpected rect of the last tile: %v", got)
+	}
+}
+
+func TestVerifyReportsProblems(t *testing.T) {
+	mem, opts := splitNoise(t, "png")
+
+	a, _ := mem.ReadFile("tiles/noise_row0_col0.png")
+	b, _ := mem.ReadFile("tiles/noise_row0_col1.png")
+	mem.WriteFile("tiles/noise_row0_col0.png", b)
+	mem.WriteFile("tiles/noise_row0_col1.png", a)
+	mem.Remove("tiles/noise_row1_col1.png")
+	writeNoisePNG(t, mem, "tiles/noise_row1_col0.png", 32, 24)
+	mem.WriteFile("tiles/noise_tile_7.png", a)
+
+	report := verifyNoise(t, mem, VerifyOptions{}, opts)
+	if report.OK() {
+		t.Fatal("expected the report to fail")
+	}
+	status := make(map[string]TileStatus)
+	for _, tile := range report.Tiles {
+		status[tile.Path] = tile.Status
+	}
+	expected := map[string]TileStatus{
+		"tiles/noise_row0_col0.png": TileMisplaced,
+		"tiles/noise_row0_col1.png": TileMisplaced,
+		"tiles/noise_row1_col0.png": TileMismatched,
+		"tiles/noise_row1_col1.png": TileMissing,
+		"tiles/noise_tile_7.png":    TileExtra,
+	}
+	for path, want := range expected {
+		if status[path] != want {
+			t.Errorf("%s: expected %s, got %q", path, want, status[path])
+		}
+	}
+	if report.Uncovered != 32*24 {
+		t.Fatalf("expected the missing tile to be uncovered, got %d pixels", report.Uncovered)
+	}
+}
+
+func TestVerifyReportsStrayGridIndicesAsExtra(t *testing.T) {
+	for _, name := range []string{
+		"tiles/noise_row999999999_col999999999.png",
+		"tiles/noise_row0_col99999999999999999999999.png",
+		// Five tile files cannot form six rows.
+		"tiles/noise_row5_col0.png",
+	} {
+		mem, opts := splitNoise(t, "png")
+		a, _ := mem.ReadFile("tiles/noise_row0_col0.png")
+		mem.WriteFile(name, a)
+		report := verifyNoise(t, mem, VerifyOptions{}, opts)
+		if report.OK() || len(report.Tiles) != 5 || report.Count(TileOK) != 4 {
+			t.Fatalf("%s: expected the 2x2 grid to verify, got %+v", name, report.Tiles)
+		}
+		if last := report.Tiles[4]; last.Path != name || last.Status != TileExtra {
+			t.Errorf("%s: expected the stray file to be extra, got %+v", name, last)
+		}
+	}
+}
+
+func TestVerifyFlattensTransparentSource(t *testing.T) {
+	mem := NewMemFS()
+	writeHalfTransparentPNG(t, mem, "logo.png", 64, 48)
+	opts := SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "tiles", Format: "jpeg"}
+	if _, err := GridSplit("logo.png", 2, 2, opts); err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+
+	report, err := Verify("logo.png", VerifyOptions{TilesFS: mem}, opts)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if !report.OK() {
+		t.Fatalf("expected the flattened tiles to verify, got %+v", report.Tiles)
+	}
+}
+
+func TestVerifyLossyThresholds(t *testing.T) {
+	// Noise is the worst case for JPEG, so use a smooth source.
+	mem := NewMemFS()
+	writePNG(t, mem, "noise.png", gradient(64, 48))
+	opts := SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "tiles", Format: "jpeg"}
+	if _, err := GridSplit("noise.png", 2, 2, opts); err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+
+	report := verifyNoise(t, mem, VerifyOptions{}, opts)
+	if !report.OK() {
+		t.Fatalf("expected JPEG tiles to pass, got %+v", report.Tiles)
+	}
+	if tile := report.Tiles[0]; !tile.Lossy || tile.PSNR < 30 || tile.SSIM < 0.9 {
+		t.Fatalf("unexpected lossy metrics %+v", tile)
+	}
+
+	report = verifyNoise(t, mem, VerifyOptions{MinPSNR: 99}, opts)
+	if report.Count(TileMismatched) != 4 {
+		t.Fatalf("expected an unreachable PSNR to fail every tile, got %+v", report.Tiles)
+	}
+}
+
+func TestVerifyAgainstPlan(t *testing.T) {
+	mem := NewMemFS()
+	writeNoisePNG(t, mem, "noise.png", 50, 30)
+	opts := SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "tiles", Format: "png"}
+	plan, err := PlanTile("noise.png", 20, 20, opts)
+	if err != nil {
+		t.Fatalf("PlanTile returned error: %v", err)
+	}
+	if _, err := TileSplit("noise.png", 20, 20, opts); err != nil {
+		t.Fatalf("TileSplit returned error: %v", err)
+	}
+
+	report := verifyNoise(t, mem, VerifyOptions{Plan: plan}, opts)
+	if !report.OK() || len(report.Tiles) != 6 {
+		t.Fatalf("expected 6 matching tiles, got %+v", report)
+	}
+
+	// Discovery derives the same layout from the file names.
+	report = verifyNoise(t, mem, VerifyOptions{}, opts)
+	if !report.OK() || len(report.Tiles) != 6 {
+		t.Fatalf("expected discovery to find 6 matching tiles, got %+v", report)
+	}
+
+	plan.Width++
+	if _, err := Verify("noise.png", VerifyOptions{Plan: plan, TilesFS: mem}, opts); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected a plan for another size to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package imagesplit
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+    // Interval is the time between two scans of the input directory.
+    // Defaults to two seconds.
+    Interval time.Duration
+    // Settle is how long the size and modification time of an image, and
+    // of its sidecar file, must stay unchanged before it is split, so that
+    // files still being copied are left alone. Defaults to Interval.
+    Settle time.Duration
+    // ProcessedDir, when set, receives every original, together with its
+    // sidecar file, once it was split.
+    ProcessedDir string
+    // FailedDir, when set, receives every original whose split failed.
+    FailedDir string
+    // OnImage, when set, is called after every image Watch handled.
+    OnImage func(WatchResult)
+}
+
+// WatchResult reports an image handled by Watch.
+type WatchResult struct {
+    InputPath string
+    // Outputs lists the tiles of the image, as SplitDirectory returns them.
+    Outputs []string
+    // Err is the failure of the split or of moving the original.
+    Err error
+    // MovedTo is the new path of the original, empty when it was not moved.
+    MovedTo string
+}
+
+// Watch monitors inputDir by polling and splits every image that appears or
+// changes with cfg, exactly as SplitDirectory would, into its subdirectory
+// of outputDir. Images already present when Watch starts are split too; set
+// cfg.Incremental to skip those that were split before. An image is split
+// once it has settled (see WatchOptions.Settle) and again only after it
+// changed. A failed image does not stop the watch; it is reported through
+// WatchOptions.OnImage and cfg.Options.Observer.
+//
+// Moving originals uses os.Rename and requires the local file system, so
+// ProcessedDir and FailedDir cannot be combined with cfg.Options.InputFS and
+// must be on the same volume as inputDir. cfg.PruneStale is not supported.
+//
+// Watch returns when ctx is done, after finishing the image it is splitting,
+// with the error of ctx. It returns early only for invalid arguments or when
+// inputDir cannot be read.
+func Watch(ctx context.Context, inputDir, outputDir string, cfg DirectorySplitConfig, wopts WatchOptions) error {
+    if err := validateDirectoryArgs(inputDir, outputDir, cfg); err != nil {
+        return err
+    }
+    if cfg.PruneStale {
+        return invalidArgf("pruning stale outputs is not supported in watch mode")
+    }
+    if wopts.Interval < 0 || wopts.Settle < 0 {
+        return invalidArgf("watch interval and settle time must not be negative")
+    }
+    if wopts.Interval == 0 {
+        wopts.Interval = 2 * time.Second
+    }
+    if wopts.Settle == 0 {
+        wopts.Settle = wopts.Interval
+    }
+    policy, err := normalizeOverwritePolicy(cfg.Overwrite)
+    if err != nil {
+        return err
+    }
+
+    for _, dir := range []string{wopts.ProcessedDir, wopts.FailedDir} {
+        if strings.TrimSpace(dir) == "" {
+            continue
+        }
+        if cfg.Options.InputFS != nil {
+            return invalidArgf("moving originals requires the local file system")
+        }
+        resolvedDir, err := resolvePath(dir)
+        if err != nil {
+            return err
+        }
+        resolvedInput, err := resolvePath(inputDir)
+        if err != nil {
+            return err
+        }
+        if resolvedDir == resolvedInput {
+            return invalidArgf("cannot move originals into the watched directory %s", inputDir)
+        }
+        if err := os.MkdirAll(dir, 0o755); err != nil {
+            return fmt.Errorf("create directory for originals: %w", err)
+        }
+    }
+    if err := outputFS(cfg.Options.OutputFS).MkdirAll(outputDir); err != nil {
+        return outputErrorf("create output directory: %w", err)
+    }
+
+    w := &watcher{
+        inputDir:  inputDir,
+        outputDir: outputDir,
+        cfg:       cfg,
+        policy:    policy,
+        opts:      wopts,
+        src:       newInputSource(cfg.Options.InputFS),
+        files:     make(map[string]*watchedFile),
+    }
+    ticker := time.NewTicker(wopts.Interval)
+    defer ticker.Stop()
+    for {
+        if err := w.scan(ctx, time.Now()); err != nil {
+            return err
+        }
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-ticker.C:
+        }
+    }
+}
+
+// fileSignature identifies the state of an image and its sidecar file.
+type fileSignature struct {
+    size, sidecarSize       int64
+    modTime, sidecarModTime time.Time
+}
+
+type watchedFile struct {
+    sig fileSignature
+    // since is when sig was first observed.
+    since time.Time
+    // done reports that the image was handled in this state.
+    done bool
+}
+
+type watcher struct {
+    inputDir  string
+    outputDir string
+    cfg       DirectorySplitConfig
+    policy    OverwritePolicy
+    opts      WatchOptions
+    src       inputSource
+    files     map[string]*watchedFile
+}
+
+// scan splits every image that is ready at now.
+func (w *watcher) scan(ctx context.Context, now time.Time) error {
+    ready, err := w.ready(now)
+    if err != nil {
+        return err
+    }
+
+    progress := newProgressTracker(w.cfg.Options.Observer, len(ready))
+    for i := range ready {
+        if ctx.Err() != nil {
+            return nil
+        }
+        job := ready[i : i+1]
+        path := job[0].inputPath
+        result := WatchResult{InputPath: path}
+
+        // Sidecars are loaded per image, so that a bad one fails only its
+        // image.
+        if w.cfg.Sidecars {
+            result.Err = loadSidecars(w.cfg, job)
+        }
+        if result.Err == nil {
+            var results map[string][]string
+            results, result.Err = runDirectoryJobs(w.inputDir, w.outputDir, w.cfg, w.policy, job, false, progress)
+            result.Outputs = results[path]
+        }
+        w.files[path].done = true
+
+        movedTo, err := w.move(path, result.Err == nil)
+        result.MovedTo = movedTo
+        if err != nil {
+            result.Err = errors.Join(result.Err, err)
+        }
+        if w.opts.OnImage != nil {
+            w.opts.OnImage(result)
+        }
+    }
+    return nil
+}
+
+// ready returns the images that have settled at now and were not handled in
+// their current state.
+func (w *watcher) ready(now time.Time) ([]directoryJob, error) {
+    jobs, err := directoryJobs(w.src, w.inputDir, w.outputDir)
+    if err != nil {
+        return nil, err
+    }
+
+    present := make(map[string]bool, len(jobs))
+    var ready []directoryJob
+    for _, job := range jobs {
+        info, err := w.src.stat(job.inputPath)
+        if err != nil {
+            // Removed since the directory was listed.
+            continue
+        }
+        present[job.inputPath] = true
+        sig := fileSignature{size: info.Size(), modTime: info.ModTime()}
+        if w.cfg.Sidecars {
+            if info, err := w.src.stat(job.inputPath + SidecarSuffix); err == nil {
+                sig.sidecarSize, sig.sidecarModTime = info.Size(), info.ModTime()
+            }
+        }
+
+        f := w.files[job.inputPath]
+        if f == nil || f.sig != sig {
+            w.files[job.inputPath] = &watchedFile{sig: sig, since: now}
+            continue
+        }
+        if !f.done && now.Sub(f.since) >= w.opts.Settle {
+            ready = append(ready, job)
+        }
+    }
+
+    // Forget removed images, so that a file dropped again under the same
+    // name is split again.
+    for path := range w.files {
+        if !present[path] {
+            delete(w.files, path)
+        }
+    }
+    return ready, nil
+}
+
+// move moves the original at path, and its sidecar file, to the processed
+// or failed directory and returns its new path.
+func (w *watcher) move(path string, succeeded bool) (string, error) {
+    dir := w.opts.FailedDir
+    if succeeded {
+        dir = w.opts.ProcessedDir
+    }
+    if strings.TrimSpace(dir) == "" {
+        return "", nil
+    }
+
+    target := availablePath(dir, filepath.Base(path))
+    if err := os.Rename(path, target); err != nil {
+        return "", fmt.Errorf("move original: %w", err)
+    }
+    sidecar := path + SidecarSuffix
+    if _, err := os.Stat(sidecar); err == nil {
+        if err := os.Rename(sidecar, target+SidecarSuffix); err != nil {
+            return target, fmt.Errorf("move sidecar: %w", err)
+        }
+    }
+    return target, nil
+}
+
+// availablePath returns dir/name, or dir/base_N.ext for the first N that
+// does not exist yet, so that originals dropped twice do not overwrite each
+// other.
+func availablePath(dir, name string) string {
+    target := filepath.Join(dir, name)
+    ext := filepath.Ext(name)
+    for n := 2; ; n++ {
+        if _, err := os.Stat(target); err != nil {
+            // Free, or unreadable, in which case os.Rename reports why.
+            return target
+        }
+        target = filepath.Join(dir, fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), n, ext))
+    }
+}
@@ -0,0 +1,166 @@
+package imagesplit
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	testdata "github.com/zsq2010/utils/imagesplit/testdata"
+)
+
+func TestWatcherWaitsForFilesToSettle(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "in/a.png", 20, 20, color.White)
+	w := &watcher{
+		inputDir:  "in",
+		outputDir: "out",
+		opts:      WatchOptions{Settle: time.Second},
+		src:       newInputSource(mem),
+		files:     make(map[string]*watchedFile),
+	}
+
+	t0 := time.Now()
+	readyAt := func(at time.Time) int {
+		t.Helper()
+		ready, err := w.ready(at)
+		if err != nil {
+			t.Fatalf("ready returned error: %v", err)
+		}
+		return len(ready)
+	}
+
+	if n := readyAt(t0); n != 0 {
+		t.Fatalf("expected a new file to wait, got %d ready", n)
+	}
+	if n := readyAt(t0.Add(500 * time.Millisecond)); n != 0 {
+		t.Fatalf("expected the file to wait for the settle time, got %d ready", n)
+	}
+
+	// Still growing: the settle time starts over.
+	writeColorPNG(t, mem, "in/a.png", 40, 20, color.White)
+	if n := readyAt(t0.Add(time.Second)); n != 0 {
+		t.Fatalf("expected a changed file to wait, got %d ready", n)
+	}
+	if n := readyAt(t0.Add(2 * time.Second)); n != 1 {
+		t.Fatalf("expected the settled file to be ready, got %d", n)
+	}
+
+	w.files["in/a.png"].done = true
+	if n := readyAt(t0.Add(3 * time.Second)); n != 0 {
+		t.Fatalf("expected a handled file not to be split again, got %d ready", n)
+	}
+
+	// Dropped again after being removed.
+	mem.Remove("in/a.png")
+	readyAt(t0.Add(4 * time.Second))
+	writeColorPNG(t, mem, "in/a.png", 40, 20, color.White)
+	readyAt(t0.Add(5 * time.Second))
+	if n := readyAt(t0.Add(6 * time.Second)); n != 1 {
+		t.Fatalf("expected a file dropped again to be split again, got %d ready", n)
+	}
+}
+
+func TestWatchSplitsAndMovesOriginals(t *testing.T) {
+	root := t.TempDir()
+	inputDir := filepath.Join(root, "in")
+	outDir := filepath.Join(root, "out")
+	processed := filepath.Join(root, "processed")
+	failed := filepath.Join(root, "failed")
+	if err := os.MkdirAll(inputDir, 0o755); err != nil {
+		t.Fatalf("create input directory: %v", err)
+	}
+	if err := testdata.WriteGradientPNG(filepath.Join(inputDir, "good.png")); err != nil {
+		t.Fatalf("write gradient png: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "bad.png"), []byte("not an image"), 0o644); err != nil {
+		t.Fatalf("write broken image: %v", err)
+	}
+
+	results := make(chan WatchResult, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, inputDir, outDir, DirectorySplitConfig{Mode: DirectorySplitModeGrid, Rows: 2, Cols: 2}, WatchOptions{
+			Interval:     10 * time.Millisecond,
+			ProcessedDir: processed,
+			FailedDir:    failed,
+			OnImage:      func(r WatchResult) { results <- r },
+		})
+	}()
+
+	got := make(map[string]WatchResult)
+	for len(got) < 2 {
+		select {
+		case r := <-results:
+			got[filepath.Base(r.InputPath)] = r
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for results, got %v", got)
+		}
+	}
+
+	good := got["good.png"]
+	if good.Err != nil || len(good.Outputs) != 4 || good.MovedTo != filepath.Join(processed, "good.png") {
+		t.Fatalf("unexpected result for good.png: %+v", good)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "good", "good_row1_col1.png")); err != nil {
+		t.Fatalf("expected tiles of good.png: %v", err)
+	}
+	bad := got["bad.png"]
+	if bad.Err == nil || bad.MovedTo != filepath.Join(failed, "bad.png") {
+		t.Fatalf("unexpected result for bad.png: %+v", bad)
+	}
+	if _, err := os.Stat(filepath.Join(inputDir, "good.png")); !os.IsNotExist(err) {
+		t.Fatalf("expected good.png to be moved out of the input directory, got %v", err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected Watch to stop with context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not stop after cancellation")
+	}
+}
+
+func TestWatchValidation(t *testing.T) {
+	mem := NewMemFS()
+	writeColorPNG(t, mem, "in/a.png", 20, 20, color.White)
+	cfg := DirectorySplitConfig{Mode: DirectorySplitModeGrid, Rows: 1, Cols: 1, Options: SplitOptions{InputFS: mem, OutputFS: mem}}
+
+	ctx := context.Background()
+	if err := Watch(ctx, "in", "out", cfg, WatchOptions{ProcessedDir: "done"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected moving originals of an fs.FS to be rejected, got %v", err)
+	}
+	pruning := cfg
+	pruning.Incremental, pruning.PruneStale = true, true
+	if err := Watch(ctx, "in", "out", pruning, WatchOptions{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected pruning to be rejected, got %v", err)
+	}
+	if err := Watch(ctx, "in", "out", cfg, WatchOptions{Interval: -time.Second}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected a negative interval to be rejected, got %v", err)
+	}
+
+	// The originals directory is compared after resolving symlinks.
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in")
+	if err := os.MkdirAll(input, 0o755); err != nil {
+		t.Fatalf("create input directory: %v", err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(input, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	local := DirectorySplitConfig{Mode: DirectorySplitModeGrid, Rows: 1, Cols: 1}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := Watch(ctx, input, filepath.Join(dir, "out"), local, WatchOptions{FailedDir: link}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected moving originals into the watched directory to be rejected, got %v", err)
+	}
+}
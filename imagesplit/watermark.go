@@ -0,0 +1,272 @@
+package imagesplit
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "image"
+    "image/color"
+    "image/draw"
+    "image/png"
+    "math"
+    "strings"
+    "sync"
+
+    "golang.org/x/image/font"
+    "golang.org/x/image/font/gofont/goregular"
+    "golang.org/x/image/font/opentype"
+    "golang.org/x/image/math/fixed"
+)
+
+// WatermarkPosition anchors a watermark inside a tile.
+type WatermarkPosition string
+
+const (
+    // WatermarkCenter centers the mark in the tile.
+    WatermarkCenter WatermarkPosition = "center"
+    // WatermarkTopLeft places the mark in the top-left corner.
+    WatermarkTopLeft WatermarkPosition = "top-left"
+    // WatermarkTopRight places the mark in the top-right corner.
+    WatermarkTopRight WatermarkPosition = "top-right"
+    // WatermarkBottomLeft places the mark in the bottom-left corner.
+    WatermarkBottomLeft WatermarkPosition = "bottom-left"
+    // WatermarkBottomRight places the mark in the bottom-right corner. It is
+    // the default.
+    WatermarkBottomRight WatermarkPosition = "bottom-right"
+)
+
+// Watermark stamps every generated tile with a text or an image (for example
+// a decoded PNG logo). Exactly one of Text and Image must be set.
+type Watermark struct {
+    // Text is rendered with Font in Color.
+    Text string
+    // Font is TrueType or OpenType font data. When nil, the built-in Go
+    // Regular font is used.
+    Font []byte
+    // Color is the text color. Defaults to white.
+    Color color.Color
+    // Image is drawn instead of a text, keeping its alpha channel.
+    Image image.Image
+    // Position anchors the mark inside the tile. Defaults to
+    // WatermarkBottomRight. It is ignored when Repeat is set.
+    Position WatermarkPosition
+    // Opacity scales the alpha of the mark, from 0 (invisible) to 1. Zero
+    // uses a default of 0.5.
+    Opacity float64
+    // Scale is the width of the mark relative to the tile width, from 0 to 1.
+    // Zero uses a default of 0.25. The mark is shrunk further if it would be
+    // taller than the tile.
+    Scale float64
+    // Margin is the distance to the tile edges relative to the shorter tile
+    // side. Zero uses a default of 0.02.
+    Margin float64
+    // Repeat covers the whole tile with the mark in a regular pattern
+    // instead of placing it once.
+    Repeat bool
+}
+
+// watermarkRenderSize is the font size in pixels at which texts are rendered
+// before being scaled to the tile.
+const watermarkRenderSize = 128
+
+// preparedWatermark is a validated Watermark with its mark rendered once per
+// split. Scaled marks are cached per size because most tiles of a split have
+// the same dimensions.
+type preparedWatermark struct {
+    mark     *image.RGBA
+    position WatermarkPosition
+    opacity  float64
+    scale    float64
+    margin   float64
+    repeat   bool
+
+    mu     sync.Mutex
+    scaled map[image.Point]*image.RGBA
+}
+
+func prepareWatermark(w *Watermark) (*preparedWatermark, error) {
+    if w == nil {
+        return nil, nil
+    }
+    if (w.Text == "") == (w.Image == nil) {
+        return nil, invalidArgf("watermark requires either a text or an image")
+    }
+
+    p := &preparedWatermark{
+        opacity: w.Opacity,
+        scale:   w.Scale,
+        margin:  w.Margin,
+        repeat:  w.Repeat,
+        scaled:  make(map[image.Point]*image.RGBA),
+    }
+    switch pos := WatermarkPosition(strings.ToLower(strings.TrimSpace(string(w.Position)))); pos {
+    case "":
+        p.position = WatermarkBottomRight
+    case WatermarkCenter, WatermarkTopLeft, WatermarkTopRight, WatermarkBottomLeft, WatermarkBottomRight:
+        p.position = pos
+    default:
+        return nil, invalidArgf("unsupported watermark position: %s", w.Position)
+    }
+    if p.opacity < 0 || p.opacity > 1 || math.IsNaN(p.opacity) {
+        return nil, invalidArgf("watermark opacity must be between 0 and 1")
+    }
+    if p.opacity == 0 {
+        p.opacity = 0.5
+    }
+    if p.scale < 0 || p.scale > 1 || math.IsNaN(p.scale) {
+        return nil, invalidArgf("watermark scale must be between 0 and 1")
+    }
+    if p.scale == 0 {
+        p.scale = 0.25
+    }
+    if p.margin < 0 || p.margin >= 0.5 || math.IsNaN(p.margin) {
+        return nil, invalidArgf("watermark margin must be between 0 and 0.5")
+    }
+    if p.margin == 0 {
+        p.margin = 0.02
+    }
+
+    if w.Image != nil {
+        b := w.Image.Bounds()
+        if b.Empty() {
+            return nil, invalidArgf("watermark image is empty")
+        }
+        p.mark = cropImage(w.Image, b)
+        return p, nil
+    }
+
+    mark, err := renderText(w.Text, w.Font, w.Color)
+    if err != nil {
+        return nil, err
+    }
+    p.mark = mark
+    return p, nil
+}
+
+// renderText draws text onto a transparent canvas that is just large enough
+// to hold it.
+func renderText(text string, fontData []byte, c color.Color) (*image.RGBA, error) {
+    if c == nil {
+        c = color.White
+    }
+    face, err := newFontFace(fontData, watermarkRenderSize)
+    if err != nil {
+        return nil, invalidArgf("watermark font: %v", err)
+    }
+    defer face.Close()
+
+    bounds, _ := font.BoundString(face, text)
+    minX, minY := bounds.Min.X.Floor(), bounds.Min.Y.Floor()
+    width, height := bounds.Max.X.Ceil()-minX, bounds.Max.Y.Ceil()-minY
+    if width <= 0 || height <= 0 {
+        return nil, invalidArgf("watermark text has no visible glyphs")
+    }
+
+    dst := image.NewRGBA(image.Rect(0, 0, width, height))
+    d := font.Drawer{
+        Dst:  dst,
+        Src:  image.NewUniform(c),
+        Face: face,
+        Dot:  fixed.P(-minX, -minY),
+    }
+    d.DrawString(text)
+    return dst, nil
+}
+
+// newFontFace loads a face of the given pixel size from TrueType or OpenType
+// data, or from the built-in Go Regular font when data is nil.
+func newFontFace(data []byte, size float64) (font.Face, error) {
+    if data == nil {
+        data = goregular.TTF
+    }
+    f, err := opentype.Parse(data)
+    if err != nil {
+        return nil, err
+    }
+    return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+}
+
+// apply stamps the watermark onto tile in place.
+func (p *preparedWatermark) apply(tile *image.RGBA) {
+    tb := tile.Bounds()
+    mark := p.scaledMark(tb.Dx(), tb.Dy())
+    mb := mark.Bounds()
+    mask := image.NewUniform(color.Alpha{A: uint8(math.Round(p.opacity * 255))})
+    margin := int(math.Round(p.margin * float64(min(tb.Dx(), tb.Dy()))))
+
+    if p.repeat {
+        stepX, stepY := mb.Dx()+max(margin, mb.Dx()/2), mb.Dy()+max(margin, mb.Dy())
+        for row, y := 0, tb.Min.Y+margin; y < tb.Max.Y; row, y = row+1, y+stepY {
+            // Offset every other row by half a step for a brick pattern.
+            x := tb.Min.X + margin - (row%2)*stepX/2
+            for ; x < tb.Max.X; x += stepX {
+                r := mb.Add(image.Pt(x, y))
+                draw.DrawMask(tile, r, mark, image.Point{}, mask, image.Point{}, draw.Over)
+            }
+        }
+        return
+    }
+
+    var at image.Point
+    switch p.position {
+    case WatermarkCenter:
+        at = image.Pt((tb.Dx()-mb.Dx())/2, (tb.Dy()-mb.Dy())/2)
+    case WatermarkTopLeft:
+        at = image.Pt(margin, margin)
+    case WatermarkTopRight:
+        at = image.Pt(tb.Dx()-mb.Dx()-margin, margin)
+    case WatermarkBottomLeft:
+        at = image.Pt(margin, tb.Dy()-mb.Dy()-margin)
+    default:
+        at = image.Pt(tb.Dx()-mb.Dx()-margin, tb.Dy()-mb.Dy()-margin)
+    }
+    r := mb.Add(tb.Min).Add(at)
+    draw.DrawMask(tile, r, mark, image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// scaledMark returns the mark scaled for a tile of the given size.
+func (p *preparedWatermark) scaledMark(tileWidth, tileHeight int) *image.RGBA {
+    key := image.Pt(tileWidth, tileHeight)
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if mark, ok := p.scaled[key]; ok {
+        return mark
+    }
+
+    b := p.mark.Bounds()
+    factor := p.scale * float64(tileWidth) / float64(b.Dx())
+    if h := float64(b.Dy()) * factor; h > float64(tileHeight) {
+        factor = float64(tileHeight) / float64(b.Dy())
+    }
+    w := clampInt(int(math.Round(float64(b.Dx())*factor)), 1, tileWidth)
+    h := clampInt(int(math.Round(float64(b.Dy())*factor)), 1, tileHeight)
+    mark := resizeImage(p.mark, w, h)
+    p.scaled[key] = mark
+    return mark
+}
+
+// watermarkFingerprint identifies a watermark for incremental runs. Font
+// data and the image are hashed rather than embedded.
+func watermarkFingerprint(w *Watermark) string {
+    if w == nil {
+        return ""
+    }
+    h := sha256.New()
+    json.NewEncoder(h).Encode(struct {
+        Text     string
+        Position WatermarkPosition
+        Opacity  float64
+        Scale    float64
+        Margin   float64
+        Repeat   bool
+    }{w.Text, w.Position, w.Opacity, w.Scale, w.Margin, w.Repeat})
+    h.Write(w.Font)
+    if w.Color != nil {
+        r, g, b, a := w.Color.RGBA()
+        json.NewEncoder(h).Encode([]uint32{r, g, b, a})
+    }
+    if w.Image != nil {
+        png.Encode(h, w.Image)
+    }
+    return hex.EncodeToString(h.Sum(nil))
+}
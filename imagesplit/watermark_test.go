@@ -0,0 +1,123 @@
+package imagesplit
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func solidImage(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func splitSolidWithWatermark(t *testing.T, wm *Watermark) []*image.RGBA {
+	t.Helper()
+	mem := NewMemFS()
+	writePNG(t, mem, "black.png", solidImage(200, 100, color.Black))
+	files, err := GridSplit("black.png", 1, 2, SplitOptions{InputFS: mem, OutputFS: mem, OutputDir: "out", Watermark: wm})
+	if err != nil {
+		t.Fatalf("GridSplit returned error: %v", err)
+	}
+	var tiles []*image.RGBA
+	for _, name := range files {
+		img := readImage(t, mem, name)
+		tiles = append(tiles, cropImage(img, img.Bounds()))
+	}
+	return tiles
+}
+
+// markedArea returns the bounding box of the pixels that are no longer black.
+func markedArea(img *image.RGBA) image.Rectangle {
+	var r image.Rectangle
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if c := img.RGBAAt(x, y); c.R != 0 || c.G != 0 || c.B != 0 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func TestWatermarkTextEveryTile(t *testing.T) {
+	tiles := splitSolidWithWatermark(t, &Watermark{Text: "© Studio", Opacity: 1, Scale: 0.5})
+	for i, tile := range tiles {
+		area := markedArea(tile)
+		if area.Empty() {
+			t.Fatalf("tile %d has no watermark", i)
+		}
+		// Bottom-right placement with a 2% margin of the 100px tile side.
+		if area.Max.X > 98 || area.Max.Y > 98 || area.Min.X < 45 {
+			t.Errorf("tile %d: watermark at %v, expected bottom-right half", i, area)
+		}
+		if area.Dx() > 50 {
+			t.Errorf("tile %d: watermark width %d exceeds scale", i, area.Dx())
+		}
+	}
+}
+
+func TestWatermarkImagePlacementAndOpacity(t *testing.T) {
+	logo := solidImage(10, 10, color.White)
+	tiles := splitSolidWithWatermark(t, &Watermark{Image: logo, Position: WatermarkTopLeft, Opacity: 0.5, Scale: 0.2, Margin: 0.1})
+	tile := tiles[0]
+	if area := markedArea(tile); area != image.Rect(10, 10, 30, 30) {
+		t.Fatalf("unexpected watermark area %v", area)
+	}
+	if c := tile.RGBAAt(20, 20); c.R < 120 || c.R > 135 {
+		t.Errorf("expected half-opaque white over black, got %v", c)
+	}
+}
+
+func TestWatermarkRepeat(t *testing.T) {
+	logo := solidImage(10, 10, color.White)
+	tiles := splitSolidWithWatermark(t, &Watermark{Image: logo, Opacity: 1, Scale: 0.1, Repeat: true})
+	marks := 0
+	tile := tiles[1]
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if tile.RGBAAt(x, y).R == 255 && (x == 0 || tile.RGBAAt(x-1, y).R != 255) && (y == 0 || tile.RGBAAt(x, y-1).R != 255) {
+				marks++
+			}
+		}
+	}
+	if marks < 10 {
+		t.Errorf("expected the mark to repeat across the tile, found %d", marks)
+	}
+}
+
+func TestWatermarkValidation(t *testing.T) {
+	cases := []*Watermark{
+		{},
+		{Text: "a", Image: solidImage(1, 1, color.White)},
+		{Text: "a", Opacity: 2},
+		{Text: "a", Scale: -1},
+		{Text: "a", Position: "middle"},
+		{Text: "a", Font: []byte("not a font")},
+		{Text: " "},
+	}
+	for _, wm := range cases {
+		if _, err := prepareWatermark(wm); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("prepareWatermark(%+v) = %v, want ErrInvalidArgument", wm, err)
+		}
+	}
+}
+
+func TestWatermarkChangesFingerprint(t *testing.T) {
+	cfg := DirectorySplitConfig{Mode: DirectorySplitModeGrid, Rows: 1, Cols: 1}
+	plain, _ := configFingerprint(cfg)
+	cfg.Options.Watermark = &Watermark{Text: "a"}
+	marked, _ := configFingerprint(cfg)
+	cfg.Options.Watermark = &Watermark{Text: "b"}
+	other, _ := configFingerprint(cfg)
+	if plain == marked || marked == other {
+		t.Errorf("expected watermark to change the configuration fingerprint")
+	}
+}